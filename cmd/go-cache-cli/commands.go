@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/vishaldc/go-cache/internal/cache"
+	"github.com/vishaldc/go-cache/internal/client"
+)
+
+type command struct {
+	client *client.Client
+	out    *printer
+}
+
+var errUsage = errors.New("invalid arguments, run with -h for usage")
+
+func (c *command) run(ctx context.Context, name string, args []string) error {
+	switch name {
+	case "get":
+		if len(args) != 1 {
+			return errUsage
+		}
+		value, err := c.client.Get(args[0])
+		if err != nil {
+			return err
+		}
+		return c.out.value(args[0], value)
+	case "set":
+		fs := flag.NewFlagSet("set", flag.ContinueOnError)
+		ttl := fs.Duration("ttl", 0, "expire the key after this duration")
+		tags := fs.String("tags", "", "comma separated tags to group the key under")
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		if fs.NArg() != 2 {
+			return errUsage
+		}
+		opts := cache.SetOptions{TTL: *ttl}
+		if *tags != "" {
+			opts.Tags = strings.Split(*tags, ",")
+		}
+		return c.set(fs.Arg(0), fs.Arg(1), opts)
+	case "del":
+		fs := flag.NewFlagSet("del", flag.ContinueOnError)
+		var sel cache.Selector
+		fs.StringVar(&sel.Prefix, "prefix", "", "delete every key starting with prefix")
+		fs.StringVar(&sel.Match, "match", "", "delete every key matching this glob")
+		fs.StringVar(&sel.Tag, "tag", "", "delete every key written with this tag")
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		if sel.IsEmpty() {
+			if fs.NArg() != 1 {
+				return errUsage
+			}
+			return c.client.Delete(fs.Arg(0))
+		}
+		if fs.NArg() != 0 {
+			return errUsage
+		}
+		deleted, err := c.client.Invalidate(sel)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "deleted %d keys\n", deleted)
+		return nil
+	case "scan":
+		fs := flag.NewFlagSet("scan", flag.ContinueOnError)
+		match := fs.String("match", "", "only list keys matching this glob")
+		details := fs.Bool("l", false, "include the size and ttl of each key")
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		if fs.NArg() > 1 {
+			return errUsage
+		}
+		return c.scan(cache.ScanOptions{Prefix: optional(fs.Args(), 0), Match: *match}, *details)
+	case "dump":
+		if len(args) < 1 || len(args) > 2 {
+			return errUsage
+		}
+		return c.dump(args[0], optional(args, 1))
+	case "load":
+		if len(args) != 1 {
+			return errUsage
+		}
+		return c.load(args[0])
+	case "flush":
+		if len(args) != 0 {
+			return errUsage
+		}
+		deleted, err := c.client.Flush()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "deleted %d keys\n", deleted)
+		return nil
+	case "configure":
+		fs := flag.NewFlagSet("configure", flag.ContinueOnError)
+		var config cache.NamespaceConfig
+		fs.IntVar(&config.MaxKeys, "max-keys", 0, "keys kept before the oldest writes are evicted, 0 for no limit")
+		fs.Int64Var(&config.MaxBytes, "max-bytes", 0, "bytes kept before the oldest writes are evicted, 0 for no limit")
+		fs.DurationVar(&config.DefaultTTL, "default-ttl", 0, "ttl of the writes that do not set one, 0 for none")
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		if fs.NArg() != 0 {
+			return errUsage
+		}
+		stats, err := c.client.Configure(config)
+		if err != nil {
+			return err
+		}
+		return c.out.namespaces([]cache.NamespaceStats{*stats})
+	case "ns":
+		if len(args) != 0 {
+			return errUsage
+		}
+		stats, err := c.client.Namespaces()
+		if err != nil {
+			return err
+		}
+		return c.out.namespaces(stats)
+	case "members":
+		if len(args) != 0 {
+			return errUsage
+		}
+		members, err := c.client.Members()
+		if err != nil {
+			return err
+		}
+		return c.out.members(members)
+	case "tail":
+		if len(args) > 1 {
+			return errUsage
+		}
+		return c.client.Watch(ctx, optional(args, 0), c.out.event)
+	default:
+		return fmt.Errorf("unknown command %q", name)
+	}
+}
+
+func (c *command) set(key, raw string, opts cache.SetOptions) error {
+	if raw == "-" {
+		b, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		raw = string(b)
+	}
+
+	var value map[string]any
+	if err := json.Unmarshal([]byte(raw), &value); err != nil {
+		return fmt.Errorf("value must be a JSON object: %w", err)
+	}
+	return c.client.SetWithOptions(key, value, opts)
+}
+
+// scan follows cursors until every matching key has been listed
+func (c *command) scan(opts cache.ScanOptions, details bool) error {
+	var keys []cache.KeyInfo
+	for {
+		page, err := c.client.Scan(opts, details)
+		if err != nil {
+			return err
+		}
+		if details {
+			keys = append(keys, page.Entries...)
+		} else {
+			for _, k := range page.Keys {
+				keys = append(keys, cache.KeyInfo{Key: k})
+			}
+		}
+		if page.Cursor == "" {
+			break
+		}
+		opts.Cursor = page.Cursor
+	}
+	return c.out.keys(keys, details)
+}
+
+func (c *command) dump(path, prefix string) error {
+	w := io.Writer(os.Stdout)
+	if path != "-" {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	encoder := json.NewEncoder(w)
+	count := 0
+	err := c.client.Dump(prefix, func(e cache.Entry) error {
+		count++
+		return encoder.Encode(e)
+	})
+	if err != nil {
+		return err
+	}
+	if path != "-" {
+		fmt.Fprintf(os.Stderr, "dumped %d entries to %s\n", count, path)
+	}
+	return nil
+}
+
+func (c *command) load(path string) error {
+	r := io.Reader(os.Stdin)
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	decoder := json.NewDecoder(r)
+	count := 0
+	for decoder.More() {
+		var e cache.Entry
+		if err := decoder.Decode(&e); err != nil {
+			return fmt.Errorf("entry %d: %w", count+1, err)
+		}
+		if err := c.client.Set(e.Key, e.Value); err != nil {
+			return fmt.Errorf("set %q: %w", e.Key, err)
+		}
+		count++
+	}
+	fmt.Fprintf(os.Stderr, "loaded %d entries\n", count)
+	return nil
+}
+
+func optional(args []string, i int) string {
+	if i < len(args) {
+		return args[i]
+	}
+	return ""
+}
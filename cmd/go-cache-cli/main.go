@@ -0,0 +1,80 @@
+// Command go-cache-cli is an operator tool for reading and writing keys,
+// scanning, dumping and loading the keyspace of a worker, inspecting cluster
+// membership and tailing the change stream.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/vishaldc/go-cache/internal/client"
+)
+
+const usage = `usage: go-cache-cli [flags] <command> [args]
+
+commands:
+  get <key>            print the value stored under key
+  set [-ttl d] [-tags a,b] <key> <json>
+                       store a JSON object under key, use - to read it from stdin
+  del <key>            delete key
+  del [-prefix p] [-match glob] [-tag t]
+                       delete every matching key across the cluster
+  scan [-match glob] [-l] [prefix]
+                       list keys starting with prefix, -l adds sizes and ttls
+  dump <file> [prefix] write every entry to file as newline delimited JSON, - for stdout
+  load <file>          write every entry of a dump file back to the cache, - for stdin
+  configure [-max-keys n] [-max-bytes n] [-default-ttl d]
+                       create the namespace given with -ns across the cluster or
+                       replace its limits
+  flush                remove every key of the namespace given with -ns across the cluster
+  ns                   list namespaces with their limits and usage
+  members              show the worker and the pool it replicates to
+  tail [prefix]        print changes as they are applied until interrupted
+
+flags:
+`
+
+func main() {
+	addr := flag.String("addr", envOr("GO_CACHE_ADDR", "http://localhost:8080"), "address of the worker client port (GO_CACHE_ADDR)")
+	output := flag.String("o", "table", "output format: table or json")
+	ns := flag.String("ns", "", "namespace to address, the default namespace when empty")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	p, err := newPrinter(os.Stdout, *output)
+	if err != nil {
+		fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	cmd := &command{client: client.New(*addr).Namespace(*ns), out: p}
+	if err := cmd.run(ctx, flag.Arg(0), flag.Args()[1:]); err != nil {
+		fatal(err)
+	}
+}
+
+func envOr(name, fallback string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "go-cache-cli:", err)
+	os.Exit(1)
+}
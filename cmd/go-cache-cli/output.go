@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/vishaldc/go-cache/internal/cache"
+	"github.com/vishaldc/go-cache/internal/client"
+)
+
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+)
+
+// printer renders command results either as JSON or as aligned tables
+type printer struct {
+	w      io.Writer
+	format string
+}
+
+func newPrinter(w io.Writer, format string) (*printer, error) {
+	if format != formatTable && format != formatJSON {
+		return nil, fmt.Errorf("unknown output format %q", format)
+	}
+	return &printer{w: w, format: format}, nil
+}
+
+func (p *printer) json(v any) error {
+	encoder := json.NewEncoder(p.w)
+	if p.format == formatJSON {
+		encoder.SetIndent("", "  ")
+	}
+	return encoder.Encode(v)
+}
+
+func (p *printer) table(header []string, rows [][]string) error {
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	writeRow(tw, header)
+	for _, row := range rows {
+		writeRow(tw, row)
+	}
+	return tw.Flush()
+}
+
+func writeRow(w io.Writer, cols []string) {
+	for i, col := range cols {
+		if i > 0 {
+			fmt.Fprint(w, "\t")
+		}
+		fmt.Fprint(w, col)
+	}
+	fmt.Fprintln(w)
+}
+
+func (p *printer) value(key string, value map[string]any) error {
+	if p.format == formatJSON {
+		return p.json(value)
+	}
+
+	fields := make([]string, 0, len(value))
+	for f := range value {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+
+	rows := make([][]string, 0, len(fields))
+	for _, f := range fields {
+		rows = append(rows, []string{f, compact(value[f])})
+	}
+	return p.table([]string{"FIELD", "VALUE"}, rows)
+}
+
+func (p *printer) keys(keys []cache.KeyInfo, details bool) error {
+	if p.format == formatJSON {
+		if details {
+			return p.json(keys)
+		}
+		names := make([]string, 0, len(keys))
+		for _, k := range keys {
+			names = append(names, k.Key)
+		}
+		return p.json(names)
+	}
+
+	header := []string{"KEY"}
+	if details {
+		header = append(header, "SIZE", "TTL")
+	}
+	rows := make([][]string, 0, len(keys))
+	for _, k := range keys {
+		row := []string{k.Key}
+		if details {
+			ttl := "-"
+			if k.TTL >= 0 {
+				ttl = (time.Duration(k.TTL) * time.Second).String()
+			}
+			row = append(row, fmt.Sprint(k.Size), ttl)
+		}
+		rows = append(rows, row)
+	}
+	return p.table(header, rows)
+}
+
+func (p *printer) members(m *client.Membership) error {
+	if p.format == formatJSON {
+		return p.json(m)
+	}
+
+	rows := [][]string{}
+	if m.Self != nil {
+		rows = append(rows, workerRow("self", *m.Self))
+	}
+	for _, w := range m.Pool {
+		rows = append(rows, workerRow("peer", w))
+	}
+	return p.table([]string{"ROLE", "ID", "WORKER", "CREATED", "LAST HEARTBEAT"}, rows)
+}
+
+func (p *printer) namespaces(stats []cache.NamespaceStats) error {
+	if p.format == formatJSON {
+		return p.json(stats)
+	}
+
+	rows := make([][]string, 0, len(stats))
+	for _, ns := range stats {
+		name := ns.Name
+		if name == "" {
+			name = "(default)"
+		}
+		rows = append(rows, []string{
+			name,
+			fmt.Sprint(ns.Keys),
+			fmt.Sprint(ns.Bytes),
+			fmt.Sprint(ns.Evictions),
+			limit(int64(ns.Config.MaxKeys)),
+			limit(ns.Config.MaxBytes),
+			durationOrDash(ns.Config.DefaultTTL),
+		})
+	}
+	return p.table([]string{"NAMESPACE", "KEYS", "BYTES", "EVICTIONS", "MAX KEYS", "MAX BYTES", "DEFAULT TTL"}, rows)
+}
+
+func limit(n int64) string {
+	if n == 0 {
+		return "-"
+	}
+	return fmt.Sprint(n)
+}
+
+func durationOrDash(d time.Duration) string {
+	if d == 0 {
+		return "-"
+	}
+	return d.String()
+}
+
+func workerRow(role string, w client.Worker) []string {
+	return []string{role, fmt.Sprint(w.ID), w.Hostname, w.CreatedAt.Format(time.RFC3339), w.Updated.Format(time.RFC3339)}
+}
+
+// event prints a single change, tail uses one line per event in both formats
+func (p *printer) event(e cache.Event) error {
+	if p.format == formatJSON {
+		return json.NewEncoder(p.w).Encode(e)
+	}
+	value := ""
+	if e.Value != nil {
+		value = compact(e.Value)
+	}
+	_, err := fmt.Fprintf(p.w, "%s\t%-6s\t%s\t%s\n", e.Time.Format(time.RFC3339Nano), e.Op, e.Key, value)
+	return err
+}
+
+// compact renders v as single line JSON
+func compact(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
@@ -3,48 +3,272 @@ package main
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"os/signal"
 	"syscall"
+	"time"
 
+	"github.com/vishaldc/go-cache/internal/apierror"
+	"github.com/vishaldc/go-cache/internal/auth"
+	"github.com/vishaldc/go-cache/internal/cache"
 	"github.com/vishaldc/go-cache/internal/handlers"
+	"github.com/vishaldc/go-cache/internal/loader"
 	"github.com/vishaldc/go-cache/internal/log"
+	"github.com/vishaldc/go-cache/internal/metrics"
+	"github.com/vishaldc/go-cache/internal/notify"
+	"github.com/vishaldc/go-cache/internal/peer"
+	"github.com/vishaldc/go-cache/internal/ratelimit"
 	"github.com/vishaldc/go-cache/internal/registry"
+	"github.com/vishaldc/go-cache/internal/store"
+	"github.com/vishaldc/go-cache/internal/tlsconfig"
+	"github.com/vishaldc/go-cache/internal/tracing"
 	"go.uber.org/zap"
 )
 
+const (
+	// SHUTDOWN_TIMEOUT bounds the time the worker takes to stop once signalled
+	SHUTDOWN_TIMEOUT = 30 * time.Second
+
+	// DRAIN_DELAY is the time the readiness checks fail before the client
+	// listener closes, so load balancers stop routing to the worker first
+	DRAIN_DELAY = 5 * time.Second
+
+	// FLUSH_TIMEOUT bounds flushing the queued writes to the stores
+	FLUSH_TIMEOUT = 5 * time.Second
+
+	// DEREGISTER_TIMEOUT bounds leaving the registry and finishing the
+	// replication in flight
+	DEREGISTER_TIMEOUT = 5 * time.Second
+)
+
 func main() {
 	config := registry.LoadConfiguration()
+	peerConfig := peer.Config{CAFile: config.SyncCAFile, CertFile: config.SyncCertFile, KeyFile: config.SyncKeyFile, Secret: config.SyncSecret}
+	if err := peer.Setup(peerConfig); err != nil {
+		log.Logger.Fatal("failed to configure peer authentication", zap.String("error", err.Error()))
+	}
 	registry.Setup(config)
+	cache.SetLimits(cache.Limits{MaxKeyLength: config.MaxKeyLength, MaxValueSize: config.MaxValueSize, MaxBatchSize: config.MaxBatchSize})
+	if config.NamespacesFile != "" {
+		if err := cache.LoadNamespaces(config.NamespacesFile); err != nil {
+			log.Logger.Fatal("failed to load namespaces", zap.String("error", err.Error()))
+		}
+	}
+	// namespaces configured on the cluster before this worker joined
+	if err := registry.GetRegistry().Bootstrap(context.Background()); err != nil {
+		log.Logger.Warn("failed to bootstrap namespaces", zap.String("error", err.Error()))
+	}
+	if config.LoadersFile != "" {
+		if err := loader.Setup(registry.DB(), config.LoadersFile); err != nil {
+			log.Logger.Fatal("failed to load loaders", zap.String("error", err.Error()))
+		}
+	}
+	if config.StoresFile != "" {
+		if err := store.Setup(registry.DB(), config.StoresFile); err != nil {
+			log.Logger.Fatal("failed to load stores", zap.String("error", err.Error()))
+		}
+	}
+	if config.AuthFile != "" {
+		if err := auth.Setup(config.AuthFile); err != nil {
+			log.Logger.Fatal("failed to load auth", zap.String("error", err.Error()))
+		}
+	}
+	if len(config.NotifyChannels) > 0 {
+		if err := notify.Setup(registry.ConnectionString(), config.NotifyChannels, notify.ParseResync(config.NotifyResync)); err != nil {
+			log.Logger.Fatal("failed to listen for notifications", zap.String("error", err.Error()))
+		}
+	}
+	cache.RunJanitor()
 	// Create a context that listens for SIGTERM or SIGINT
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
 	defer stop()
 
+	if config.OTLPEndpoint != "" {
+		shutdownTracing, err := tracing.Setup(ctx, config.Hostname)
+		if err != nil {
+			log.Logger.Fatal("failed to set up tracing", zap.String("error", err.Error()))
+		}
+		defer func() {
+			if err := shutdownTracing(context.Background()); err != nil {
+				log.Logger.Error("failed to flush spans", zap.Error(err))
+			}
+		}()
+	}
+
+	// the sync handlers have their own mux so they are only served on the
+	// sync port, to the peers authenticated there
+	syncMux := http.NewServeMux()
+	syncServer := &http.Server{
+		Addr:      fmt.Sprintf(":%s", config.SyncPort),
+		Handler:   tracing.Middleware(metrics.Middleware(peer.Middleware(syncMux))),
+		TLSConfig: peer.ServerTLSConfig(),
+	}
+	if config.RateLimitsFile != "" {
+		if err := ratelimit.Setup(ctx, config.RateLimitsFile); err != nil {
+			log.Logger.Fatal("failed to load rate limits", zap.String("error", err.Error()))
+		}
+	}
+	server := &http.Server{Addr: fmt.Sprintf(":%s", config.ServerPort), Handler: clientHandler(http.DefaultServeMux)}
+	// watch streams never complete on their own, their requests are cancelled
+	// as soon as shutdown starts so they do not hold it up
+	streams, cancelStreams := context.WithCancel(context.Background())
+	server.BaseContext = func(net.Listener) context.Context { return streams }
+	server.RegisterOnShutdown(cancelStreams)
+	if config.TLSCertFile != "" || config.TLSKeyFile != "" {
+		reloader, err := tlsconfig.New(tlsconfig.Config{
+			CertFile:     config.TLSCertFile,
+			KeyFile:      config.TLSKeyFile,
+			MinVersion:   config.TLSMinVersion,
+			CipherSuites: config.TLSCipherSuites,
+			ClientCAFile: config.TLSClientCAFile,
+			ClientAuth:   config.TLSClientAuth,
+		})
+		if err != nil {
+			log.Logger.Fatal("failed to load TLS certificate", zap.String("error", err.Error()))
+		}
+		server.TLSConfig = reloader.TLSConfig()
+		go reloader.Watch(ctx, tlsconfig.RELOAD_INTERVAL)
+	}
+	var adminServer *http.Server
+
 	go func() {
 		// start a different server on a different port for the sync handlers
 		// Sync handlers
-		http.HandleFunc("POST /cache/sync", handlers.SyncPostHandler)
-		http.HandleFunc("DELETE /cache/sync", handlers.SyncDeleteHandler)
+		syncMux.HandleFunc("POST /cache/sync", handlers.SyncPostHandler)
+		syncMux.HandleFunc("DELETE /cache/sync", handlers.SyncDeleteHandler)
+		syncMux.HandleFunc("POST /cache/sync/incr", handlers.SyncIncrHandler)
+		syncMux.HandleFunc("POST /cache/sync/collection", handlers.SyncCollectionHandler)
+		syncMux.HandleFunc("POST /cache/sync/txn", handlers.SyncTxnHandler)
+		syncMux.HandleFunc("PUT /cache/sync/indexes", handlers.SyncIndexPutHandler)
+		syncMux.HandleFunc("DELETE /cache/sync/indexes", handlers.SyncIndexDeleteHandler)
+		syncMux.HandleFunc("DELETE /cache/sync/invalidate", handlers.SyncInvalidateHandler)
+		syncMux.HandleFunc("DELETE /cache/sync/flush", handlers.SyncFlushHandler)
+		syncMux.HandleFunc("GET /cache/sync/ns", handlers.SyncNamespacesHandler)
+		syncMux.HandleFunc("PUT /cache/sync/ns", handlers.SyncNamespacePutHandler)
+		syncMux.HandleFunc("POST /cache/sync/ratelimit", handlers.SyncRateLimitHandler)
 		log.Logger.Info("starting sync server on:", zap.String("port", config.SyncPort))
-		if err := http.ListenAndServe(fmt.Sprintf(":%s", config.SyncPort), nil); err != nil {
+		listen := syncServer.ListenAndServe
+		if syncServer.TLSConfig != nil {
+			// the certificate is in the TLS configuration
+			listen = func() error { return syncServer.ListenAndServeTLS("", "") }
+		}
+		if err := listen(); err != http.ErrServerClosed {
 			log.Logger.Fatal("could not start sync server:", zap.String("error", err.Error()))
 		}
 	}()
 
 	// Main server
 	go func() {
-		http.HandleFunc("GET /cache", handlers.GetHandler)
-		http.HandleFunc("POST /cache", handlers.PostHandler)
-		http.HandleFunc("DELETE /cache", handlers.DeleteHandler)
+		// the default namespace is served under /cache and named ones under /ns/{name}/cache
+		for _, prefix := range []string{"", "/ns/{name}"} {
+			http.HandleFunc("GET "+prefix+"/cache", auth.Require(auth.Read, handlers.GetHandler))
+			http.HandleFunc("POST "+prefix+"/cache", auth.Require(auth.Write, handlers.PostHandler))
+			http.HandleFunc("PATCH "+prefix+"/cache", auth.Require(auth.Write, handlers.PatchHandler))
+			http.HandleFunc("DELETE "+prefix+"/cache", auth.Require(auth.Delete, handlers.DeleteHandler))
+			http.HandleFunc("POST "+prefix+"/cache/txn", auth.Require(auth.Write, handlers.TxnHandler))
+			http.HandleFunc("POST "+prefix+"/cache/incr", auth.Require(auth.Write, handlers.IncrHandler))
+			http.HandleFunc("POST "+prefix+"/cache/decr", auth.Require(auth.Write, handlers.DecrHandler))
+			for _, op := range cache.CollectionOps {
+				http.HandleFunc("POST "+prefix+"/cache/"+op, auth.Require(auth.Write, handlers.CollectionHandler))
+			}
+			http.HandleFunc("GET "+prefix+"/cache/hget", auth.Require(auth.Read, handlers.HGetHandler))
+			http.HandleFunc("GET "+prefix+"/cache/lrange", auth.Require(auth.Read, handlers.LRangeHandler))
+			http.HandleFunc("GET "+prefix+"/cache/smembers", auth.Require(auth.Read, handlers.SMembersHandler))
+			http.HandleFunc("GET "+prefix+"/cache/zrange", auth.Require(auth.Read, handlers.ZRangeHandler))
+			http.HandleFunc("DELETE "+prefix+"/cache/tags/{tag}", auth.Require(auth.Delete, handlers.TagDeleteHandler))
+			http.HandleFunc("GET "+prefix+"/cache/keys", auth.Require(auth.Read, handlers.KeysHandler))
+			http.HandleFunc("GET "+prefix+"/cache/query", auth.Require(auth.Read, handlers.QueryHandler))
+			http.HandleFunc("GET "+prefix+"/cache/indexes", auth.Require(auth.Read, handlers.IndexesHandler))
+			http.HandleFunc("PUT "+prefix+"/cache/indexes/{index}", auth.Require(auth.Admin, handlers.IndexPutHandler))
+			http.HandleFunc("DELETE "+prefix+"/cache/indexes/{index}", auth.Require(auth.Admin, handlers.IndexDeleteHandler))
+			http.HandleFunc("GET "+prefix+"/cache/dump", auth.Require(auth.Read, handlers.DumpHandler))
+			http.HandleFunc("GET "+prefix+"/cache/watch", auth.Require(auth.Read, handlers.WatchHandler))
+		}
+		http.HandleFunc("GET /ns", auth.Require(auth.Admin, handlers.NamespacesHandler))
+		http.HandleFunc("GET /ns/{name}", auth.Require(auth.Read, handlers.NamespaceGetHandler))
+		http.HandleFunc("PUT /ns/{name}", auth.Require(auth.Admin, handlers.NamespacePutHandler))
+		http.HandleFunc("POST /ns/{name}/flush", auth.Require(auth.Admin, handlers.FlushHandler))
+		http.HandleFunc("GET /cluster/members", auth.Require(auth.Admin, handlers.MembersHandler))
+		http.HandleFunc("GET /cluster/notifications", auth.Require(auth.Admin, handlers.NotificationsHandler))
+		http.HandleFunc("GET /healthz", handlers.HealthzHandler)
+		http.HandleFunc("GET /readyz", handlers.ReadyzHandler)
+		http.HandleFunc("GET /status", auth.Require(auth.Admin, handlers.StatusHandler))
 
 		log.Logger.Info("starting server on:", zap.String("port", config.ServerPort))
-		if err := http.ListenAndServe(fmt.Sprintf(":%s", config.ServerPort), nil); err != nil {
+		listen := server.ListenAndServe
+		if server.TLSConfig != nil {
+			// the certificate is served by the TLS configuration
+			listen = func() error { return server.ListenAndServeTLS("", "") }
+		}
+		if err := listen(); err != http.ErrServerClosed {
 			log.Logger.Fatal("could not start server:", zap.String("error", err.Error()))
 		}
 	}()
 
+	// Admin server, kept off the client port so metrics are not public
+	if config.AdminPort != "" {
+		mux := http.NewServeMux()
+		mux.Handle("GET /metrics", metrics.Handler())
+		adminServer = &http.Server{Addr: fmt.Sprintf(":%s", config.AdminPort), Handler: mux}
+		go func() {
+			log.Logger.Info("starting admin server on:", zap.String("port", config.AdminPort))
+			if err := adminServer.ListenAndServe(); err != http.ErrServerClosed {
+				log.Logger.Fatal("could not start admin server:", zap.String("error", err.Error()))
+			}
+		}()
+	}
+
 	// Wait for SIGTERM or SIGINT
 	<-ctx.Done()
 	log.Logger.Info("shutdown signal received")
+	shutdown(server, syncServer, adminServer)
+}
 
+// clientHandler wraps the client facing routes of mux. Auth replaces the
+// request to record its principal, it wraps the metrics and tracing so they
+// read the route pattern the mux sets on the request they passed on.
+func clientHandler(mux http.Handler) http.Handler {
+	return apierror.Middleware(auth.Middleware(tracing.Middleware(metrics.Middleware(ratelimit.Middleware(mux)))))
+}
+
+// shutdown stops the worker within SHUTDOWN_TIMEOUT: the readiness checks
+// fail for DRAIN_DELAY, the client server stops accepting requests and waits
+// for the ones in flight, the writes they made are flushed to the stores and
+// the pool, and the worker leaves the registry before the sync and admin
+// servers stop. Flushing and leaving the registry have their own deadlines so
+// slow client requests can not starve them.
+func shutdown(server, syncServer, adminServer *http.Server) {
+	reg := registry.GetRegistry()
+	reg.Drain()
+	time.Sleep(DRAIN_DELAY)
+
+	ctx, cancel := context.WithTimeout(context.Background(), SHUTDOWN_TIMEOUT-DRAIN_DELAY-FLUSH_TIMEOUT-DEREGISTER_TIMEOUT)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Logger.Error("failed to shut down server", zap.Error(err))
+	}
+
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), FLUSH_TIMEOUT)
+	defer cancelFlush()
+	if err := store.Flush(flushCtx); err != nil {
+		log.Logger.Error("failed to flush stores", zap.Error(err))
+	}
+
+	regCtx, cancelReg := context.WithTimeout(context.Background(), DEREGISTER_TIMEOUT)
+	defer cancelReg()
+	if err := reg.Shutdown(regCtx); err != nil {
+		log.Logger.Error("failed to shut down registry", zap.Error(err))
+	}
+
+	// the peers stopped replicating to the worker once it left the registry
+	if err := syncServer.Shutdown(regCtx); err != nil {
+		log.Logger.Error("failed to shut down sync server", zap.Error(err))
+	}
+	if adminServer != nil {
+		if err := adminServer.Shutdown(regCtx); err != nil {
+			log.Logger.Error("failed to shut down admin server", zap.Error(err))
+		}
+	}
+	log.Logger.Info("shutdown completed")
 }
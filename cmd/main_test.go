@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vishaldc/go-cache/internal/auth"
+	"github.com/vishaldc/go-cache/internal/metrics"
+)
+
+func TestClientHandlerRoute(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "auth.json")
+	config := `{"api_keys": [{"name": "reader", "key": "r-key", "permissions": [{"namespace": "*", "operations": ["read"]}]}]}`
+	assert.NoError(t, os.WriteFile(path, []byte(config), 0o600))
+	assert.NoError(t, auth.Setup(path))
+	t.Cleanup(auth.Disable)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /route/{key}", auth.Require(auth.Read, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	// Test requests are labelled with their route when auth is enabled
+	req := httptest.NewRequest("GET", "/route/a", nil)
+	req.Header.Set(auth.API_KEY_HEADER, "r-key")
+	rr := httptest.NewRecorder()
+	clientHandler(mux).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	rr = httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Contains(t, rr.Body.String(), `go_cache_http_request_duration_seconds_count{code="204",handler="GET /route/{key}"} 1`)
+}
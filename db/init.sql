@@ -14,6 +14,32 @@ CREATE TABLE go_cache.workers (
 CREATE INDEX idx_workers_created_at_desc ON go_cache.workers (created_at DESC);
 CREATE INDEX idx_workers_updated_at_desc ON go_cache.workers (updated_at DESC);
 
+-- Keys written through or behind by the namespaces with a store
+DROP TABLE IF EXISTS go_cache.entries;
+CREATE TABLE go_cache.entries (
+    namespace character varying(255) NOT NULL,
+    key text NOT NULL,
+    value jsonb NOT NULL,
+    version bigint NOT NULL,
+    updated_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    PRIMARY KEY (namespace, key)
+);
+
+-- Writes behind that exhausted their retries
+DROP TABLE IF EXISTS go_cache.dead_letters;
+CREATE TABLE go_cache.dead_letters (
+    id integer PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    namespace character varying(255) NOT NULL,
+    key text NOT NULL,
+    op character varying(16) NOT NULL,
+    value jsonb,
+    version bigint NOT NULL,
+    error text NOT NULL,
+    attempts integer NOT NULL,
+    failed_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+CREATE INDEX idx_dead_letters_failed_at_desc ON go_cache.dead_letters (failed_at DESC);
+
 -- List all schemas
 -- SELECT schema_name FROM information_schema.schemata;
 
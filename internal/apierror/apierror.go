@@ -0,0 +1,133 @@
+package apierror
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// REQUEST_ID_HEADER carries the id of a request, a valid id sent by the client
+// is kept and a new one is generated otherwise
+const REQUEST_ID_HEADER = "X-Request-ID"
+
+// MAX_REQUEST_ID_LENGTH bounds the request ids accepted from clients
+const MAX_REQUEST_ID_LENGTH = 128
+
+// The codes of the failures not caused by the cache, the codes of the cache
+// errors are returned by cache.Code. Codes are stable, clients match them
+// rather than the messages.
+const (
+	CodeMethodNotAllowed     = "method_not_allowed"
+	CodeMissingKey           = "missing_key"
+	CodeMissingParameter     = "missing_parameter"
+	CodeInvalidParameter     = "invalid_parameter"
+	CodeInvalidBody          = "invalid_body"
+	CodeUnsupportedMediaType = "unsupported_media_type"
+	CodeBodyTooLarge         = "body_too_large"
+	CodeUnauthenticated      = "unauthenticated"
+	CodePermissionDenied     = "permission_denied"
+	CodeRateLimited          = "rate_limited"
+	CodeNotReady             = "not_ready"
+	CodeStoreFailed          = "store_failed"
+	CodeInternal             = "internal"
+)
+
+// Error is the JSON body of a failed request
+type Error struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Key       string `json:"key,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// Write answers a failed request with status. Clients accepting JSON get an
+// Error, the others the message as plain text like http.Error.
+func Write(w http.ResponseWriter, r *http.Request, status int, code, message, key string) {
+	if !wantsJSON(r) {
+		http.Error(w, message, status)
+		return
+	}
+
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Type", "application/json")
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(Error{Code: code, Message: message, Key: key, RequestID: RequestID(r)})
+}
+
+// wantsJSON reports whether the client prefers JSON to plain text. Clients
+// that do not name a JSON media type, such as curl sending */*, keep the
+// plain text messages.
+func wantsJSON(r *http.Request) bool {
+	var jsonQ, textQ float64
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if s, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(s, 64); err != nil {
+				continue
+			}
+		}
+		switch mediaType {
+		// an error is a single line of a stream of JSON objects
+		case "application/json", "application/problem+json", "application/x-ndjson":
+			jsonQ = max(jsonQ, q)
+		case "text/plain":
+			textQ = max(textQ, q)
+		}
+	}
+	return jsonQ > 0 && jsonQ >= textQ
+}
+
+type requestIDKey struct{}
+
+// RequestID returns the id of a request, the one sent by the client when the
+// middleware did not assign it
+func RequestID(r *http.Request) string {
+	if id, ok := r.Context().Value(requestIDKey{}).(string); ok {
+		return id
+	}
+	return r.Header.Get(REQUEST_ID_HEADER)
+}
+
+// Middleware assigns an id to every request, returned in the X-Request-ID
+// header of the response and in the body of the errors
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(REQUEST_ID_HEADER)
+		if !validID(id) {
+			id = newID()
+		}
+		w.Header().Set(REQUEST_ID_HEADER, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// validID reports whether a request id sent by a client can be logged and
+// echoed back as is
+func validID(id string) bool {
+	if id == "" || len(id) > MAX_REQUEST_ID_LENGTH {
+		return false
+	}
+	for _, c := range id {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,68 @@
+package apierror
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		accept string
+		json   bool
+	}{
+		{"", false},
+		{"*/*", false},
+		{"text/plain", false},
+		{"application/json", true},
+		{"application/x-ndjson", true},
+		{"text/plain;q=0.5, application/json", true},
+		{"application/json;q=0.5, text/plain", false},
+		{"application/json;q=0", false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/cache?key=a", nil)
+		req.Header.Set("Accept", tt.accept)
+		req.Header.Set(REQUEST_ID_HEADER, "req-1")
+		rr := httptest.NewRecorder()
+		Write(rr, req, http.StatusNotFound, "key_not_found", "key not found in cache", "a")
+		assert.Equal(t, http.StatusNotFound, rr.Code, tt.accept)
+
+		// Test plain text clients keep the message of http.Error
+		if !tt.json {
+			assert.Equal(t, "key not found in cache\n", rr.Body.String(), tt.accept)
+			continue
+		}
+		assert.Equal(t, "application/json", rr.Header().Get("Content-Type"), tt.accept)
+		var body Error
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body), tt.accept)
+		assert.Equal(t, Error{Code: "key_not_found", Message: "key not found in cache", Key: "a", RequestID: "req-1"}, body, tt.accept)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var id string
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id = RequestID(r)
+	}))
+
+	// Test a request id is generated and returned
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/cache", nil))
+	assert.Len(t, id, 32)
+	assert.Equal(t, id, rr.Header().Get(REQUEST_ID_HEADER))
+
+	// Test the id of the client is kept unless it is not valid
+	req := httptest.NewRequest("GET", "/cache", nil)
+	req.Header.Set(REQUEST_ID_HEADER, "client-id")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, "client-id", id)
+
+	req.Header.Set(REQUEST_ID_HEADER, "bad id")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	assert.NotEqual(t, "bad id", id)
+	assert.Len(t, id, 32)
+}
@@ -0,0 +1,406 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/vishaldc/go-cache/internal/apierror"
+	"github.com/vishaldc/go-cache/internal/log"
+	"go.uber.org/zap"
+)
+
+// API_KEY_HEADER is the header carrying a static API key
+const API_KEY_HEADER = "X-API-Key"
+
+// ANY_NAMESPACE grants a permission on every namespace
+const ANY_NAMESPACE = "*"
+
+var (
+	ErrorInvalidAuth        = errors.New("invalid auth configuration")
+	ErrorMissingCredentials = errors.New("missing credentials")
+	ErrorInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Operation is the kind of access a handler needs
+type Operation string
+
+const (
+	Read   Operation = "read"
+	Write  Operation = "write"
+	Delete Operation = "delete"
+	// Admin changes the configuration of a namespace or the cluster, it
+	// grants every other operation
+	Admin Operation = "admin"
+)
+
+// Permission grants operations on the keys of a namespace starting with a
+// prefix. The default namespace is the empty name and ANY_NAMESPACE matches
+// every namespace. A request without a key, such as a listing, is only
+// allowed by a permission without a prefix, or one that is a prefix of the
+// prefix it lists. Handlers touching keys the request does not name, such
+// as transactions and queries, check each of them with Allowed.
+type Permission struct {
+	Namespace  string      `json:"namespace"`
+	Prefix     string      `json:"prefix,omitempty"`
+	Operations []Operation `json:"operations"`
+}
+
+// allows reports whether the permission grants op on the keys of the
+// namespace starting with prefix
+func (p Permission) allows(op Operation, ns, prefix string) bool {
+	if p.Namespace != ANY_NAMESPACE && p.Namespace != ns {
+		return false
+	}
+	if !strings.HasPrefix(prefix, p.Prefix) {
+		return false
+	}
+	return slices.Contains(p.Operations, op) || slices.Contains(p.Operations, Admin)
+}
+
+// APIKey is a static key sent in the X-API-Key header
+type APIKey struct {
+	Name        string       `json:"name"`
+	Key         string       `json:"key"`
+	Permissions []Permission `json:"permissions"`
+}
+
+// JWT verifies bearer tokens signed with HS256 by a shared secret or RS256
+// by a key of a local JWKS file. Tokens must expire and carry their
+// permissions in a permissions claim.
+type JWT struct {
+	Secret   string `json:"secret,omitempty"`
+	JWKSFile string `json:"jwks_file,omitempty"`
+	Issuer   string `json:"issuer,omitempty"`
+	Audience string `json:"audience,omitempty"`
+}
+
+// Config is the content of the auth file
+type Config struct {
+	APIKeys []APIKey `json:"api_keys,omitempty"`
+	JWT     *JWT     `json:"jwt,omitempty"`
+}
+
+// Principal is the caller a request was authenticated as
+type Principal struct {
+	Name        string
+	Permissions []Permission
+}
+
+// Allows reports whether any permission of the principal grants op on the
+// keys of the namespace starting with prefix
+func (p *Principal) Allows(op Operation, ns, prefix string) bool {
+	for _, permission := range p.Permissions {
+		if permission.allows(op, ns, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// claims are the claims of a bearer token
+type claims struct {
+	jwt.RegisteredClaims
+	Permissions []Permission `json:"permissions"`
+}
+
+// authenticator holds the credentials of the configuration
+type authenticator struct {
+	keys   []APIKey
+	jwt    *JWT
+	rsa    map[string]*rsa.PublicKey
+	parser *jwt.Parser
+}
+
+var mu sync.RWMutex
+var current *authenticator
+
+// Setup requires the credentials defined in a JSON file on the handlers
+// wrapped by Require, such as
+//
+//	{"api_keys": [{"name": "ci", "key": "...", "permissions": [
+//	    {"namespace": "users", "prefix": "user:", "operations": ["read"]}]}],
+//	 "jwt": {"jwks_file": "jwks.json", "issuer": "https://auth.example.com"}}
+//
+// Without it every request is allowed.
+func Setup(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var config Config
+	if err := json.Unmarshal(b, &config); err != nil {
+		return errors.Join(ErrorInvalidAuth, err)
+	}
+	a, err := newAuthenticator(config)
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	current = a
+	log.Logger.Info("auth enabled", zap.Int("api_keys", len(a.keys)), zap.Bool("jwt", a.jwt != nil))
+	return nil
+}
+
+// Disable allows every request again
+func Disable() {
+	mu.Lock()
+	defer mu.Unlock()
+	current = nil
+}
+
+func newAuthenticator(config Config) (*authenticator, error) {
+	a := &authenticator{keys: config.APIKeys, jwt: config.JWT}
+	for _, key := range a.keys {
+		if key.Key == "" {
+			return nil, fmt.Errorf("%w: api key %q is empty", ErrorInvalidAuth, key.Name)
+		}
+	}
+	if a.jwt == nil {
+		return a, nil
+	}
+
+	if a.jwt.Secret == "" && a.jwt.JWKSFile == "" {
+		return nil, fmt.Errorf("%w: jwt needs a secret or a jwks file", ErrorInvalidAuth)
+	}
+	methods := []string{}
+	if a.jwt.Secret != "" {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if a.jwt.JWKSFile != "" {
+		keys, err := loadJWKS(a.jwt.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		a.rsa = keys
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+	opts := []jwt.ParserOption{jwt.WithValidMethods(methods), jwt.WithExpirationRequired()}
+	if a.jwt.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(a.jwt.Issuer))
+	}
+	if a.jwt.Audience != "" {
+		opts = append(opts, jwt.WithAudience(a.jwt.Audience))
+	}
+	a.parser = jwt.NewParser(opts...)
+	return a, nil
+}
+
+// loadJWKS returns the RSA keys of a JWKS file by key id
+func loadJWKS(path string) (map[string]*rsa.PublicKey, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var jwks struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(b, &jwks); err != nil {
+		return nil, errors.Join(ErrorInvalidAuth, err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range jwks.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("%w: key %q: %v", ErrorInvalidAuth, k.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("%w: key %q: %v", ErrorInvalidAuth, k.Kid, err)
+		}
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%w: no RSA key in %s", ErrorInvalidAuth, path)
+	}
+	return keys, nil
+}
+
+// authenticate returns the principal of the credentials of a request
+func (a *authenticator) authenticate(r *http.Request) (*Principal, error) {
+	if key := r.Header.Get(API_KEY_HEADER); key != "" {
+		for _, k := range a.keys {
+			if subtle.ConstantTimeCompare([]byte(key), []byte(k.Key)) == 1 {
+				return &Principal{Name: k.Name, Permissions: k.Permissions}, nil
+			}
+		}
+		return nil, ErrorInvalidCredentials
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return nil, ErrorMissingCredentials
+	}
+	if a.parser == nil {
+		return nil, ErrorInvalidCredentials
+	}
+	var c claims
+	if _, err := a.parser.ParseWithClaims(token, &c, a.key); err != nil {
+		return nil, errors.Join(ErrorInvalidCredentials, err)
+	}
+	return &Principal{Name: c.Subject, Permissions: c.Permissions}, nil
+}
+
+// key returns the key verifying a token for its signing method
+func (a *authenticator) key(token *jwt.Token) (any, error) {
+	if token.Method == jwt.SigningMethodHS256 {
+		return []byte(a.jwt.Secret), nil
+	}
+	kid, _ := token.Header["kid"].(string)
+	if key, ok := a.rsa[kid]; ok {
+		return key, nil
+	}
+	// a token without a key id is verified by the only key of the file
+	if kid == "" && len(a.rsa) == 1 {
+		for _, key := range a.rsa {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown key id %q", kid)
+}
+
+// Identify returns the name of the principal a request was authenticated as
+// by Middleware, false when auth is disabled or the credentials are not valid
+func Identify(r *http.Request) (string, bool) {
+	principal := FromContext(r.Context())
+	if principal == nil {
+		return "", false
+	}
+	return principal.Name, true
+}
+
+type authKey struct{}
+
+// authentication is the outcome of authenticating a request
+type authentication struct {
+	principal *Principal
+	err       error
+}
+
+// FromContext returns the principal a request was authenticated as, nil
+// when auth is disabled or the credentials are not valid
+func FromContext(ctx context.Context) *Principal {
+	a, _ := ctx.Value(authKey{}).(authentication)
+	return a.principal
+}
+
+// Middleware authenticates every request once, so tokens are verified a
+// single time, and records the outcome in the request context for Require
+// and the rate limits. It refuses no request, routes served without Require
+// such as the probes stay public. The request it passes on is a copy, so it
+// wraps the middlewares reading the route pattern set by the mux.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.RLock()
+		a := current
+		mu.RUnlock()
+		if a == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		principal, err := a.authenticate(r)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), authKey{}, authentication{principal: principal, err: err})))
+	})
+}
+
+// authenticated returns the outcome of authenticating a request recorded by
+// Middleware, authenticating requests that did not go through it
+func (a *authenticator) authenticated(r *http.Request) (*http.Request, *Principal, error) {
+	if outcome, ok := r.Context().Value(authKey{}).(authentication); ok {
+		return r, outcome.principal, outcome.err
+	}
+	principal, err := a.authenticate(r)
+	return r.WithContext(context.WithValue(r.Context(), authKey{}, authentication{principal: principal, err: err})), principal, err
+}
+
+// Allowed reports whether the principal of a request context is granted op
+// on the keys of the namespace starting with prefix. Every request is allowed
+// when auth is disabled, none when it is enabled and the request was not
+// authenticated.
+func Allowed(ctx context.Context, op Operation, ns, prefix string) bool {
+	if p := FromContext(ctx); p != nil {
+		return p.Allows(op, ns, prefix)
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	return current == nil
+}
+
+// Require wraps a handler so it only serves requests whose credentials grant
+// op on the namespace and key of the request, answering 401 to requests
+// without valid credentials and 403 to the ones not allowed
+func Require(op Operation, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mu.RLock()
+		a := current
+		mu.RUnlock()
+		if a == nil {
+			next(w, r)
+			return
+		}
+
+		r, principal, err := a.authenticated(r)
+		if err != nil {
+			log.Logger.Warn("unauthenticated request", zap.String("path", r.URL.Path), zap.Error(err))
+			w.Header().Set("WWW-Authenticate", `Bearer realm="go-cache"`)
+			apierror.Write(w, r, http.StatusUnauthorized, apierror.CodeUnauthenticated, errorMessage(err), "")
+			return
+		}
+
+		ns := r.PathValue("name")
+		if ns == "" {
+			ns = r.URL.Query().Get("ns")
+		}
+		// a request naming both a key and a prefix must be allowed both
+		var prefixes []string
+		for _, param := range []string{"key", "prefix"} {
+			if v := r.URL.Query().Get(param); v != "" {
+				prefixes = append(prefixes, v)
+			}
+		}
+		if len(prefixes) == 0 {
+			prefixes = []string{""}
+		}
+		for _, prefix := range prefixes {
+			if !principal.Allows(op, ns, prefix) {
+				log.Logger.Warn("permission denied", zap.String("principal", principal.Name), zap.String("operation", string(op)),
+					zap.String("namespace", ns), zap.String("key", prefix))
+				apierror.Write(w, r, http.StatusForbidden, apierror.CodePermissionDenied, "permission denied", r.URL.Query().Get("key"))
+				return
+			}
+		}
+
+		next(w, r)
+	}
+}
+
+// errorMessage returns the message of an authentication error without the
+// details of why a token was rejected
+func errorMessage(err error) string {
+	if errors.Is(err, ErrorMissingCredentials) {
+		return ErrorMissingCredentials.Error()
+	}
+	return ErrorInvalidCredentials.Error()
+}
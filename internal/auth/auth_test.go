@@ -0,0 +1,208 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// setup enables auth with config for the duration of a test
+func setup(t *testing.T, config string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "auth.json")
+	assert.NoError(t, os.WriteFile(path, []byte(config), 0o600))
+	assert.NoError(t, Setup(path))
+	t.Cleanup(Disable)
+}
+
+// serve routes a request through a handler requiring op
+func serve(op Operation, method, target string, header http.Header) *httptest.ResponseRecorder {
+	mux := http.NewServeMux()
+	handler := Require(op, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(FromContext(r.Context()).Name))
+	})
+	mux.HandleFunc(method+" /cache", handler)
+	mux.HandleFunc(method+" /ns/{name}/cache", handler)
+
+	req := httptest.NewRequest(method, target, nil)
+	for k, values := range header {
+		for _, v := range values {
+			req.Header.Add(k, v)
+		}
+	}
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestRequireDisabled(t *testing.T) {
+	// Test every request is allowed without an auth file
+	mux := http.NewServeMux()
+	mux.HandleFunc("DELETE /cache", Require(Delete, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("DELETE", "/cache?key=a", nil))
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+}
+
+func TestRequireAPIKey(t *testing.T) {
+	setup(t, `{"api_keys": [
+		{"name": "reader", "key": "r-key", "permissions": [{"namespace": "users", "prefix": "user:", "operations": ["read"]}]},
+		{"name": "admin", "key": "a-key", "permissions": [{"namespace": "*", "operations": ["admin"]}]}
+	]}`)
+	reader := http.Header{API_KEY_HEADER: {"r-key"}}
+
+	// Test requests without or with an unknown key are unauthenticated
+	rr := serve(Read, "GET", "/ns/users/cache?key=user:1", nil)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Equal(t, "missing credentials\n", rr.Body.String())
+	assert.NotEmpty(t, rr.Header().Get("WWW-Authenticate"))
+	rr = serve(Read, "GET", "/ns/users/cache?key=user:1", http.Header{API_KEY_HEADER: {"unknown"}})
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	// Test the permission is scoped to the operation, namespace and prefix
+	rr = serve(Read, "GET", "/ns/users/cache?key=user:1", reader)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "reader", rr.Body.String())
+	assert.Equal(t, http.StatusForbidden, serve(Write, "POST", "/ns/users/cache?key=user:1", reader).Code)
+	assert.Equal(t, http.StatusForbidden, serve(Read, "GET", "/ns/users/cache?key=session:1", reader).Code)
+	assert.Equal(t, http.StatusForbidden, serve(Read, "GET", "/cache?key=user:1", reader).Code)
+
+	// Test listings are allowed within the prefix only
+	assert.Equal(t, http.StatusOK, serve(Read, "GET", "/ns/users/cache?prefix=user:4", reader).Code)
+	assert.Equal(t, http.StatusForbidden, serve(Read, "GET", "/ns/users/cache", reader).Code)
+
+	// Test a key can not widen a prefix outside of the permission
+	assert.Equal(t, http.StatusForbidden, serve(Read, "GET", "/ns/users/cache?key=user:1&prefix=session:", reader).Code)
+
+	// Test admin grants every operation on every namespace
+	assert.Equal(t, http.StatusOK, serve(Delete, "DELETE", "/cache?key=a", http.Header{API_KEY_HEADER: {"a-key"}}).Code)
+}
+
+func TestMiddleware(t *testing.T) {
+	setup(t, `{"api_keys": [{"name": "writer", "key": "w-key", "permissions": [{"namespace": "*", "operations": ["write"]}]}]}`)
+	require := Require(Write, func(w http.ResponseWriter, r *http.Request) {
+		name, _ := Identify(r)
+		w.Write([]byte(name))
+	})
+	// the credentials are dropped after the middleware so a second
+	// authentication would fail
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Del(API_KEY_HEADER)
+		require(w, r)
+	}))
+
+	// Test Require uses the principal authenticated by the middleware
+	req := httptest.NewRequest("POST", "/cache?key=a", nil)
+	req.Header.Set(API_KEY_HEADER, "w-key")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "writer", rr.Body.String())
+
+	// Test invalid credentials are recorded and refused by Require only
+	req = httptest.NewRequest("POST", "/cache?key=a", nil)
+	req.Header.Set(API_KEY_HEADER, "unknown")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestAllowed(t *testing.T) {
+	// Test every key is allowed without an auth file
+	assert.True(t, Allowed(context.Background(), Write, "users", "session:1"))
+
+	setup(t, `{"api_keys": [{"name": "writer", "key": "w-key", "permissions": [{"namespace": "users", "prefix": "user:", "operations": ["write"]}]}]}`)
+	ctx := context.WithValue(context.Background(), authKey{}, authentication{principal: &Principal{Name: "writer", Permissions: []Permission{
+		{Namespace: "users", Prefix: "user:", Operations: []Operation{Write}},
+	}}})
+
+	// Test keys are checked against the principal of the context
+	assert.True(t, Allowed(ctx, Write, "users", "user:1"))
+	assert.False(t, Allowed(ctx, Write, "users", "session:1"))
+	assert.False(t, Allowed(ctx, Delete, "users", "user:1"))
+
+	// Test requests that were not authenticated are denied
+	assert.False(t, Allowed(context.Background(), Write, "users", "user:1"))
+}
+
+func TestRequireHS256(t *testing.T) {
+	setup(t, `{"jwt": {"secret": "secret", "issuer": "issuer"}}`)
+	sign := func(secret string, c claims) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, c).SignedString([]byte(secret))
+		assert.NoError(t, err)
+		return "Bearer " + token
+	}
+	c := claims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: "svc", Issuer: "issuer", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))},
+		Permissions:      []Permission{{Namespace: "", Operations: []Operation{Read, Write}}},
+	}
+
+	// Test a valid token grants the permissions of its claim
+	rr := serve(Write, "POST", "/cache?key=a", http.Header{"Authorization": {sign("secret", c)}})
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "svc", rr.Body.String())
+	assert.Equal(t, http.StatusForbidden, serve(Delete, "DELETE", "/cache?key=a", http.Header{"Authorization": {sign("secret", c)}}).Code)
+
+	// Test tokens with a bad signature, issuer or expiry are rejected
+	assert.Equal(t, http.StatusUnauthorized, serve(Read, "GET", "/cache?key=a", http.Header{"Authorization": {sign("other", c)}}).Code)
+	wrongIssuer := c
+	wrongIssuer.Issuer = "other"
+	assert.Equal(t, http.StatusUnauthorized, serve(Read, "GET", "/cache?key=a", http.Header{"Authorization": {sign("secret", wrongIssuer)}}).Code)
+	expired := c
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+	rr = serve(Read, "GET", "/cache?key=a", http.Header{"Authorization": {sign("secret", expired)}})
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Equal(t, "invalid credentials\n", rr.Body.String())
+}
+
+func TestRequireRS256(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	jwks, err := json.Marshal(map[string]any{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": "k1",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}})
+	assert.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	assert.NoError(t, os.WriteFile(path, jwks, 0o600))
+	setup(t, `{"jwt": {"jwks_file": "`+path+`", "audience": "go-cache"}}`)
+
+	c := claims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: "svc", Audience: jwt.ClaimStrings{"go-cache"}, ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))},
+		Permissions:      []Permission{{Namespace: "*", Operations: []Operation{Read}}},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, c)
+	token.Header["kid"] = "k1"
+	signed, err := token.SignedString(key)
+	assert.NoError(t, err)
+
+	// Test a token signed by a key of the JWKS file is accepted
+	assert.Equal(t, http.StatusOK, serve(Read, "GET", "/ns/users/cache?key=a", http.Header{"Authorization": {"Bearer " + signed}}).Code)
+
+	// Test a HS256 token is rejected when only RS256 is configured
+	hs, err := jwt.NewWithClaims(jwt.SigningMethodHS256, c).SignedString([]byte("secret"))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, serve(Read, "GET", "/ns/users/cache?key=a", http.Header{"Authorization": {"Bearer " + hs}}).Code)
+}
+
+func TestSetupInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "auth.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"jwt": {}}`), 0o600))
+	assert.ErrorIs(t, Setup(path), ErrorInvalidAuth)
+}
@@ -2,60 +2,394 @@ package cache
 
 import (
 	"bytes"
+	"container/list"
 	"encoding/gob"
 	"errors"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type CacheItem struct {
 	value []byte
-	// ttl   int64
+	// kind is the kind of collection stored in value, empty for JSON objects
+	kind      string
+	version   uint64
+	expiresAt time.Time
+	// staleUntil ends the grace period during which an expired item may
+	// still be served as stale, zero when the item has none
+	staleUntil time.Time
+	storedAt   time.Time
+	tags       []string
 }
 
+// Cache is an isolated keyspace, the default namespace is used by the package
+// level functions and named namespaces are returned by Namespace
 type Cache struct {
+	name string
+
 	mu    sync.RWMutex
 	store map[string]CacheItem
+	// tags indexes the keys written with each tag
+	tags map[string]map[string]struct{}
+	// order lists keys from the least to the most recently written, the
+	// front is evicted first when the namespace is over capacity
+	order *list.List
+	elems map[string]*list.Element
+	// sorted lists the keys in lexical order so scans and key listings seek
+	// to their start instead of sorting the whole store
+	sorted []string
+	bytes  int64
+	// indexes maps index names to the secondary indexes of the namespace
+	indexes map[string]*index
+
+	config    NamespaceConfig
+	evictions atomic.Uint64
+	// hits and misses count the reads served by GetStale
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+const (
+	// EventSet is published when a key is written
+	EventSet = "set"
+
+	// EventDelete is published when a key is removed
+	EventDelete = "delete"
+
+	// EventExpire is published when the janitor removes an expired key
+	EventExpire = "expire"
+
+	// EventEvict is published when a key is removed to respect a namespace limit
+	EventEvict = "evict"
+
+	// EventFlush is published when every key of a namespace is removed
+	EventFlush = "flush"
+)
+
+// Event describes a change applied to the cache
+type Event struct {
+	Op        string         `json:"op"`
+	Namespace string         `json:"ns,omitempty"`
+	Key       string         `json:"key,omitempty"`
+	Value     map[string]any `json:"value,omitempty"`
+	Version   uint64         `json:"version,omitempty"`
+	Time      time.Time      `json:"time"`
 }
 
-var c *Cache = newCache()
+// JANITOR_INTERVAL is the interval at which expired keys are removed
+const JANITOR_INTERVAL = 10 * time.Second
+
+var c *Cache = newCache("")
 var ErrorKeyNotFound = errors.New("key not found")
 
+var subMu sync.Mutex
+var subs = make(map[chan Event]struct{})
+
 func init() {
 	gob.Register(&value{})
+	// nested JSON objects and arrays are stored as interface values
+	gob.Register(map[string]any{})
+	gob.Register([]any{})
 }
 
-func newCache() *Cache {
+func newCache(name string) *Cache {
 	return &Cache{
-		store: make(map[string]CacheItem),
+		name:    name,
+		store:   make(map[string]CacheItem),
+		tags:    make(map[string]map[string]struct{}),
+		order:   list.New(),
+		elems:   make(map[string]*list.Element),
+		indexes: make(map[string]*index),
 	}
 }
 
+// SetOptions controls the expiry and tagging of a written key
+type SetOptions struct {
+	// TTL expires the key after the duration, zero uses the default ttl of
+	// the namespace
+	TTL time.Duration
+	// Stale keeps an expired key for the duration so it can be served stale
+	// while it is refreshed, only applies to keys with a ttl
+	Stale time.Duration
+	// Tags group keys so they can be invalidated together
+	Tags []string
+	// If makes the write conditional on the current state of the key
+	If Precondition
+	// Version forces the version of the written key, used when applying a
+	// write replicated from another worker. Zero assigns a new version.
+	Version uint64
+}
+
 func Set(key string, value map[string]any) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	item, err := newItem(value)
-	if err == nil {
-		c.store[key] = item
-		return nil
-	}
+	return c.Set(key, value)
+}
 
+// SetWithTTL stores value under key, the key expires after ttl unless ttl is zero
+func SetWithTTL(key string, value map[string]any, ttl time.Duration) error {
+	_, err := c.SetWithOptions(key, value, SetOptions{TTL: ttl})
 	return err
 }
 
+// SetWithOptions stores value under key with the given options and returns
+// the version of the written key
+func SetWithOptions(key string, value map[string]any, opts SetOptions) (uint64, error) {
+	return c.SetWithOptions(key, value, opts)
+}
+
 func Get(key string) (map[string]any, error) {
+	return c.Get(key)
+}
+
+func Delete(key string) {
+	c.Delete(key)
+}
+
+// Keys returns the sorted list of keys starting with prefix
+func Keys(prefix string) []string {
+	return c.Keys(prefix)
+}
+
+// Name returns the name of the namespace, empty for the default namespace
+func (c *Cache) Name() string {
+	return c.name
+}
+
+func (c *Cache) Set(key string, value map[string]any) error {
+	_, err := c.SetWithOptions(key, value, SetOptions{})
+	return err
+}
+
+// SetWithOptions stores value under key with the given options and returns
+// the version of the written key. ErrorPreconditionFailed is returned without
+// writing when the precondition does not hold, ErrorKeyTooLong and
+// ErrorValueTooLarge when the write is over the limits and ErrorStaleVersion
+// with the current version when a forced version is not newer than it. Writing a new key to
+// a namespace at capacity evicts the least recently written keys.
+func (c *Cache) SetWithOptions(key string, value map[string]any, opts SetOptions) (uint64, error) {
+	item, err := newItem(value)
+	if err != nil {
+		return 0, err
+	}
+	if err := checkItem(key, item); err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	c.mu.Lock()
+	old, exists := c.store[key]
+	if !opts.If.satisfied(old, exists && !old.expired(now)) {
+		c.mu.Unlock()
+		return 0, ErrorPreconditionFailed
+	}
+	if superseded(old, exists, opts.Version) {
+		c.mu.Unlock()
+		observeVersion(opts.Version)
+		return old.version, ErrorStaleVersion
+	}
+
+	ttl := opts.TTL
+	if ttl == 0 {
+		ttl = c.config.DefaultTTL
+	}
+	if ttl > 0 {
+		item.expiresAt = now.Add(ttl)
+		if opts.Stale > 0 {
+			item.staleUntil = item.expiresAt.Add(opts.Stale)
+		}
+	}
+	item.tags = opts.Tags
+	if item.version = opts.Version; item.version == 0 {
+		item.version = nextVersion()
+	} else {
+		observeVersion(item.version)
+	}
+	c.put(key, item)
+	evicted := c.evict(key)
+	c.mu.Unlock()
+
+	for _, k := range evicted {
+		c.publish(Event{Op: EventEvict, Key: k, Time: now})
+	}
+	c.publish(Event{Op: EventSet, Key: key, Value: value, Version: item.version, Time: now})
+	return item.version, nil
+}
+
+func (c *Cache) Get(key string) (map[string]any, error) {
+	value, _, err := c.GetVersioned(key)
+	return value, err
+}
+
+// GetVersioned returns the value stored under key and its version
+func (c *Cache) GetVersioned(key string) (map[string]any, uint64, error) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 	item, ok := c.store[key]
-	if !ok {
-		return nil, ErrorKeyNotFound
+	if !ok || item.expired(time.Now()) {
+		return nil, 0, ErrorKeyNotFound
+	}
+	if item.kind != "" {
+		return nil, 0, ErrorWrongType
 	}
-	return item.Value(), nil
+	return item.Value(), item.version, nil
 }
 
-func Delete(key string) {
+// Version returns the version of key without decoding its value
+func (c *Cache) Version(key string) (uint64, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	item, ok := c.store[key]
+	if !ok || item.expired(time.Now()) {
+		return 0, ErrorKeyNotFound
+	}
+	return item.version, nil
+}
+
+func (c *Cache) Delete(key string) {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	ok := c.remove(key)
+	c.mu.Unlock()
+
+	if ok {
+		c.publish(Event{Op: EventDelete, Key: key, Time: time.Now()})
+	}
+}
+
+// Keys returns the sorted list of keys starting with prefix
+func (c *Cache) Keys(prefix string) []string {
+	now := time.Now()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var keys []string
+	i, _ := slices.BinarySearch(c.sorted, prefix)
+	for _, k := range c.sorted[i:] {
+		if !strings.HasPrefix(k, prefix) {
+			break
+		}
+		if !c.store[k].expired(now) {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// put stores item under key and keeps the tag index, secondary indexes, write
+// order and size accounting in sync, the caller must hold the write lock
+func (c *Cache) put(key string, item CacheItem) {
+	if old, ok := c.store[key]; ok {
+		c.untag(key, old.tags)
+		c.bytes -= int64(old.Size())
+		c.order.MoveToBack(c.elems[key])
+	} else {
+		c.elems[key] = c.order.PushBack(key)
+		i, _ := slices.BinarySearch(c.sorted, key)
+		c.sorted = slices.Insert(c.sorted, i, key)
+	}
+	item.storedAt = time.Now()
+	c.store[key] = item
+	c.bytes += int64(item.Size())
+	for _, tag := range item.tags {
+		keys, ok := c.tags[tag]
+		if !ok {
+			keys = make(map[string]struct{})
+			c.tags[tag] = keys
+		}
+		keys[key] = struct{}{}
+	}
+	c.reindex(key, item)
+}
+
+// remove deletes key and reports whether it existed, the caller must hold
+// the write lock
+func (c *Cache) remove(key string) bool {
+	item, ok := c.store[key]
+	if !ok {
+		return false
+	}
+	c.untag(key, item.tags)
+	c.unindex(key)
+	c.bytes -= int64(item.Size())
+	c.order.Remove(c.elems[key])
+	delete(c.elems, key)
+	if i, ok := slices.BinarySearch(c.sorted, key); ok {
+		c.sorted = slices.Delete(c.sorted, i, i+1)
+	}
 	delete(c.store, key)
+	return true
+}
+
+func (c *Cache) untag(key string, tags []string) {
+	for _, tag := range tags {
+		delete(c.tags[tag], key)
+		if len(c.tags[tag]) == 0 {
+			delete(c.tags, tag)
+		}
+	}
+}
+
+// RunJanitor periodically removes expired keys from every namespace once
+// their grace period is over. Expired keys are never returned by reads other
+// than GetStale, the janitor only reclaims their memory.
+func RunJanitor() {
+	go func() {
+		for {
+			time.Sleep(JANITOR_INTERVAL)
+			now := time.Now()
+			for _, ns := range namespaces() {
+				ns.removeExpired(now)
+			}
+		}
+	}()
+}
+
+func (c *Cache) removeExpired(now time.Time) {
+	var expired []string
+	c.mu.Lock()
+	for k, item := range c.store {
+		if item.dead(now) {
+			c.remove(k)
+			expired = append(expired, k)
+		}
+	}
+	c.mu.Unlock()
+
+	for _, k := range expired {
+		c.publish(Event{Op: EventExpire, Key: k, Time: now})
+	}
+}
+
+// Subscribe registers a listener for events of every namespace. Events are
+// dropped for subscribers that do not keep up with the buffer; call the
+// returned function to unsubscribe.
+func Subscribe(buffer int) (<-chan Event, func()) {
+	ch := make(chan Event, buffer)
+	subMu.Lock()
+	subs[ch] = struct{}{}
+	subMu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			subMu.Lock()
+			delete(subs, ch)
+			subMu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+func (c *Cache) publish(e Event) {
+	e.Namespace = c.name
+	subMu.Lock()
+	defer subMu.Unlock()
+	for ch := range subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
 }
 
 type value map[string]any
@@ -74,12 +408,35 @@ func (v value) unmarshall(b []byte) error {
 	return gob.NewDecoder(r).Decode(&v)
 }
 
+// Value decodes the stored JSON object, collections have no object value and
+// return nil
 func (c CacheItem) Value() map[string]any {
+	if c.kind != "" {
+		return nil
+	}
 	v := value{}
 	v.unmarshall(c.value)
 	return v
 }
 
+// expired reports whether the item has a ttl that elapsed before now
+func (c CacheItem) expired(now time.Time) bool {
+	return !c.expiresAt.IsZero() && !now.Before(c.expiresAt)
+}
+
+// TTL returns the remaining time to live, zero means the item does not expire
+func (c CacheItem) TTL(now time.Time) time.Duration {
+	if c.expiresAt.IsZero() {
+		return 0
+	}
+	return c.expiresAt.Sub(now)
+}
+
+// Size returns the number of bytes used to store the value
+func (c CacheItem) Size() int {
+	return len(c.value)
+}
+
 func newItem(value value) (CacheItem, error) {
 	b, err := value.marshall()
 	if err == nil {
@@ -89,3 +446,9 @@ func newItem(value value) (CacheItem, error) {
 	}
 	return CacheItem{}, err
 }
+
+// Entry is a key and its value as exported by a dump of the cache
+type Entry struct {
+	Key   string         `json:"key"`
+	Value map[string]any `json:"value"`
+}
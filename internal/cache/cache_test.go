@@ -2,6 +2,7 @@ package cache
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -43,3 +44,56 @@ func TestDelete(t *testing.T) {
 	_, err = Get(key)
 	assert.Equal(t, ErrorKeyNotFound, err, "Expected error for deleted key")
 }
+
+func TestKeys(t *testing.T) {
+	// Set up keys with and without the prefix
+	Set("keys:b", map[string]any{"field1": "value1"})
+	Set("keys:a", map[string]any{"field1": "value1"})
+	Set("other:a", map[string]any{"field1": "value1"})
+
+	// Test Keys returns only prefixed keys in sorted order
+	assert.Equal(t, []string{"keys:a", "keys:b"}, Keys("keys:"))
+}
+
+func TestSubscribe(t *testing.T) {
+	events, unsubscribe := Subscribe(2)
+
+	// Test Set and Delete publish events
+	Set("subscribeKey", map[string]any{"field1": "value1"})
+	Delete("subscribeKey")
+
+	e := <-events
+	assert.Equal(t, EventSet, e.Op)
+	assert.Equal(t, "subscribeKey", e.Key)
+	assert.Equal(t, map[string]any{"field1": "value1"}, e.Value)
+
+	e = <-events
+	assert.Equal(t, EventDelete, e.Op)
+	assert.Equal(t, "subscribeKey", e.Key)
+
+	// Test the channel is closed after unsubscribing
+	unsubscribe()
+	_, ok := <-events
+	assert.False(t, ok, "Expected channel to be closed")
+}
+
+func TestSetWithTTL(t *testing.T) {
+	key := "testKeyWithTTL"
+	value := map[string]any{"field1": "value1"}
+
+	// Test Set with a ttl that has already elapsed
+	err := SetWithTTL(key, value, time.Nanosecond)
+	assert.Nil(t, err, "Expected no error on SetWithTTL")
+	time.Sleep(time.Millisecond)
+
+	// Test Get of an expired key
+	_, err = Get(key)
+	assert.Equal(t, ErrorKeyNotFound, err, "Expected error for expired key")
+
+	// Test the janitor removes the expired key
+	c.removeExpired(time.Now())
+	c.mu.RLock()
+	_, ok := c.store[key]
+	c.mu.RUnlock()
+	assert.False(t, ok, "Expected expired key to be removed")
+}
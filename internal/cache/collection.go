@@ -0,0 +1,428 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	// KindHash is a collection of named fields holding JSON values
+	KindHash = "hash"
+
+	// KindList is an ordered collection of JSON values
+	KindList = "list"
+
+	// KindSet is an unordered collection of unique string members
+	KindSet = "set"
+
+	// KindZSet is a collection of unique string members ordered by score
+	KindZSet = "zset"
+)
+
+// Collection write operations, named after the Redis commands they mirror
+const (
+	OpHSet  = "hset"
+	OpHDel  = "hdel"
+	OpLPush = "lpush"
+	OpRPush = "rpush"
+	OpLPop  = "lpop"
+	OpRPop  = "rpop"
+	OpSAdd  = "sadd"
+	OpSRem  = "srem"
+	OpZAdd  = "zadd"
+	OpZRem  = "zrem"
+)
+
+// CollectionOps lists every collection write operation
+var CollectionOps = []string{OpHSet, OpHDel, OpLPush, OpRPush, OpLPop, OpRPop, OpSAdd, OpSRem, OpZAdd, OpZRem}
+
+var ErrorWrongType = errors.New("operation against a key holding the wrong kind of value")
+var ErrorInvalidOperation = errors.New("invalid collection operation")
+
+// CollectionOp is an atomic write to a collection, it is replicated to the
+// pool as is so concurrent writes on different workers are all applied
+type CollectionOp struct {
+	Op string `json:"op"`
+	// Fields are the hash fields written by hset
+	Fields map[string]any `json:"fields,omitempty"`
+	// Values are the list values pushed by lpush and rpush, lpush inserts
+	// them one after the other at the head like Redis
+	Values []any `json:"values,omitempty"`
+	// Members are the set or sorted set members removed by srem and zrem,
+	// added by sadd, or the hash fields removed by hdel
+	Members []string `json:"members,omitempty"`
+	// Scores are the sorted set members and scores written by zadd
+	Scores map[string]float64 `json:"scores,omitempty"`
+	// Count is the number of values popped by lpop and rpop, default 1
+	Count int `json:"count,omitempty"`
+}
+
+// CollectionOptions controls the expiry and version of a collection write
+type CollectionOptions struct {
+	// TTL expires the collection when the write creates it, the expiry of
+	// an existing collection is kept
+	TTL time.Duration
+	// Version forces the version of the updated key, used when applying a
+	// write replicated from another worker
+	Version uint64
+}
+
+// CollectionResult is the outcome of a collection write
+type CollectionResult struct {
+	// Count is the number of fields or members added or removed, or the
+	// length of the list after a push
+	Count int
+	// Values are the values popped from a list
+	Values []any
+}
+
+// ZMember is a member of a sorted set and its score
+type ZMember struct {
+	Member string  `json:"member"`
+	Score  float64 `json:"score"`
+}
+
+// collection is the stored form of every kind of collection, only the field
+// matching the kind of the item is set
+type collection struct {
+	Hash map[string]any
+	List []any
+	Set  map[string]bool
+	ZSet map[string]float64
+}
+
+func (c *collection) marshall() ([]byte, error) {
+	w := bytes.Buffer{}
+	if err := gob.NewEncoder(&w).Encode(c); err != nil {
+		return nil, err
+	}
+	return w.Bytes(), nil
+}
+
+func (c *collection) unmarshall(b []byte) error {
+	return gob.NewDecoder(bytes.NewReader(b)).Decode(c)
+}
+
+func (c *collection) size(kind string) int {
+	switch kind {
+	case KindHash:
+		return len(c.Hash)
+	case KindList:
+		return len(c.List)
+	case KindSet:
+		return len(c.Set)
+	case KindZSet:
+		return len(c.ZSet)
+	}
+	return 0
+}
+
+// opKind returns the kind of collection an operation applies to
+func opKind(op string) (string, bool) {
+	switch op {
+	case OpHSet, OpHDel:
+		return KindHash, true
+	case OpLPush, OpRPush, OpLPop, OpRPop:
+		return KindList, true
+	case OpSAdd, OpSRem:
+		return KindSet, true
+	case OpZAdd, OpZRem:
+		return KindZSet, true
+	}
+	return "", false
+}
+
+// Apply atomically applies a write operation to the collection stored under
+// key, creating it when missing. A collection left empty is deleted.
+// ErrorWrongType is returned when key holds another kind of value and
+// ErrorValueTooLarge when the collection would grow over the limit.
+func (c *Cache) Apply(key string, op CollectionOp, opts CollectionOptions) (CollectionResult, uint64, error) {
+	kind, ok := opKind(op.Op)
+	if !ok {
+		return CollectionResult{}, 0, fmt.Errorf("%w: unknown op %q", ErrorInvalidOperation, op.Op)
+	}
+	if err := checkKey(key); err != nil {
+		return CollectionResult{}, 0, err
+	}
+
+	now := time.Now()
+	c.mu.Lock()
+	old, exists := c.store[key]
+	exists = exists && !old.expired(now)
+	if exists && old.kind != kind {
+		c.mu.Unlock()
+		return CollectionResult{}, 0, ErrorWrongType
+	}
+
+	var coll collection
+	if exists {
+		if err := coll.unmarshall(old.value); err != nil {
+			c.mu.Unlock()
+			return CollectionResult{}, 0, err
+		}
+	}
+	result, changed, err := coll.apply(op)
+	if err != nil || !changed {
+		var version uint64
+		if exists {
+			version = old.version
+		}
+		c.mu.Unlock()
+		return result, version, err
+	}
+
+	if coll.size(kind) == 0 {
+		c.remove(key)
+		c.mu.Unlock()
+		c.publish(Event{Op: EventDelete, Key: key, Time: now})
+		return result, 0, nil
+	}
+
+	b, err := coll.marshall()
+	if err != nil {
+		c.mu.Unlock()
+		return CollectionResult{}, 0, err
+	}
+	item := CacheItem{value: b, kind: kind}
+	// a collection grown over the limit is left as it was
+	if err := checkSize(item); err != nil {
+		c.mu.Unlock()
+		return CollectionResult{}, 0, err
+	}
+	if exists {
+		item.expiresAt = old.expiresAt
+		item.staleUntil = old.staleUntil
+		item.tags = old.tags
+	} else {
+		ttl := opts.TTL
+		if ttl == 0 {
+			ttl = c.config.DefaultTTL
+		}
+		if ttl > 0 {
+			item.expiresAt = now.Add(ttl)
+		}
+	}
+	if item.version = opts.Version; item.version == 0 {
+		item.version = nextVersion()
+	} else {
+		observeVersion(item.version)
+		if exists && old.version > item.version {
+			item.version = old.version
+		}
+	}
+	c.put(key, item)
+	evicted := c.evict(key)
+	c.mu.Unlock()
+
+	for _, k := range evicted {
+		c.publish(Event{Op: EventEvict, Key: k, Time: now})
+	}
+	c.publish(Event{Op: EventSet, Key: key, Version: item.version, Time: now})
+	return result, item.version, nil
+}
+
+// apply applies op and reports whether the collection changed
+func (c *collection) apply(op CollectionOp) (CollectionResult, bool, error) {
+	var result CollectionResult
+	switch op.Op {
+	case OpHSet:
+		if c.Hash == nil {
+			c.Hash = make(map[string]any)
+		}
+		for field, v := range op.Fields {
+			if _, ok := c.Hash[field]; !ok {
+				result.Count++
+			}
+			c.Hash[field] = v
+		}
+		return result, len(op.Fields) > 0, nil
+	case OpHDel:
+		for _, field := range op.Members {
+			if _, ok := c.Hash[field]; ok {
+				delete(c.Hash, field)
+				result.Count++
+			}
+		}
+	case OpLPush:
+		list := make([]any, len(op.Values), len(op.Values)+len(c.List))
+		for i, v := range op.Values {
+			list[len(op.Values)-1-i] = v
+		}
+		c.List = append(list, c.List...)
+		result.Count = len(c.List)
+		return result, len(op.Values) > 0, nil
+	case OpRPush:
+		c.List = append(c.List, op.Values...)
+		result.Count = len(c.List)
+		return result, len(op.Values) > 0, nil
+	case OpLPop, OpRPop:
+		count := op.Count
+		if count < 0 {
+			return result, false, fmt.Errorf("%w: count must not be negative", ErrorInvalidOperation)
+		}
+		if count == 0 {
+			count = 1
+		}
+		count = min(count, len(c.List))
+		result.Values = make([]any, 0, count)
+		for i := 0; i < count; i++ {
+			if op.Op == OpLPop {
+				result.Values = append(result.Values, c.List[0])
+				c.List = c.List[1:]
+			} else {
+				result.Values = append(result.Values, c.List[len(c.List)-1])
+				c.List = c.List[:len(c.List)-1]
+			}
+		}
+		result.Count = len(result.Values)
+	case OpSAdd:
+		if c.Set == nil {
+			c.Set = make(map[string]bool)
+		}
+		for _, m := range op.Members {
+			if !c.Set[m] {
+				c.Set[m] = true
+				result.Count++
+			}
+		}
+	case OpSRem:
+		for _, m := range op.Members {
+			if c.Set[m] {
+				delete(c.Set, m)
+				result.Count++
+			}
+		}
+	case OpZAdd:
+		if c.ZSet == nil {
+			c.ZSet = make(map[string]float64)
+		}
+		for m, score := range op.Scores {
+			if _, ok := c.ZSet[m]; !ok {
+				result.Count++
+			}
+			c.ZSet[m] = score
+		}
+		return result, len(op.Scores) > 0, nil
+	case OpZRem:
+		for _, m := range op.Members {
+			if _, ok := c.ZSet[m]; ok {
+				delete(c.ZSet, m)
+				result.Count++
+			}
+		}
+	}
+	return result, result.Count > 0, nil
+}
+
+// collection returns the decoded collection stored under key, the caller must
+// hold the read lock
+func (c *Cache) collection(key, kind string) (collection, error) {
+	var coll collection
+	item, ok := c.store[key]
+	if !ok || item.expired(time.Now()) {
+		return coll, ErrorKeyNotFound
+	}
+	if item.kind != kind {
+		return coll, ErrorWrongType
+	}
+	err := coll.unmarshall(item.value)
+	return coll, err
+}
+
+// HGet returns the given fields of a hash, every field when none is given.
+// Missing fields are left out of the result.
+func (c *Cache) HGet(key string, fields ...string) (map[string]any, error) {
+	c.mu.RLock()
+	coll, err := c.collection(key, KindHash)
+	c.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return coll.Hash, nil
+	}
+	result := make(map[string]any, len(fields))
+	for _, field := range fields {
+		if v, ok := coll.Hash[field]; ok {
+			result[field] = v
+		}
+	}
+	return result, nil
+}
+
+// LRange returns the values of a list between the start and stop positions
+// inclusive, negative positions count from the end of the list
+func (c *Cache) LRange(key string, start, stop int) ([]any, error) {
+	c.mu.RLock()
+	coll, err := c.collection(key, KindList)
+	c.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	from, to := rangeBounds(start, stop, len(coll.List))
+	return append([]any{}, coll.List[from:to]...), nil
+}
+
+// SMembers returns the sorted members of a set
+func (c *Cache) SMembers(key string) ([]string, error) {
+	c.mu.RLock()
+	coll, err := c.collection(key, KindSet)
+	c.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	members := make([]string, 0, len(coll.Set))
+	for m := range coll.Set {
+		members = append(members, m)
+	}
+	sort.Strings(members)
+	return members, nil
+}
+
+// ZRange returns the members of a sorted set between the start and stop ranks
+// inclusive, ordered by score then member, from the highest score when
+// reverse is set. Negative ranks count from the end.
+func (c *Cache) ZRange(key string, start, stop int, reverse bool) ([]ZMember, error) {
+	c.mu.RLock()
+	coll, err := c.collection(key, KindZSet)
+	c.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	members := make([]ZMember, 0, len(coll.ZSet))
+	for m, score := range coll.ZSet {
+		members = append(members, ZMember{Member: m, Score: score})
+	}
+	sort.Slice(members, func(i, j int) bool {
+		a, b := members[i], members[j]
+		if reverse {
+			a, b = b, a
+		}
+		if a.Score != b.Score {
+			return a.Score < b.Score
+		}
+		return a.Member < b.Member
+	})
+	from, to := rangeBounds(start, stop, len(members))
+	return members[from:to], nil
+}
+
+// rangeBounds converts inclusive Redis style positions into slice bounds
+func rangeBounds(start, stop, length int) (int, int) {
+	if start < 0 {
+		start += length
+	}
+	if stop < 0 {
+		stop += length
+	}
+	start = max(start, 0)
+	stop = min(stop, length-1)
+	if start > stop {
+		return 0, 0
+	}
+	return start, stop + 1
+}
@@ -0,0 +1,149 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHash(t *testing.T) {
+	ns := newCache("hash")
+
+	// Test HSET counts the new fields
+	result, _, err := ns.Apply("user", CollectionOp{Op: OpHSet, Fields: map[string]any{"name": "a", "age": float64(30)}}, CollectionOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.Count)
+	result, _, err = ns.Apply("user", CollectionOp{Op: OpHSet, Fields: map[string]any{"age": float64(31)}}, CollectionOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, result.Count)
+
+	// Test HGET of some and every field
+	fields, err := ns.HGet("user", "age", "missing")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"age": float64(31)}, fields)
+	fields, err = ns.HGet("user")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"name": "a", "age": float64(31)}, fields)
+
+	// Test removing every field deletes the key
+	result, _, err = ns.Apply("user", CollectionOp{Op: OpHDel, Members: []string{"name", "age"}}, CollectionOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.Count)
+	_, err = ns.HGet("user")
+	assert.Equal(t, ErrorKeyNotFound, err)
+}
+
+func TestList(t *testing.T) {
+	ns := newCache("list")
+
+	// Test LPUSH inserts each value at the head and RPUSH at the tail
+	_, _, err := ns.Apply("queue", CollectionOp{Op: OpLPush, Values: []any{"b", "a"}}, CollectionOptions{})
+	assert.NoError(t, err)
+	result, _, err := ns.Apply("queue", CollectionOp{Op: OpRPush, Values: []any{"c"}}, CollectionOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, result.Count)
+
+	values, err := ns.LRange("queue", 0, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, []any{"a", "b", "c"}, values)
+	values, err = ns.LRange("queue", -2, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []any{"b", "c"}, values)
+
+	// Test RPOP and LPOP
+	result, _, err = ns.Apply("queue", CollectionOp{Op: OpRPop, Count: 2}, CollectionOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []any{"c", "b"}, result.Values)
+	result, _, err = ns.Apply("queue", CollectionOp{Op: OpLPop, Count: 5}, CollectionOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []any{"a"}, result.Values)
+
+	// Test popping an empty list
+	result, _, err = ns.Apply("queue", CollectionOp{Op: OpRPop}, CollectionOptions{})
+	assert.NoError(t, err)
+	assert.Empty(t, result.Values)
+}
+
+func TestSet(t *testing.T) {
+	ns := newCache("set")
+
+	result, _, err := ns.Apply("tags", CollectionOp{Op: OpSAdd, Members: []string{"b", "a", "b"}}, CollectionOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.Count)
+
+	members, err := ns.SMembers("tags")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, members)
+
+	result, _, err = ns.Apply("tags", CollectionOp{Op: OpSRem, Members: []string{"a", "c"}}, CollectionOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Count)
+}
+
+func TestZSet(t *testing.T) {
+	ns := newCache("zset")
+
+	_, _, err := ns.Apply("board", CollectionOp{Op: OpZAdd, Scores: map[string]float64{"a": 3, "b": 1, "c": 2, "d": 2}}, CollectionOptions{})
+	assert.NoError(t, err)
+
+	// Test members are ordered by score then member
+	members, err := ns.ZRange("board", 0, -1, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []ZMember{{"b", 1}, {"c", 2}, {"d", 2}, {"a", 3}}, members)
+
+	// Test the top two in reverse order
+	members, err = ns.ZRange("board", 0, 1, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []ZMember{{"a", 3}, {"d", 2}}, members)
+
+	result, _, err := ns.Apply("board", CollectionOp{Op: OpZRem, Members: []string{"a"}}, CollectionOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Count)
+}
+
+func TestCollectionWrongType(t *testing.T) {
+	ns := newCache("wrongType")
+	assert.NoError(t, ns.Set("object", map[string]any{"a": "b"}))
+	_, _, err := ns.Apply("list", CollectionOp{Op: OpRPush, Values: []any{1}}, CollectionOptions{})
+	assert.NoError(t, err)
+
+	// Test collection operations on an object and object reads of a collection
+	_, _, err = ns.Apply("object", CollectionOp{Op: OpSAdd, Members: []string{"a"}}, CollectionOptions{})
+	assert.Equal(t, ErrorWrongType, err)
+	_, _, err = ns.Apply("list", CollectionOp{Op: OpSAdd, Members: []string{"a"}}, CollectionOptions{})
+	assert.Equal(t, ErrorWrongType, err)
+	_, err = ns.Get("list")
+	assert.Equal(t, ErrorWrongType, err)
+	_, _, err = ns.IncrInt("list", 1, IncrOptions{})
+	assert.Equal(t, ErrorWrongType, err)
+
+	// Test an unknown operation
+	_, _, err = ns.Apply("list", CollectionOp{Op: "lset"}, CollectionOptions{})
+	assert.ErrorIs(t, err, ErrorInvalidOperation)
+
+	// Test a write replaces a collection
+	assert.NoError(t, ns.Set("list", map[string]any{"a": "b"}))
+	_, err = ns.Get("list")
+	assert.NoError(t, err)
+}
+
+func TestCollectionTTLAndVersion(t *testing.T) {
+	ns := newCache("collectionTTL")
+
+	// Test the ttl applies when the collection is created
+	_, v1, err := ns.Apply("set", CollectionOp{Op: OpSAdd, Members: []string{"a"}}, CollectionOptions{TTL: 50 * time.Millisecond})
+	assert.NoError(t, err)
+	_, v2, err := ns.Apply("set", CollectionOp{Op: OpSAdd, Members: []string{"b"}}, CollectionOptions{TTL: time.Hour})
+	assert.NoError(t, err)
+	assert.Greater(t, v2, v1)
+
+	// Test a write that changes nothing keeps the version
+	_, v3, err := ns.Apply("set", CollectionOp{Op: OpSAdd, Members: []string{"b"}}, CollectionOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, v2, v3)
+
+	time.Sleep(60 * time.Millisecond)
+	_, err = ns.SMembers("set")
+	assert.Equal(t, ErrorKeyNotFound, err)
+}
@@ -0,0 +1,210 @@
+package cache
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// DEFAULT_COUNTER_FIELD is the field of the stored object holding a counter
+const DEFAULT_COUNTER_FIELD = "value"
+
+var ErrorNotCounter = errors.New("value is not a number")
+var ErrorOutOfRange = errors.New("counter out of range")
+
+// IncrOptions controls an atomic increment
+type IncrOptions struct {
+	// Field is the field of the stored object holding the counter, defaults
+	// to DEFAULT_COUNTER_FIELD
+	Field string
+	// TTL expires the counter when the increment creates it, the expiry of
+	// an existing counter is kept
+	TTL time.Duration
+	// Min and Max reject increments that would take the counter out of bounds
+	Min *float64
+	Max *float64
+	// Version forces the version of the updated key, used when applying an
+	// increment replicated from another worker. A newer local version is kept
+	// since replicated increments may arrive in any order.
+	Version uint64
+}
+
+// IncrInt atomically adds by to an integer counter, creating it at zero when
+// the key or field is missing, and returns the new value and version
+func (c *Cache) IncrInt(key string, by int64, opts IncrOptions) (int64, uint64, error) {
+	var result int64
+	version, err := c.incr(key, opts, func(current any, exists bool) (any, error) {
+		var n int64
+		if exists {
+			var ok bool
+			if n, ok = toInt(current); !ok {
+				return nil, ErrorNotCounter
+			}
+		}
+		var err error
+		result, err = addInt(n, by)
+		return result, err
+	})
+	return result, version, err
+}
+
+// IncrFloat atomically adds by to a counter stored as a float, creating it at
+// zero when the key or field is missing, and returns the new value and version
+func (c *Cache) IncrFloat(key string, by float64, opts IncrOptions) (float64, uint64, error) {
+	var result float64
+	version, err := c.incr(key, opts, func(current any, exists bool) (any, error) {
+		var n float64
+		if exists {
+			var ok bool
+			if n, ok = toFloat(current); !ok {
+				return nil, ErrorNotCounter
+			}
+		}
+		var err error
+		result, err = addFloat(n, by)
+		return result, err
+	})
+	return result, version, err
+}
+
+// Incr atomically adds the integer by to a counter like IncrInt while it holds
+// an integer, and like IncrFloat once it holds a fraction. It returns the new
+// value, an int64 or a float64, and version.
+func (c *Cache) Incr(key string, by int64, opts IncrOptions) (any, uint64, error) {
+	var result any
+	version, err := c.incr(key, opts, func(current any, exists bool) (any, error) {
+		var err error
+		if !exists {
+			result, err = addInt(0, by)
+		} else if n, ok := toInt(current); ok {
+			result, err = addInt(n, by)
+		} else if f, ok := toFloat(current); ok {
+			result, err = addFloat(f, float64(by))
+		} else {
+			err = ErrorNotCounter
+		}
+		return result, err
+	})
+	return result, version, err
+}
+
+// addInt adds by to n, failing with ErrorOutOfRange on overflow
+func addInt(n, by int64) (int64, error) {
+	if (by > 0 && n > math.MaxInt64-by) || (by < 0 && n < math.MinInt64-by) {
+		return 0, ErrorOutOfRange
+	}
+	return n + by, nil
+}
+
+// addFloat adds by to n, failing with ErrorOutOfRange when the sum is not finite
+func addFloat(n, by float64) (float64, error) {
+	result := n + by
+	if math.IsInf(result, 0) || math.IsNaN(result) {
+		return 0, ErrorOutOfRange
+	}
+	return result, nil
+}
+
+// incr applies update to the counter field under the write lock, keeping the
+// expiry and tags of an existing key
+func (c *Cache) incr(key string, opts IncrOptions, update func(current any, exists bool) (any, error)) (uint64, error) {
+	field := opts.Field
+	if field == "" {
+		field = DEFAULT_COUNTER_FIELD
+	}
+	if err := checkKey(key); err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	c.mu.Lock()
+	old, exists := c.store[key]
+	exists = exists && !old.expired(now)
+	if exists && old.kind != "" {
+		c.mu.Unlock()
+		return 0, ErrorWrongType
+	}
+
+	value := map[string]any{}
+	if exists {
+		value = old.Value()
+	}
+	current, ok := value[field]
+	next, err := update(current, ok)
+	if err == nil && !inBounds(next, opts.Min, opts.Max) {
+		err = ErrorOutOfRange
+	}
+	if err != nil {
+		c.mu.Unlock()
+		return 0, err
+	}
+	value[field] = next
+
+	item, err := newItem(value)
+	if err != nil {
+		c.mu.Unlock()
+		return 0, err
+	}
+	if exists {
+		item.expiresAt = old.expiresAt
+		item.staleUntil = old.staleUntil
+		item.tags = old.tags
+	} else {
+		ttl := opts.TTL
+		if ttl == 0 {
+			ttl = c.config.DefaultTTL
+		}
+		if ttl > 0 {
+			item.expiresAt = now.Add(ttl)
+		}
+	}
+	if item.version = opts.Version; item.version == 0 {
+		item.version = nextVersion()
+	} else {
+		observeVersion(item.version)
+		if exists && old.version > item.version {
+			item.version = old.version
+		}
+	}
+	c.put(key, item)
+	evicted := c.evict(key)
+	c.mu.Unlock()
+
+	for _, k := range evicted {
+		c.publish(Event{Op: EventEvict, Key: k, Time: now})
+	}
+	c.publish(Event{Op: EventSet, Key: key, Value: value, Version: item.version, Time: now})
+	return item.version, nil
+}
+
+func inBounds(n any, lower, upper *float64) bool {
+	f, _ := toFloat(n)
+	return (lower == nil || f >= *lower) && (upper == nil || f <= *upper)
+}
+
+// toInt converts integers and integral floats, as decoded from JSON, to int64
+func toInt(v any) (int64, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int64:
+		return n, true
+	case float64:
+		if n == math.Trunc(n) && n >= math.MinInt64 && n < math.MaxInt64 {
+			return int64(n), true
+		}
+	}
+	return 0, false
+}
+
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
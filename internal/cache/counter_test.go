@@ -0,0 +1,144 @@
+package cache
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIncrInt(t *testing.T) {
+	ns := newCache("incrInt")
+
+	// Test the counter is created at zero
+	n, v1, err := ns.IncrInt("counter", 5, IncrOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), n)
+
+	// Test decrementing returns the new value and version
+	n, v2, err := ns.IncrInt("counter", -2, IncrOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+	assert.Greater(t, v2, v1)
+
+	value, err := ns.Get("counter")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"value": int64(3)}, value)
+}
+
+func TestIncrConcurrent(t *testing.T) {
+	ns := newCache("incrConcurrent")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ns.IncrInt("counter", 1, IncrOptions{})
+		}()
+	}
+	wg.Wait()
+
+	// Check no increment was lost
+	n, _, err := ns.IncrInt("counter", 0, IncrOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), n)
+}
+
+func TestIncrFloat(t *testing.T) {
+	ns := newCache("incrFloat")
+
+	// Test a float increment of a JSON decoded integer
+	assert.NoError(t, ns.Set("counter", map[string]any{"value": float64(1), "name": "score"}))
+	f, _, err := ns.IncrFloat("counter", 0.5, IncrOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1.5, f)
+
+	// Test an integer increment of a fractional value is rejected
+	_, _, err = ns.IncrInt("counter", 1, IncrOptions{})
+	assert.Equal(t, ErrorNotCounter, err)
+
+	// Test a non numeric field is rejected
+	_, _, err = ns.IncrFloat("counter", 1, IncrOptions{Field: "name"})
+	assert.Equal(t, ErrorNotCounter, err)
+
+	// Check the other fields are kept
+	value, err := ns.Get("counter")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"value": 1.5, "name": "score"}, value)
+}
+
+func TestIncr(t *testing.T) {
+	ns := newCache("incr")
+
+	// Test an integer counter keeps integer arithmetic
+	n, _, err := ns.Incr("counter", 2, IncrOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+
+	// Test an integer increment of a fractional counter adds to the fraction
+	_, _, err = ns.IncrFloat("counter", 0.5, IncrOptions{})
+	assert.NoError(t, err)
+	n, _, err = ns.Incr("counter", 1, IncrOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 3.5, n)
+	n, _, err = ns.Incr("counter", -1, IncrOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 2.5, n)
+
+	// Test a non numeric field is rejected
+	assert.NoError(t, ns.Set("name", map[string]any{"value": "score"}))
+	_, _, err = ns.Incr("name", 1, IncrOptions{})
+	assert.Equal(t, ErrorNotCounter, err)
+}
+
+func TestIncrBounds(t *testing.T) {
+	ns := newCache("incrBounds")
+	lower, upper := float64(0), float64(2)
+	opts := IncrOptions{Min: &lower, Max: &upper}
+
+	for i := 0; i < 2; i++ {
+		_, _, err := ns.IncrInt("quota", 1, opts)
+		assert.NoError(t, err)
+	}
+
+	// Test an increment over the maximum is rejected and not applied
+	_, _, err := ns.IncrInt("quota", 1, opts)
+	assert.Equal(t, ErrorOutOfRange, err)
+
+	// Test a decrement under the minimum is rejected
+	_, _, err = ns.IncrInt("quota", -3, opts)
+	assert.Equal(t, ErrorOutOfRange, err)
+
+	n, _, err := ns.IncrInt("quota", 0, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+}
+
+func TestIncrTTL(t *testing.T) {
+	ns := newCache("incrTTL")
+
+	// Test the ttl applies when the counter is created
+	_, _, err := ns.IncrInt("counter", 1, IncrOptions{TTL: 50 * time.Millisecond})
+	assert.NoError(t, err)
+
+	// Test the ttl of an existing counter is kept
+	_, _, err = ns.IncrInt("counter", 1, IncrOptions{TTL: time.Hour})
+	assert.NoError(t, err)
+
+	time.Sleep(60 * time.Millisecond)
+	_, err = ns.Get("counter")
+	assert.Equal(t, ErrorKeyNotFound, err)
+}
+
+func TestIncrReplicatedVersion(t *testing.T) {
+	ns := newCache("incrVersion")
+
+	// Test replicated increments arriving out of order keep the newest version
+	_, _, err := ns.IncrInt("counter", 1, IncrOptions{Version: 20})
+	assert.NoError(t, err)
+	_, v, err := ns.IncrInt("counter", 1, IncrOptions{Version: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(20), v)
+}
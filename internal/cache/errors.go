@@ -0,0 +1,43 @@
+package cache
+
+import "errors"
+
+// codes are the stable codes of the errors returned by the cache, reported to
+// clients so they do not match on messages
+var codes = []struct {
+	err  error
+	code string
+}{
+	{ErrorKeyNotFound, "key_not_found"},
+	{ErrorPreconditionFailed, "precondition_failed"},
+	{ErrorStaleVersion, "stale_version"},
+	{ErrorWrongType, "wrong_type"},
+	{ErrorInvalidOperation, "invalid_operation"},
+	{ErrorNotCounter, "not_counter"},
+	{ErrorOutOfRange, "out_of_range"},
+	{ErrorInvalidIndex, "invalid_index"},
+	{ErrorIndexNotFound, "index_not_found"},
+	{ErrorEmptySelector, "empty_selector"},
+	{ErrorInvalidNamespace, "invalid_namespace"},
+	{ErrorNamespaceNotFound, "namespace_not_found"},
+	{ErrorInvalidPatch, "invalid_patch"},
+	{ErrorPatchTestFailed, "patch_test_failed"},
+	{ErrorInvalidPath, "invalid_path"},
+	{ErrorInvalidCursor, "invalid_cursor"},
+	{ErrorInvalidPattern, "invalid_pattern"},
+	{ErrorInvalidTxn, "invalid_txn"},
+	{ErrorKeyTooLong, "key_too_long"},
+	{ErrorValueTooLarge, "value_too_large"},
+	{ErrorBatchTooLarge, "batch_too_large"},
+}
+
+// Code returns the stable code of an error of the cache, internal for the
+// errors the cache does not define
+func Code(err error) string {
+	for _, c := range codes {
+		if errors.Is(err, c.err) {
+			return c.code
+		}
+	}
+	return "internal"
+}
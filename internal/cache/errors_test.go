@@ -0,0 +1,26 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCode(t *testing.T) {
+	// Test wrapped errors of the cache have their code
+	assert.Equal(t, "key_not_found", Code(ErrorKeyNotFound))
+	assert.Equal(t, "precondition_failed", Code(fmt.Errorf("%w: %q", ErrorPreconditionFailed, "a")))
+	assert.Equal(t, "value_too_large", Code(fmt.Errorf("%w: 2 bytes", ErrorValueTooLarge)))
+
+	// Test other errors are internal
+	assert.Equal(t, "internal", Code(errors.New("boom")))
+
+	// Test every code is unique
+	seen := make(map[string]bool)
+	for _, c := range codes {
+		assert.False(t, seen[c.code], c.code)
+		seen[c.code] = true
+	}
+}
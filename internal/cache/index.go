@@ -0,0 +1,183 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+var ErrorInvalidIndex = errors.New("invalid index")
+var ErrorIndexNotFound = errors.New("index not found")
+
+// IndexInfo describes a secondary index of a namespace
+type IndexInfo struct {
+	Name  string `json:"name"`
+	Field string `json:"field"`
+	// Keys is the number of keys with an indexed value
+	Keys int `json:"keys"`
+}
+
+// index maps the values of a field to the keys holding them. Only scalar
+// values are indexed, compared by their JSON encoding so integers and floats
+// with the same value match.
+type index struct {
+	field  string
+	path   []string
+	values map[string]map[string]struct{}
+	// byKey holds the indexed value of each key so removals do not decode
+	byKey map[string]string
+}
+
+func newIndex(field string) *index {
+	return &index{
+		field:  field,
+		path:   strings.Split(field, "."),
+		values: make(map[string]map[string]struct{}),
+		byKey:  make(map[string]string),
+	}
+}
+
+func (idx *index) add(key string, value map[string]any) {
+	v, ok := lookup(value, idx.path)
+	if !ok {
+		return
+	}
+	term, ok := indexTerm(v)
+	if !ok {
+		return
+	}
+	keys, ok := idx.values[term]
+	if !ok {
+		keys = make(map[string]struct{})
+		idx.values[term] = keys
+	}
+	keys[key] = struct{}{}
+	idx.byKey[key] = term
+}
+
+func (idx *index) remove(key string) {
+	term, ok := idx.byKey[key]
+	if !ok {
+		return
+	}
+	delete(idx.byKey, key)
+	delete(idx.values[term], key)
+	if len(idx.values[term]) == 0 {
+		delete(idx.values, term)
+	}
+}
+
+// indexTerm returns the indexed form of a scalar value
+func indexTerm(v any) (string, bool) {
+	switch v.(type) {
+	case map[string]any, []any:
+		return "", false
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", false
+	}
+	return string(b), true
+}
+
+// CreateIndex indexes the keys of the namespace by field, a dot separated path
+// such as user.id into the stored objects. Existing keys are indexed
+// immediately and the index is kept up to date on every write, delete and
+// expiry. Recreating an index with a different field rebuilds it.
+func (c *Cache) CreateIndex(name, field string) error {
+	if !validNamespace.MatchString(name) || field == "" {
+		return ErrorInvalidIndex
+	}
+	for _, part := range strings.Split(field, ".") {
+		if part == "" {
+			return ErrorInvalidIndex
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if idx, ok := c.indexes[name]; ok && idx.field == field {
+		return nil
+	}
+	idx := newIndex(field)
+	for key, item := range c.store {
+		if value := item.Value(); value != nil {
+			idx.add(key, value)
+		}
+	}
+	c.indexes[name] = idx
+	return nil
+}
+
+// DropIndex removes an index and reports whether it existed
+func (c *Cache) DropIndex(name string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.indexes[name]
+	delete(c.indexes, name)
+	return ok
+}
+
+// Indexes returns the indexes of the namespace sorted by name
+func (c *Cache) Indexes() []IndexInfo {
+	c.mu.RLock()
+	infos := make([]IndexInfo, 0, len(c.indexes))
+	for name, idx := range c.indexes {
+		infos = append(infos, IndexInfo{Name: name, Field: idx.field, Keys: len(idx.byKey)})
+	}
+	c.mu.RUnlock()
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// Query returns the entries whose indexed field equals value, sorted by key.
+// ErrorIndexNotFound is returned when the index does not exist.
+func (c *Cache) Query(name string, value any) ([]Entry, error) {
+	term, ok := indexTerm(value)
+	if !ok {
+		return nil, ErrorInvalidIndex
+	}
+
+	now := time.Now()
+	c.mu.RLock()
+	idx, ok := c.indexes[name]
+	if !ok {
+		c.mu.RUnlock()
+		return nil, ErrorIndexNotFound
+	}
+	entries := make([]Entry, 0, len(idx.values[term]))
+	for key := range idx.values[term] {
+		if item := c.store[key]; !item.expired(now) {
+			entries = append(entries, Entry{Key: key, Value: item.Value()})
+		}
+	}
+	c.mu.RUnlock()
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	return entries, nil
+}
+
+// reindex updates the indexes for a write of key, the caller must hold the
+// write lock
+func (c *Cache) reindex(key string, item CacheItem) {
+	if len(c.indexes) == 0 {
+		return
+	}
+	value := item.Value()
+	for _, idx := range c.indexes {
+		idx.remove(key)
+		if value != nil {
+			idx.add(key, value)
+		}
+	}
+}
+
+// unindex removes key from the indexes, the caller must hold the write lock
+func (c *Cache) unindex(key string) {
+	for _, idx := range c.indexes {
+		idx.remove(key)
+	}
+}
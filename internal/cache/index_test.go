@@ -0,0 +1,77 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIndex(t *testing.T) {
+	ns := newCache("index")
+	assert.NoError(t, ns.Set("session:1", map[string]any{"user_id": float64(42), "device": map[string]any{"os": "ios"}}))
+	assert.NoError(t, ns.Set("session:2", map[string]any{"user_id": float64(7)}))
+
+	// Test existing keys are indexed on creation
+	assert.NoError(t, ns.CreateIndex("user_id", "user_id"))
+	assert.NoError(t, ns.CreateIndex("os", "device.os"))
+	entries, err := ns.Query("user_id", float64(42))
+	assert.NoError(t, err)
+	assert.Equal(t, []Entry{{Key: "session:1", Value: map[string]any{"user_id": float64(42), "device": map[string]any{"os": "ios"}}}}, entries)
+
+	// Test nested fields are indexed
+	entries, err = ns.Query("os", "ios")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	// Test the index follows writes, integers matching floats
+	_, _, err = ns.IncrInt("session:3", 42, IncrOptions{Field: "user_id"})
+	assert.NoError(t, err)
+	assert.NoError(t, ns.Set("session:1", map[string]any{"user_id": float64(8)}))
+	entries, err = ns.Query("user_id", float64(42))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "session:3", entries[0].Key)
+
+	// Test the overwritten key left the nested index
+	entries, err = ns.Query("os", "ios")
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+
+	// Test the index follows deletes
+	ns.Delete("session:3")
+	entries, err = ns.Query("user_id", float64(42))
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+
+	assert.Equal(t, []IndexInfo{{Name: "os", Field: "device.os", Keys: 0}, {Name: "user_id", Field: "user_id", Keys: 2}}, ns.Indexes())
+
+	// Test querying a dropped index
+	assert.True(t, ns.DropIndex("user_id"))
+	_, err = ns.Query("user_id", float64(42))
+	assert.Equal(t, ErrorIndexNotFound, err)
+}
+
+func TestIndexExpiry(t *testing.T) {
+	ns := newCache("indexExpiry")
+	assert.NoError(t, ns.CreateIndex("status", "status"))
+	_, err := ns.SetWithOptions("job", map[string]any{"status": "running"}, SetOptions{TTL: 10 * time.Millisecond})
+	assert.NoError(t, err)
+
+	// Test expired keys are not returned before the janitor runs
+	time.Sleep(20 * time.Millisecond)
+	entries, err := ns.Query("status", "running")
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+
+	// Test the janitor removes them from the index
+	ns.removeExpired(time.Now())
+	assert.Equal(t, 0, ns.Indexes()[0].Keys)
+}
+
+func TestCreateIndexInvalid(t *testing.T) {
+	ns := newCache("indexInvalid")
+	assert.Equal(t, ErrorInvalidIndex, ns.CreateIndex("", "field"))
+	assert.Equal(t, ErrorInvalidIndex, ns.CreateIndex("name", ""))
+	assert.Equal(t, ErrorInvalidIndex, ns.CreateIndex("name", "a..b"))
+}
@@ -0,0 +1,147 @@
+package cache
+
+import (
+	"errors"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+var ErrorEmptySelector = errors.New("prefix, match or tag is required")
+
+// Selector selects a group of keys for bulk invalidation. When several fields
+// are set a key must satisfy all of them.
+type Selector struct {
+	Prefix string
+	Match  string
+	Tag    string
+}
+
+// SelectorFromParams reads a selector from the prefix, match and tag parameters
+func SelectorFromParams(params url.Values) Selector {
+	return Selector{
+		Prefix: params.Get("prefix"),
+		Match:  params.Get("match"),
+		Tag:    params.Get("tag"),
+	}
+}
+
+// Params encodes the selector as the parameters read by SelectorFromParams
+func (s Selector) Params() url.Values {
+	params := url.Values{}
+	if s.Prefix != "" {
+		params.Set("prefix", s.Prefix)
+	}
+	if s.Match != "" {
+		params.Set("match", s.Match)
+	}
+	if s.Tag != "" {
+		params.Set("tag", s.Tag)
+	}
+	return params
+}
+
+// IsEmpty reports whether the selector would match every key
+func (s Selector) IsEmpty() bool {
+	return s.Prefix == "" && s.Match == "" && s.Tag == ""
+}
+
+// Invalidate deletes every key of the default namespace matching sel
+func Invalidate(sel Selector) (int, error) {
+	return c.Invalidate(sel)
+}
+
+// Invalidate deletes every key matching sel and returns the number of keys
+// removed. An empty selector is rejected so a missing parameter can not
+// wipe the whole cache.
+func (c *Cache) Invalidate(sel Selector) (int, error) {
+	selected, err := sel.matcher()
+	if err != nil {
+		return 0, err
+	}
+
+	c.mu.Lock()
+	removed := c.selectKeys(sel.Tag, selected)
+	for _, key := range removed {
+		c.remove(key)
+	}
+	c.mu.Unlock()
+
+	now := time.Now()
+	for _, key := range removed {
+		c.publish(Event{Op: EventDelete, Key: key, Time: now})
+	}
+	return len(removed), nil
+}
+
+// Select returns the sorted keys matching sel without removing them, so they
+// can be checked or deleted from a store before DeleteKeys removes them
+func (c *Cache) Select(sel Selector) ([]string, error) {
+	selected, err := sel.matcher()
+	if err != nil {
+		return nil, err
+	}
+	c.mu.RLock()
+	keys := c.selectKeys(sel.Tag, selected)
+	c.mu.RUnlock()
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// DeleteKeys deletes keys and returns the number of keys removed
+func (c *Cache) DeleteKeys(keys []string) int {
+	var removed []string
+	c.mu.Lock()
+	for _, key := range keys {
+		if c.remove(key) {
+			removed = append(removed, key)
+		}
+	}
+	c.mu.Unlock()
+
+	now := time.Now()
+	for _, key := range removed {
+		c.publish(Event{Op: EventDelete, Key: key, Time: now})
+	}
+	return len(removed)
+}
+
+// matcher returns whether a key satisfies the prefix and pattern of the
+// selector, rejecting an empty selector
+func (s Selector) matcher() (func(key string) bool, error) {
+	if s.IsEmpty() {
+		return nil, ErrorEmptySelector
+	}
+	var match *regexp.Regexp
+	if s.Match != "" {
+		var err error
+		if match, err = compileGlob(s.Match); err != nil {
+			return nil, err
+		}
+	}
+	return func(key string) bool {
+		return strings.HasPrefix(key, s.Prefix) && (match == nil || match.MatchString(key))
+	}, nil
+}
+
+// selectKeys returns the keys written with tag, or every key when tag is
+// empty, that are selected. The caller must hold the lock.
+func (c *Cache) selectKeys(tag string, selected func(key string) bool) []string {
+	var keys []string
+	if tag != "" {
+		for key := range c.tags[tag] {
+			if selected(key) {
+				keys = append(keys, key)
+			}
+		}
+		return keys
+	}
+	for key := range c.store {
+		if selected(key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,72 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInvalidateByPrefixAndMatch(t *testing.T) {
+	Set("catalog:1:name", map[string]any{"field1": "value1"})
+	Set("catalog:1:price", map[string]any{"field1": "value1"})
+	Set("catalog:2:name", map[string]any{"field1": "value1"})
+
+	// Test Invalidate by match
+	deleted, err := Invalidate(Selector{Match: "catalog:*:price"})
+	assert.Nil(t, err, "Expected no error on Invalidate")
+	assert.Equal(t, 1, deleted)
+	assert.Equal(t, []string{"catalog:1:name", "catalog:2:name"}, Keys("catalog:"))
+
+	// Test Invalidate by prefix
+	deleted, err = Invalidate(Selector{Prefix: "catalog:"})
+	assert.Nil(t, err, "Expected no error on Invalidate")
+	assert.Equal(t, 2, deleted)
+	assert.Empty(t, Keys("catalog:"))
+}
+
+func TestInvalidateByTag(t *testing.T) {
+	SetWithOptions("tagged:1", map[string]any{"field1": "value1"}, SetOptions{Tags: []string{"reindex"}})
+	SetWithOptions("tagged:2", map[string]any{"field1": "value1"}, SetOptions{Tags: []string{"reindex", "other"}})
+	SetWithOptions("tagged:3", map[string]any{"field1": "value1"}, SetOptions{Tags: []string{"reindex"}})
+
+	// Test rewriting a key replaces its tags
+	Set("tagged:3", map[string]any{"field1": "value2"})
+
+	deleted, err := Invalidate(Selector{Tag: "reindex"})
+	assert.Nil(t, err, "Expected no error on Invalidate")
+	assert.Equal(t, 2, deleted)
+	assert.Equal(t, []string{"tagged:3"}, Keys("tagged:"))
+
+	// Test the tag index no longer references the deleted keys
+	c.mu.RLock()
+	_, ok := c.tags["reindex"]
+	other := len(c.tags["other"])
+	c.mu.RUnlock()
+	assert.False(t, ok, "Expected empty tag to be removed")
+	assert.Equal(t, 0, other)
+}
+
+func TestInvalidateEmptySelector(t *testing.T) {
+	_, err := Invalidate(Selector{})
+	assert.Equal(t, ErrorEmptySelector, err)
+}
+
+func TestSelectAndDeleteKeys(t *testing.T) {
+	SetWithOptions("selected:2", map[string]any{"field1": "value1"}, SetOptions{Tags: []string{"select"}})
+	SetWithOptions("selected:1", map[string]any{"field1": "value1"}, SetOptions{Tags: []string{"select"}})
+	Set("selected:3", map[string]any{"field1": "value1"})
+
+	// Test Select returns the sorted keys without removing them
+	keys, err := c.Select(Selector{Tag: "select"})
+	assert.Nil(t, err, "Expected no error on Select")
+	assert.Equal(t, []string{"selected:1", "selected:2"}, keys)
+	assert.Len(t, Keys("selected:"), 3)
+
+	_, err = c.Select(Selector{})
+	assert.Equal(t, ErrorEmptySelector, err)
+
+	// Test DeleteKeys counts only the keys it removed
+	assert.Equal(t, 2, c.DeleteKeys(append(keys, "selected:missing")))
+	assert.Equal(t, []string{"selected:3"}, Keys("selected:"))
+	c.Delete("selected:3")
+}
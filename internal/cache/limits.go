@@ -0,0 +1,95 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+const (
+	// DEFAULT_MAX_KEY_LENGTH is the maximum length of a key in bytes
+	DEFAULT_MAX_KEY_LENGTH = 1024
+
+	// DEFAULT_MAX_VALUE_SIZE is the maximum size of a stored value in bytes
+	DEFAULT_MAX_VALUE_SIZE = 1 << 20
+
+	// DEFAULT_MAX_BATCH_SIZE is the maximum number of operations of a
+	// transaction
+	DEFAULT_MAX_BATCH_SIZE = MAX_TXN_OPS
+
+	// SYNC_OP_OVERHEAD is the room left for the options and framing of each
+	// operation of a replicated request, beyond its key
+	SYNC_OP_OVERHEAD = 256
+)
+
+var (
+	ErrorKeyTooLong    = errors.New("key too long")
+	ErrorValueTooLarge = errors.New("value too large")
+	ErrorBatchTooLarge = errors.New("batch too large")
+)
+
+// Limits bounds the writes accepted by every namespace so a single request
+// can not exhaust the memory of the worker and of its peers
+type Limits struct {
+	MaxKeyLength int
+	// MaxValueSize bounds the encoded size of a value, collections included
+	MaxValueSize int
+	MaxBatchSize int
+}
+
+var limits atomic.Pointer[Limits]
+
+func init() {
+	SetLimits(Limits{})
+}
+
+// SetLimits replaces the limits of the writes, a zero limit uses its default
+func SetLimits(l Limits) {
+	if l.MaxKeyLength <= 0 {
+		l.MaxKeyLength = DEFAULT_MAX_KEY_LENGTH
+	}
+	if l.MaxValueSize <= 0 {
+		l.MaxValueSize = DEFAULT_MAX_VALUE_SIZE
+	}
+	if l.MaxBatchSize <= 0 {
+		l.MaxBatchSize = DEFAULT_MAX_BATCH_SIZE
+	}
+	limits.Store(&l)
+}
+
+// CurrentLimits returns the limits of the writes
+func CurrentLimits() Limits {
+	return *limits.Load()
+}
+
+// MaxSyncSize bounds the body of a sync request. A replicated write carries
+// at most a value, or a batch of operations whose values add up to at most
+// MaxValueSize, each with its key and options.
+func (l Limits) MaxSyncSize() int64 {
+	return int64(l.MaxValueSize) + int64(l.MaxBatchSize)*int64(l.MaxKeyLength+SYNC_OP_OVERHEAD)
+}
+
+// checkKey returns ErrorKeyTooLong when key is over the limit
+func checkKey(key string) error {
+	if max := limits.Load().MaxKeyLength; len(key) > max {
+		return fmt.Errorf("%w: %d bytes, the limit is %d", ErrorKeyTooLong, len(key), max)
+	}
+	return nil
+}
+
+// checkSize returns ErrorValueTooLarge when the value of item is over the limit
+func checkSize(item CacheItem) error {
+	if max := limits.Load().MaxValueSize; item.Size() > max {
+		return fmt.Errorf("%w: %d bytes, the limit is %d", ErrorValueTooLarge, item.Size(), max)
+	}
+	return nil
+}
+
+// checkItem returns the error of the first limit key or the value of item
+// is over
+func checkItem(key string, item CacheItem) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	return checkSize(item)
+}
@@ -0,0 +1,58 @@
+package cache
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimits(t *testing.T) {
+	SetLimits(Limits{MaxKeyLength: 8, MaxValueSize: 512, MaxBatchSize: 2})
+	t.Cleanup(func() { SetLimits(Limits{}) })
+	ns := newCache("limits")
+
+	// Test keys and values over the limits are not written
+	_, err := ns.SetWithOptions("too-long-key", map[string]any{"a": 1}, SetOptions{})
+	assert.ErrorIs(t, err, ErrorKeyTooLong)
+	_, err = ns.SetWithOptions("big", map[string]any{"a": strings.Repeat("x", 512)}, SetOptions{})
+	assert.ErrorIs(t, err, ErrorValueTooLarge)
+	assert.Empty(t, ns.Keys(""))
+
+	// Test a patch or a push growing a value over the limit keeps the old one
+	assert.NoError(t, ns.Set("doc", map[string]any{"a": "b"}))
+	_, _, err = ns.Update("doc", Precondition{}, func(value map[string]any) (map[string]any, error) {
+		return map[string]any{"a": strings.Repeat("x", 512)}, nil
+	})
+	assert.ErrorIs(t, err, ErrorValueTooLarge)
+	value, err := ns.Get("doc")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"a": "b"}, value)
+
+	_, _, err = ns.Apply("queue", CollectionOp{Op: OpRPush, Values: []any{strings.Repeat("x", 200)}}, CollectionOptions{})
+	assert.NoError(t, err)
+	_, _, err = ns.Apply("queue", CollectionOp{Op: OpRPush, Values: []any{strings.Repeat("x", 200)}}, CollectionOptions{})
+	assert.ErrorIs(t, err, ErrorValueTooLarge)
+	values, err := ns.LRange("queue", 0, -1)
+	assert.NoError(t, err)
+	assert.Len(t, values, 1)
+
+	_, _, err = ns.IncrInt("too-long-key", 1, IncrOptions{})
+	assert.ErrorIs(t, err, ErrorKeyTooLong)
+
+	// Test a transaction over the batch size or with a large value is rejected
+	_, err = ns.Commit(Txn{Ops: []TxnOp{{Op: TxnDelete, Key: "a"}, {Op: TxnDelete, Key: "b"}, {Op: TxnDelete, Key: "c"}}})
+	assert.ErrorIs(t, err, ErrorBatchTooLarge)
+	_, err = ns.Commit(Txn{Ops: []TxnOp{{Op: TxnSet, Key: "a", Value: map[string]any{"a": strings.Repeat("x", 512)}}}})
+	assert.ErrorIs(t, err, ErrorValueTooLarge)
+
+	// Test zero limits use the defaults
+	SetLimits(Limits{})
+	assert.Equal(t, Limits{MaxKeyLength: DEFAULT_MAX_KEY_LENGTH, MaxValueSize: DEFAULT_MAX_VALUE_SIZE, MaxBatchSize: DEFAULT_MAX_BATCH_SIZE}, CurrentLimits())
+}
+
+func TestMaxSyncSize(t *testing.T) {
+	// Test a sync request fits a batch of the largest keys and values
+	l := Limits{MaxKeyLength: 8, MaxValueSize: 512, MaxBatchSize: 2}
+	assert.Equal(t, int64(512+2*(8+SYNC_OP_OVERHEAD)), l.MaxSyncSize())
+}
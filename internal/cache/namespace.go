@@ -0,0 +1,289 @@
+package cache
+
+import (
+	"container/list"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+var ErrorInvalidNamespace = errors.New("invalid namespace")
+var ErrorNamespaceNotFound = errors.New("namespace not found")
+
+var validNamespace = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+
+var nsMu sync.RWMutex
+var named = make(map[string]*Cache)
+
+// NamespaceConfig holds the limits of a namespace, zero values mean unlimited
+type NamespaceConfig struct {
+	// MaxKeys is the number of keys kept before the oldest writes are evicted
+	MaxKeys int
+	// MaxBytes is the encoded size kept before the oldest writes are evicted
+	MaxBytes int64
+	// DefaultTTL applies to writes that do not set a ttl
+	DefaultTTL time.Duration
+}
+
+type namespaceConfigJSON struct {
+	MaxKeys    int    `json:"max_keys,omitempty"`
+	MaxBytes   int64  `json:"max_bytes,omitempty"`
+	DefaultTTL string `json:"default_ttl,omitempty"`
+}
+
+// MarshalJSON encodes the default ttl as a duration string such as 30m
+func (n NamespaceConfig) MarshalJSON() ([]byte, error) {
+	v := namespaceConfigJSON{MaxKeys: n.MaxKeys, MaxBytes: n.MaxBytes}
+	if n.DefaultTTL > 0 {
+		v.DefaultTTL = n.DefaultTTL.String()
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON decodes the default ttl from a duration string such as 30m
+func (n *NamespaceConfig) UnmarshalJSON(b []byte) error {
+	var v namespaceConfigJSON
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	if v.MaxKeys < 0 || v.MaxBytes < 0 {
+		return errors.New("namespace limits must not be negative")
+	}
+	*n = NamespaceConfig{MaxKeys: v.MaxKeys, MaxBytes: v.MaxBytes}
+	if v.DefaultTTL != "" {
+		ttl, err := time.ParseDuration(v.DefaultTTL)
+		if err != nil {
+			return err
+		}
+		if ttl < 0 {
+			return errors.New("default_ttl must not be negative")
+		}
+		n.DefaultTTL = ttl
+	}
+	return nil
+}
+
+// NamespaceStats reports the configuration and usage of a namespace
+type NamespaceStats struct {
+	Name      string          `json:"name"`
+	Config    NamespaceConfig `json:"config"`
+	Keys      int             `json:"keys"`
+	Bytes     int64           `json:"bytes"`
+	Evictions uint64          `json:"evictions"`
+	Hits      uint64          `json:"hits"`
+	Misses    uint64          `json:"misses"`
+}
+
+// Namespace returns the namespace called name. The empty name is the default
+// namespace used by the package level functions, other namespaces must be
+// created first and ErrorNamespaceNotFound is returned for unknown names.
+func Namespace(name string) (*Cache, error) {
+	if name == "" {
+		return c, nil
+	}
+	if !validNamespace.MatchString(name) {
+		return nil, ErrorInvalidNamespace
+	}
+
+	nsMu.RLock()
+	defer nsMu.RUnlock()
+	ns, ok := named[name]
+	if !ok {
+		return nil, ErrorNamespaceNotFound
+	}
+	return ns, nil
+}
+
+// CreateNamespace returns the namespace called name, creating it with no
+// limits if it does not exist. Namespaces are only created by the namespaces
+// file, an admin request or a peer replicating one, never by a key operation.
+func CreateNamespace(name string) (*Cache, error) {
+	if name == "" {
+		return c, nil
+	}
+	if !validNamespace.MatchString(name) {
+		return nil, ErrorInvalidNamespace
+	}
+
+	nsMu.Lock()
+	defer nsMu.Unlock()
+	ns, ok := named[name]
+	if !ok {
+		ns = newCache(name)
+		named[name] = ns
+	}
+	return ns, nil
+}
+
+// LoadNamespaces configures the namespaces defined in a JSON file mapping
+// namespace names to their limits, e.g. {"sessions": {"max_keys": 10000}}
+func LoadNamespaces(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var configs map[string]NamespaceConfig
+	if err := json.Unmarshal(b, &configs); err != nil {
+		return err
+	}
+	for name, config := range configs {
+		ns, err := CreateNamespace(name)
+		if err != nil {
+			return fmt.Errorf("%w: %q", err, name)
+		}
+		ns.Configure(config)
+	}
+	return nil
+}
+
+// NamespaceSchema is the configuration and the index declarations of a
+// namespace, copied from a peer by a worker joining the cluster
+type NamespaceSchema struct {
+	Name    string          `json:"name"`
+	Config  NamespaceConfig `json:"config"`
+	Indexes []IndexInfo     `json:"indexes,omitempty"`
+}
+
+// Schemas returns the schema of every namespace, the default namespace comes
+// first
+func Schemas() []NamespaceSchema {
+	var schemas []NamespaceSchema
+	for _, ns := range namespaces() {
+		schemas = append(schemas, NamespaceSchema{Name: ns.name, Config: ns.Config(), Indexes: ns.Indexes()})
+	}
+	return schemas
+}
+
+// ApplySchemas creates the namespaces and indexes of schemas copied from a
+// peer. Namespaces that already exist, such as those of the namespaces file,
+// keep their configuration.
+func ApplySchemas(schemas []NamespaceSchema) error {
+	for _, schema := range schemas {
+		_, err := Namespace(schema.Name)
+		exists := err == nil
+		ns, err := CreateNamespace(schema.Name)
+		if err != nil {
+			return fmt.Errorf("%w: %q", err, schema.Name)
+		}
+		if !exists {
+			ns.Configure(schema.Config)
+		}
+		for _, idx := range schema.Indexes {
+			if err := ns.CreateIndex(idx.Name, idx.Field); err != nil {
+				return fmt.Errorf("%w: %q", err, idx.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// Namespaces returns the stats of every namespace sorted by name, the default
+// namespace comes first
+func Namespaces() []NamespaceStats {
+	var stats []NamespaceStats
+	for _, ns := range namespaces() {
+		stats = append(stats, ns.Stats())
+	}
+	return stats
+}
+
+// namespaces returns the default namespace followed by the named ones
+func namespaces() []*Cache {
+	nsMu.RLock()
+	all := make([]*Cache, 0, len(named)+1)
+	for _, ns := range named {
+		all = append(all, ns)
+	}
+	nsMu.RUnlock()
+
+	sort.Slice(all, func(i, j int) bool { return all[i].name < all[j].name })
+	return append([]*Cache{c}, all...)
+}
+
+// Configure replaces the limits of the namespace, keys over the new limits
+// are evicted immediately
+func (c *Cache) Configure(config NamespaceConfig) {
+	c.mu.Lock()
+	c.config = config
+	evicted := c.evict()
+	c.mu.Unlock()
+
+	now := time.Now()
+	for _, k := range evicted {
+		c.publish(Event{Op: EventEvict, Key: k, Time: now})
+	}
+}
+
+// Config returns the limits of the namespace
+func (c *Cache) Config() NamespaceConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.config
+}
+
+// Stats returns the configuration and usage of the namespace
+func (c *Cache) Stats() NamespaceStats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return NamespaceStats{
+		Name:      c.name,
+		Config:    c.config,
+		Keys:      len(c.store),
+		Bytes:     c.bytes,
+		Evictions: c.evictions.Load(),
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+	}
+}
+
+// Flush removes every key of the namespace and returns the number removed,
+// other namespaces are not affected
+func (c *Cache) Flush() int {
+	c.mu.Lock()
+	n := len(c.store)
+	c.store = make(map[string]CacheItem)
+	c.tags = make(map[string]map[string]struct{})
+	c.order.Init()
+	c.elems = make(map[string]*list.Element)
+	c.sorted = nil
+	c.bytes = 0
+	for name, idx := range c.indexes {
+		c.indexes[name] = newIndex(idx.field)
+	}
+	c.mu.Unlock()
+
+	c.publish(Event{Op: EventFlush, Time: time.Now()})
+	return n
+}
+
+// evict removes the least recently written keys until the namespace is within
+// its limits, the keys in keep are never evicted so a write can not evict
+// itself. The caller must hold the write lock.
+func (c *Cache) evict(keep ...string) []string {
+	var evicted []string
+	for c.overCapacity() {
+		e := c.order.Front()
+		for e != nil && slices.Contains(keep, e.Value.(string)) {
+			e = e.Next()
+		}
+		if e == nil {
+			break
+		}
+		key := e.Value.(string)
+		c.remove(key)
+		c.evictions.Add(1)
+		evicted = append(evicted, key)
+	}
+	return evicted
+}
+
+func (c *Cache) overCapacity() bool {
+	return (c.config.MaxKeys > 0 && len(c.store) > c.config.MaxKeys) ||
+		(c.config.MaxBytes > 0 && c.bytes > c.config.MaxBytes)
+}
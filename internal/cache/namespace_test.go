@@ -0,0 +1,152 @@
+package cache
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNamespaceIsolation(t *testing.T) {
+	teamA, err := CreateNamespace("teamA")
+	assert.Nil(t, err, "Expected no error on Namespace")
+	teamB, err := CreateNamespace("teamB")
+	assert.Nil(t, err, "Expected no error on Namespace")
+
+	// Test the same key in different namespaces holds different values
+	teamA.Set("shared", map[string]any{"owner": "a"})
+	teamB.Set("shared", map[string]any{"owner": "b"})
+
+	value, err := teamA.Get("shared")
+	assert.Nil(t, err, "Expected no error on Get")
+	assert.Equal(t, map[string]any{"owner": "a"}, value)
+
+	_, err = Get("shared")
+	assert.Equal(t, ErrorKeyNotFound, err, "Expected the default namespace to be isolated")
+
+	// Test Flush only clears one namespace
+	assert.Equal(t, 1, teamA.Flush())
+	_, err = teamA.Get("shared")
+	assert.Equal(t, ErrorKeyNotFound, err)
+	_, err = teamB.Get("shared")
+	assert.Nil(t, err, "Expected other namespace to keep its keys")
+}
+
+func TestNamespaceInvalidName(t *testing.T) {
+	_, err := Namespace("not/valid")
+	assert.Equal(t, ErrorInvalidNamespace, err)
+	_, err = CreateNamespace("not/valid")
+	assert.Equal(t, ErrorInvalidNamespace, err)
+}
+
+func TestNamespaceNotFound(t *testing.T) {
+	// Test unknown namespaces are not created by a lookup
+	_, err := Namespace("unknown")
+	assert.Equal(t, ErrorNamespaceNotFound, err)
+	_, err = Namespace("unknown")
+	assert.Equal(t, ErrorNamespaceNotFound, err)
+
+	created, err := CreateNamespace("unknown")
+	assert.Nil(t, err, "Expected no error on CreateNamespace")
+	ns, err := Namespace("unknown")
+	assert.Nil(t, err, "Expected no error on Namespace")
+	assert.Same(t, created, ns)
+}
+
+func TestNamespaceMaxKeys(t *testing.T) {
+	ns, _ := CreateNamespace("maxKeys")
+	ns.Configure(NamespaceConfig{MaxKeys: 2})
+
+	ns.Set("a", map[string]any{"field1": "value1"})
+	ns.Set("b", map[string]any{"field1": "value1"})
+	// rewriting a makes b the oldest write
+	ns.Set("a", map[string]any{"field1": "value2"})
+	ns.Set("c", map[string]any{"field1": "value1"})
+
+	assert.Equal(t, []string{"a", "c"}, ns.Keys(""))
+	stats := ns.Stats()
+	assert.Equal(t, 2, stats.Keys)
+	assert.Equal(t, uint64(1), stats.Evictions)
+}
+
+func TestNamespaceMaxBytes(t *testing.T) {
+	ns, _ := CreateNamespace("maxBytes")
+	ns.Set("a", map[string]any{"field1": "value1"})
+	size := ns.Stats().Bytes
+
+	// Test lowering the limit evicts the keys over it
+	ns.Set("b", map[string]any{"field1": "value1"})
+	ns.Configure(NamespaceConfig{MaxBytes: size})
+	assert.Equal(t, []string{"b"}, ns.Keys(""))
+	assert.Equal(t, size, ns.Stats().Bytes)
+}
+
+func TestNamespaceDefaultTTL(t *testing.T) {
+	ns, _ := CreateNamespace("defaultTTL")
+	ns.Configure(NamespaceConfig{DefaultTTL: time.Minute})
+
+	ns.Set("a", map[string]any{"field1": "value1"})
+	ns.SetWithOptions("b", map[string]any{"field1": "value1"}, SetOptions{TTL: time.Hour})
+
+	page, err := ns.Scan(ScanOptions{})
+	assert.Nil(t, err, "Expected no error on Scan")
+	assert.Equal(t, int64(60), page.Keys[0].TTL)
+	assert.Equal(t, int64(3600), page.Keys[1].TTL)
+}
+
+func TestNamespaceConfigJSON(t *testing.T) {
+	config := NamespaceConfig{MaxKeys: 10, DefaultTTL: 90 * time.Second}
+	b, err := json.Marshal(config)
+	assert.Nil(t, err, "Expected no error on Marshal")
+	assert.JSONEq(t, `{"max_keys":10,"default_ttl":"1m30s"}`, string(b))
+
+	var decoded NamespaceConfig
+	assert.Nil(t, json.Unmarshal(b, &decoded))
+	assert.Equal(t, config, decoded)
+
+	assert.NotNil(t, json.Unmarshal([]byte(`{"max_keys":-1}`), &decoded))
+}
+
+func TestLoadNamespaces(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "namespaces.json")
+	err := os.WriteFile(path, []byte(`{"loaded": {"max_keys": 5, "default_ttl": "30m"}}`), 0o600)
+	assert.Nil(t, err)
+
+	assert.Nil(t, LoadNamespaces(path), "Expected no error on LoadNamespaces")
+	ns, _ := Namespace("loaded")
+	assert.Equal(t, NamespaceConfig{MaxKeys: 5, DefaultTTL: 30 * time.Minute}, ns.Config())
+}
+
+func TestApplySchemas(t *testing.T) {
+	existing, _ := CreateNamespace("schemaExisting")
+	existing.Configure(NamespaceConfig{MaxKeys: 1})
+
+	err := ApplySchemas([]NamespaceSchema{
+		{Name: "schemaJoined", Config: NamespaceConfig{MaxKeys: 10}, Indexes: []IndexInfo{{Name: "byUser", Field: "user.id"}}},
+		{Name: "schemaExisting", Config: NamespaceConfig{MaxKeys: 20}, Indexes: []IndexInfo{{Name: "byOrder", Field: "order"}}},
+	})
+	assert.Nil(t, err, "Expected no error on ApplySchemas")
+
+	// Test unknown namespaces are created with the config and indexes of the peer
+	joined, err := Namespace("schemaJoined")
+	assert.Nil(t, err, "Expected the namespace to be created")
+	assert.Equal(t, NamespaceConfig{MaxKeys: 10}, joined.Config())
+	assert.Equal(t, []IndexInfo{{Name: "byUser", Field: "user.id"}}, joined.Indexes())
+
+	// Test existing namespaces keep their config and get the indexes
+	assert.Equal(t, NamespaceConfig{MaxKeys: 1}, existing.Config())
+	assert.Equal(t, []IndexInfo{{Name: "byOrder", Field: "order"}}, existing.Indexes())
+
+	// Test the schemas round trip
+	found := false
+	for _, schema := range Schemas() {
+		if schema.Name == "schemaJoined" {
+			found = true
+			assert.Equal(t, joined.Indexes(), schema.Indexes)
+		}
+	}
+	assert.True(t, found, "Expected the namespace to be listed")
+}
@@ -0,0 +1,336 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrorInvalidPatch = errors.New("invalid patch")
+var ErrorPatchTestFailed = errors.New("patch test failed")
+
+// PatchOperation is an operation of an RFC 6902 JSON Patch document
+type PatchOperation struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	From  string `json:"from,omitempty"`
+	Value any    `json:"value,omitempty"`
+}
+
+// Update atomically replaces the value stored under key with the result of
+// fn, keeping its expiry and tags. ErrorKeyNotFound is returned when the key
+// does not exist and ErrorPreconditionFailed when cond does not hold. The new
+// value is returned with the options replicas need to store the same entry.
+func (c *Cache) Update(key string, cond Precondition, fn func(value map[string]any) (map[string]any, error)) (map[string]any, SetOptions, error) {
+	now := time.Now()
+	c.mu.Lock()
+	old, exists := c.store[key]
+	exists = exists && !old.expired(now)
+	if !cond.satisfied(old, exists) {
+		c.mu.Unlock()
+		return nil, SetOptions{}, ErrorPreconditionFailed
+	}
+	if !exists {
+		c.mu.Unlock()
+		return nil, SetOptions{}, ErrorKeyNotFound
+	}
+	if old.kind != "" {
+		c.mu.Unlock()
+		return nil, SetOptions{}, ErrorWrongType
+	}
+
+	value, err := fn(old.Value())
+	if err != nil {
+		c.mu.Unlock()
+		return nil, SetOptions{}, err
+	}
+	item, err := newItem(value)
+	if err == nil {
+		err = checkSize(item)
+	}
+	if err != nil {
+		c.mu.Unlock()
+		return nil, SetOptions{}, err
+	}
+	item.expiresAt = old.expiresAt
+	item.staleUntil = old.staleUntil
+	item.tags = old.tags
+	item.version = nextVersion()
+	c.put(key, item)
+	evicted := c.evict(key)
+	c.mu.Unlock()
+
+	for _, k := range evicted {
+		c.publish(Event{Op: EventEvict, Key: k, Time: now})
+	}
+	c.publish(Event{Op: EventSet, Key: key, Value: value, Version: item.version, Time: now})
+	return value, SetOptions{TTL: item.TTL(now), Stale: item.grace(), Tags: item.tags, Version: item.version}, nil
+}
+
+// MergePatch applies an RFC 7396 merge patch to target: null members remove
+// fields, objects are merged recursively and other values replace the field.
+// A patch that is not an object, null included, replaces the whole value,
+// which fails with ErrorInvalidPatch since the value must remain an object.
+func MergePatch(target map[string]any, patch any) (map[string]any, error) {
+	fields, ok := patch.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%w: a merge patch that is not an object replaces the value, which must remain an object", ErrorInvalidPatch)
+	}
+	return mergeFields(target, fields), nil
+}
+
+// mergeFields merges the members of an object merge patch into target
+func mergeFields(target map[string]any, patch map[string]any) map[string]any {
+	if target == nil {
+		target = map[string]any{}
+	}
+	for k, v := range patch {
+		switch v := v.(type) {
+		case nil:
+			delete(target, k)
+		case map[string]any:
+			current, _ := target[k].(map[string]any)
+			target[k] = mergeFields(current, v)
+		default:
+			target[k] = v
+		}
+	}
+	return target
+}
+
+// JSONPatch applies an RFC 6902 JSON Patch document to doc. The operations
+// are applied in order and the first failing one fails the whole patch, doc
+// may be partially modified on error.
+func JSONPatch(doc map[string]any, ops []PatchOperation) (map[string]any, error) {
+	var root any = doc
+	for i, op := range ops {
+		var err error
+		switch op.Op {
+		case "add":
+			root, err = addValue(root, op.Path, deepCopy(op.Value))
+		case "remove":
+			root, _, err = removeValue(root, op.Path)
+		case "replace":
+			if op.Path == "" {
+				root = deepCopy(op.Value)
+			} else if root, _, err = removeValue(root, op.Path); err == nil {
+				root, err = addValue(root, op.Path, deepCopy(op.Value))
+			}
+		case "move":
+			if strings.HasPrefix(op.Path, op.From+"/") {
+				err = fmt.Errorf("%w: can not move %q into itself", ErrorInvalidPatch, op.From)
+				break
+			}
+			var v any
+			if root, v, err = removeValue(root, op.From); err == nil {
+				root, err = addValue(root, op.Path, v)
+			}
+		case "copy":
+			var v any
+			if v, err = getValue(root, op.From); err == nil {
+				root, err = addValue(root, op.Path, deepCopy(v))
+			}
+		case "test":
+			var v any
+			if v, err = getValue(root, op.Path); err == nil && !jsonEqual(v, op.Value) {
+				err = fmt.Errorf("%w: %q", ErrorPatchTestFailed, op.Path)
+			}
+		default:
+			err = fmt.Errorf("%w: unknown op %q", ErrorInvalidPatch, op.Op)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %w", i, err)
+		}
+	}
+
+	result, ok := root.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%w: the value must remain an object", ErrorInvalidPatch)
+	}
+	return result, nil
+}
+
+// parsePointer splits an RFC 6901 JSON pointer into unescaped tokens
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("%w: invalid path %q", ErrorInvalidPatch, pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// arrayIndex parses an array index token, "-" is accepted when end is set and
+// refers to the position after the last element
+func arrayIndex(token string, length int, end bool) (int, error) {
+	if token == "-" && end {
+		return length, nil
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || (token != "0" && strings.HasPrefix(token, "0")) {
+		return 0, fmt.Errorf("%w: invalid array index %q", ErrorInvalidPatch, token)
+	}
+	if i > length || (!end && i == length) {
+		return 0, fmt.Errorf("%w: array index %q out of range", ErrorInvalidPatch, token)
+	}
+	return i, nil
+}
+
+func getValue(root any, pointer string) (any, error) {
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	v := root
+	for _, t := range tokens {
+		switch node := v.(type) {
+		case map[string]any:
+			var ok bool
+			if v, ok = node[t]; !ok {
+				return nil, fmt.Errorf("%w: path %q not found", ErrorInvalidPatch, pointer)
+			}
+		case []any:
+			i, err := arrayIndex(t, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			v = node[i]
+		default:
+			return nil, fmt.Errorf("%w: path %q not found", ErrorInvalidPatch, pointer)
+		}
+	}
+	return v, nil
+}
+
+// addValue adds v at pointer and returns the new root, arrays are copied when
+// they grow so the parent is updated with the new slice
+func addValue(root any, pointer string, v any) (any, error) {
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return v, nil
+	}
+	return setIn(root, tokens, pointer, func(parent any, last string) (any, error) {
+		switch node := parent.(type) {
+		case map[string]any:
+			node[last] = v
+			return node, nil
+		case []any:
+			i, err := arrayIndex(last, len(node), true)
+			if err != nil {
+				return nil, err
+			}
+			grown := make([]any, 0, len(node)+1)
+			grown = append(grown, node[:i]...)
+			grown = append(grown, v)
+			return append(grown, node[i:]...), nil
+		}
+		return nil, fmt.Errorf("%w: path %q not found", ErrorInvalidPatch, pointer)
+	})
+}
+
+// removeValue removes the value at pointer and returns the new root and the
+// removed value
+func removeValue(root any, pointer string) (any, any, error) {
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, nil, fmt.Errorf("%w: can not remove the whole value", ErrorInvalidPatch)
+	}
+	var removed any
+	root, err = setIn(root, tokens, pointer, func(parent any, last string) (any, error) {
+		switch node := parent.(type) {
+		case map[string]any:
+			v, ok := node[last]
+			if !ok {
+				return nil, fmt.Errorf("%w: path %q not found", ErrorInvalidPatch, pointer)
+			}
+			removed = v
+			delete(node, last)
+			return node, nil
+		case []any:
+			i, err := arrayIndex(last, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			removed = node[i]
+			shrunk := make([]any, 0, len(node)-1)
+			shrunk = append(shrunk, node[:i]...)
+			return append(shrunk, node[i+1:]...), nil
+		}
+		return nil, fmt.Errorf("%w: path %q not found", ErrorInvalidPatch, pointer)
+	})
+	return root, removed, err
+}
+
+// setIn walks to the parent of the last token, replaces it with the result of
+// fn and returns the updated root
+func setIn(node any, tokens []string, pointer string, fn func(parent any, last string) (any, error)) (any, error) {
+	if len(tokens) == 1 {
+		return fn(node, tokens[0])
+	}
+	switch n := node.(type) {
+	case map[string]any:
+		child, ok := n[tokens[0]]
+		if !ok {
+			return nil, fmt.Errorf("%w: path %q not found", ErrorInvalidPatch, pointer)
+		}
+		updated, err := setIn(child, tokens[1:], pointer, fn)
+		if err != nil {
+			return nil, err
+		}
+		n[tokens[0]] = updated
+		return n, nil
+	case []any:
+		i, err := arrayIndex(tokens[0], len(n), false)
+		if err != nil {
+			return nil, err
+		}
+		updated, err := setIn(n[i], tokens[1:], pointer, fn)
+		if err != nil {
+			return nil, err
+		}
+		n[i] = updated
+		return n, nil
+	}
+	return nil, fmt.Errorf("%w: path %q not found", ErrorInvalidPatch, pointer)
+}
+
+func deepCopy(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		m := make(map[string]any, len(v))
+		for k, e := range v {
+			m[k] = deepCopy(e)
+		}
+		return m
+	case []any:
+		s := make([]any, len(v))
+		for i, e := range v {
+			s[i] = deepCopy(e)
+		}
+		return s
+	}
+	return v
+}
+
+// jsonEqual compares values by their JSON encoding, so numbers compare equal
+// whether they were stored as integers or decoded as floats
+func jsonEqual(a, b any) bool {
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(ja, jb)
+}
@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func decode(t *testing.T, s string) map[string]any {
+	var v map[string]any
+	assert.NoError(t, json.Unmarshal([]byte(s), &v))
+	return v
+}
+
+func TestMergePatch(t *testing.T) {
+	target := decode(t, `{"a":"b","c":{"d":"e","f":"g"},"h":[1,2]}`)
+	patch := decode(t, `{"a":"z","c":{"f":null,"x":{"y":null,"z":1}},"h":[3]}`)
+
+	// Test null removes fields and objects are merged recursively
+	result, err := MergePatch(target, patch)
+	assert.NoError(t, err)
+	assert.Equal(t, decode(t, `{"a":"z","c":{"d":"e","x":{"z":1}},"h":[3]}`), result)
+
+	// Test a patch that is not an object replaces the value, which must
+	// remain an object
+	for _, patch := range []any{nil, []any{float64(1)}, "a", float64(1)} {
+		_, err = MergePatch(decode(t, `{"a":"b"}`), patch)
+		assert.ErrorIs(t, err, ErrorInvalidPatch, patch)
+	}
+}
+
+func TestJSONPatch(t *testing.T) {
+	doc := decode(t, `{"name":"a","tags":["x","y"],"profile":{"age":30}}`)
+	var ops []PatchOperation
+	assert.NoError(t, json.Unmarshal([]byte(`[
+		{"op":"test","path":"/profile/age","value":30},
+		{"op":"replace","path":"/name","value":"b"},
+		{"op":"add","path":"/tags/-","value":"z"},
+		{"op":"remove","path":"/tags/0"},
+		{"op":"copy","from":"/profile","path":"/previous"},
+		{"op":"move","from":"/profile/age","path":"/age"},
+		{"op":"add","path":"/a~1b","value":null}
+	]`), &ops))
+
+	result, err := JSONPatch(doc, ops)
+	assert.NoError(t, err)
+	assert.Equal(t, decode(t, `{"name":"b","tags":["y","z"],"profile":{},"previous":{"age":30},"age":30,"a/b":null}`), result)
+}
+
+func TestJSONPatchErrors(t *testing.T) {
+	tests := map[string]error{
+		`[{"op":"test","path":"/n","value":2}]`:           ErrorPatchTestFailed,
+		`[{"op":"remove","path":"/missing"}]`:             ErrorInvalidPatch,
+		`[{"op":"add","path":"/list/5","value":1}]`:       ErrorInvalidPatch,
+		`[{"op":"replace","path":"","value":[]}]`:         ErrorInvalidPatch,
+		`[{"op":"move","from":"/list","path":"/list/0"}]`: ErrorInvalidPatch,
+		`[{"op":"increment","path":"/n"}]`:                ErrorInvalidPatch,
+	}
+	for patch, expected := range tests {
+		var ops []PatchOperation
+		assert.NoError(t, json.Unmarshal([]byte(patch), &ops))
+		_, err := JSONPatch(decode(t, `{"n":1,"list":[1]}`), ops)
+		assert.ErrorIs(t, err, expected, patch)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	ns := newCache("update")
+	_, err := ns.SetWithOptions("key", map[string]any{"a": "b"}, SetOptions{TTL: time.Hour, Tags: []string{"t"}})
+	assert.NoError(t, err)
+
+	// Test the update keeps the ttl and tags of the key
+	value, opts, err := ns.Update("key", Precondition{}, func(v map[string]any) (map[string]any, error) {
+		return MergePatch(v, map[string]any{"c": "d"})
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"a": "b", "c": "d"}, value)
+	assert.Equal(t, []string{"t"}, opts.Tags)
+	assert.InDelta(t, time.Hour, opts.TTL, float64(time.Second))
+
+	version, err := ns.Version("key")
+	assert.NoError(t, err)
+	assert.Equal(t, opts.Version, version)
+
+	// Test a failed update leaves the value untouched
+	_, _, err = ns.Update("key", Precondition{}, func(v map[string]any) (map[string]any, error) {
+		v["c"] = "e"
+		return nil, ErrorInvalidPatch
+	})
+	assert.Equal(t, ErrorInvalidPatch, err)
+	value, err = ns.Get("key")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"a": "b", "c": "d"}, value)
+
+	// Test updating a missing key
+	_, _, err = ns.Update("missing", Precondition{}, func(v map[string]any) (map[string]any, error) { return v, nil })
+	assert.Equal(t, ErrorKeyNotFound, err)
+}
@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"sort"
+	"time"
+)
+
+// Snapshot is the state of a key before a write, taken so the write can be
+// undone when it can not be persisted
+type Snapshot struct {
+	Key    string
+	item   CacheItem
+	exists bool
+}
+
+// Snapshot returns the current state of key
+func (c *Cache) Snapshot(key string) Snapshot {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	item, exists := c.store[key]
+	return Snapshot{Key: key, item: item, exists: exists}
+}
+
+// Restore undoes the write of version to the key of s, 0 for a write that
+// removed the key, by putting back the state of the snapshot. A key written
+// again since is left untouched. It reports whether the key was restored.
+func (c *Cache) Restore(s Snapshot, version uint64) bool {
+	now := time.Now()
+	c.mu.Lock()
+	current, exists := c.store[s.Key]
+	if exists != (version != 0) || (exists && current.version != version) || (!exists && !s.exists) {
+		c.mu.Unlock()
+		return false
+	}
+	var evicted []string
+	if s.exists {
+		c.put(s.Key, s.item)
+		evicted = c.evict(s.Key)
+	} else {
+		c.remove(s.Key)
+	}
+	c.mu.Unlock()
+
+	for _, k := range evicted {
+		c.publish(Event{Op: EventEvict, Key: k, Time: now})
+	}
+	if s.exists {
+		c.publish(Event{Op: EventSet, Key: s.Key, Value: s.item.Value(), Version: s.item.version, Time: now})
+	} else {
+		c.publish(Event{Op: EventDelete, Key: s.Key, Time: now})
+	}
+	return true
+}
+
+// Export returns the value stored under key as a JSON object, whatever its
+// kind, and its version. A collection is exported under the name of its
+// kind, e.g. {"list": [1, 2]}, sets as their sorted members.
+func (c *Cache) Export(key string) (map[string]any, uint64, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	item, ok := c.store[key]
+	if !ok || item.expired(time.Now()) {
+		return nil, 0, ErrorKeyNotFound
+	}
+	if item.kind == "" {
+		return item.Value(), item.version, nil
+	}
+
+	var coll collection
+	if err := coll.unmarshall(item.value); err != nil {
+		return nil, 0, err
+	}
+	var v any
+	switch item.kind {
+	case KindHash:
+		v = coll.Hash
+	case KindList:
+		v = coll.List
+	case KindSet:
+		members := make([]string, 0, len(coll.Set))
+		for m := range coll.Set {
+			members = append(members, m)
+		}
+		sort.Strings(members)
+		v = members
+	case KindZSet:
+		v = coll.ZSet
+	}
+	return map[string]any{item.kind: v}, item.version, nil
+}
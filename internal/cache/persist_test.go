@@ -0,0 +1,71 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRestore(t *testing.T) {
+	ns := newCache("restore")
+	ns.SetWithOptions("user", map[string]any{"name": "ada"}, SetOptions{Tags: []string{"people"}})
+	before, _ := ns.Version("user")
+
+	// Test restoring a write puts back the previous value and version
+	s := ns.Snapshot("user")
+	version, err := ns.SetWithOptions("user", map[string]any{"name": "grace"}, SetOptions{})
+	assert.NoError(t, err)
+	assert.True(t, ns.Restore(s, version))
+	value, restored, err := ns.GetVersioned("user")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"name": "ada"}, value)
+	assert.Equal(t, before, restored)
+	keys, _ := ns.Select(Selector{Tag: "people"})
+	assert.Equal(t, []string{"user"}, keys)
+
+	// Test a key written again since is left untouched
+	s = ns.Snapshot("user")
+	version, _ = ns.SetWithOptions("user", map[string]any{"name": "grace"}, SetOptions{})
+	ns.SetWithOptions("user", map[string]any{"name": "alan"}, SetOptions{})
+	assert.False(t, ns.Restore(s, version))
+	value, _ = ns.Get("user")
+	assert.Equal(t, map[string]any{"name": "alan"}, value)
+
+	// Test restoring a write creating a key removes it
+	s = ns.Snapshot("new")
+	version, _ = ns.SetWithOptions("new", map[string]any{"name": "ada"}, SetOptions{})
+	assert.True(t, ns.Restore(s, version))
+	_, err = ns.Get("new")
+	assert.Equal(t, ErrorKeyNotFound, err)
+
+	// Test restoring a removal puts the key back
+	s = ns.Snapshot("user")
+	ns.Delete("user")
+	assert.True(t, ns.Restore(s, 0))
+	value, _ = ns.Get("user")
+	assert.Equal(t, map[string]any{"name": "alan"}, value)
+}
+
+func TestExport(t *testing.T) {
+	ns := newCache("export")
+	version, _ := ns.SetWithOptions("user", map[string]any{"name": "ada"}, SetOptions{})
+
+	value, exported, err := ns.Export("user")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"name": "ada"}, value)
+	assert.Equal(t, version, exported)
+
+	// Test collections are exported under their kind
+	ns.Apply("queue", CollectionOp{Op: OpRPush, Values: []any{"a", "b"}}, CollectionOptions{})
+	value, _, err = ns.Export("queue")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{KindList: []any{"a", "b"}}, value)
+
+	ns.Apply("tags", CollectionOp{Op: OpSAdd, Members: []string{"b", "a"}}, CollectionOptions{})
+	value, _, err = ns.Export("tags")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{KindSet: []string{"a", "b"}}, value)
+
+	_, _, err = ns.Export("missing")
+	assert.Equal(t, ErrorKeyNotFound, err)
+}
@@ -0,0 +1,300 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var ErrorInvalidPath = errors.New("invalid path")
+
+// Project returns the parts of value selected by fields, each field being a
+// dot separated path such as b.c into nested objects. Selected fields keep
+// their nesting and fields missing from value are left out, so the result is
+// empty when none of them exist.
+func Project(value map[string]any, fields []string) map[string]any {
+	result := map[string]any{}
+	for _, field := range fields {
+		path := strings.Split(field, ".")
+		v, ok := lookup(value, path)
+		if !ok {
+			continue
+		}
+		node := result
+		for _, name := range path[:len(path)-1] {
+			child, ok := node[name].(map[string]any)
+			if !ok {
+				child = map[string]any{}
+				node[name] = child
+			}
+			node = child
+		}
+		node[path[len(path)-1]] = v
+	}
+	return result
+}
+
+func lookup(value map[string]any, path []string) (any, bool) {
+	var v any = value
+	for _, name := range path {
+		node, ok := v.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if v, ok = node[name]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// JSONPath is a compiled JSONPath expression. The supported syntax is the
+// root $, child members .name and ['name'], array indexes [0] and [-1],
+// slices [start:end], unions [0,1] and ['a','b'], the wildcards .* and [*]
+// and the recursive descent ..name. Filter expressions are not supported.
+type JSONPath struct {
+	steps []pathStep
+}
+
+type pathStep struct {
+	recursive bool
+	wildcard  bool
+	names     []string
+	indexes   []int
+	slice     *[2]*int
+}
+
+// ParseJSONPath compiles a JSONPath expression
+func ParseJSONPath(expr string) (*JSONPath, error) {
+	if !strings.HasPrefix(expr, "$") {
+		return nil, fmt.Errorf("%w: %q must start with $", ErrorInvalidPath, expr)
+	}
+	p := &JSONPath{}
+	s := expr[1:]
+	for s != "" {
+		var step pathStep
+		switch {
+		case strings.HasPrefix(s, ".."):
+			step.recursive = true
+			s = s[2:]
+			if strings.HasPrefix(s, "[") {
+				break
+			}
+			fallthrough
+		case strings.HasPrefix(s, "."):
+			s = strings.TrimPrefix(s, ".")
+			end := strings.IndexAny(s, ".[")
+			if end < 0 {
+				end = len(s)
+			}
+			name := s[:end]
+			if name == "" {
+				return nil, fmt.Errorf("%w: %q has an empty member name", ErrorInvalidPath, expr)
+			}
+			if name == "*" {
+				step.wildcard = true
+			} else {
+				step.names = []string{name}
+			}
+			s = s[end:]
+			p.steps = append(p.steps, step)
+			continue
+		case !strings.HasPrefix(s, "["):
+			return nil, fmt.Errorf("%w: unexpected %q in %q", ErrorInvalidPath, s, expr)
+		}
+
+		end := closingBracket(s)
+		if end < 0 {
+			return nil, fmt.Errorf("%w: %q has an unterminated [", ErrorInvalidPath, expr)
+		}
+		if err := step.parseSelector(s[1:end]); err != nil {
+			return nil, fmt.Errorf("%w: %q: %v", ErrorInvalidPath, expr, err)
+		}
+		s = s[end+1:]
+		p.steps = append(p.steps, step)
+	}
+	return p, nil
+}
+
+// closingBracket returns the index of the ] closing the selector at the start
+// of s, skipping brackets inside quoted names
+func closingBracket(s string) int {
+	var quote byte
+	for i := 1; i < len(s); i++ {
+		switch {
+		case quote != 0 && s[i] == '\\':
+			i++
+		case quote != 0 && s[i] == quote:
+			quote = 0
+		case quote == 0 && (s[i] == '\'' || s[i] == '"'):
+			quote = s[i]
+		case quote == 0 && s[i] == ']':
+			return i
+		}
+	}
+	return -1
+}
+
+func (step *pathStep) parseSelector(sel string) error {
+	sel = strings.TrimSpace(sel)
+	switch {
+	case sel == "*":
+		step.wildcard = true
+		return nil
+	case strings.HasPrefix(sel, "?"):
+		return errors.New("filter expressions are not supported")
+	case strings.Contains(sel, ":") && !strings.ContainsAny(sel, `'"`):
+		bounds := strings.Split(sel, ":")
+		if len(bounds) != 2 {
+			return fmt.Errorf("invalid slice %q", sel)
+		}
+		var slice [2]*int
+		for i, b := range bounds {
+			if b = strings.TrimSpace(b); b == "" {
+				continue
+			}
+			n, err := strconv.Atoi(b)
+			if err != nil {
+				return fmt.Errorf("invalid slice %q", sel)
+			}
+			slice[i] = &n
+		}
+		step.slice = &slice
+		return nil
+	}
+
+	for _, part := range splitUnion(sel) {
+		part = strings.TrimSpace(part)
+		if len(part) >= 2 && (part[0] == '\'' || part[0] == '"') && part[len(part)-1] == part[0] {
+			name := part[1 : len(part)-1]
+			name = strings.NewReplacer(`\\`, `\`, `\'`, `'`, `\"`, `"`).Replace(name)
+			step.names = append(step.names, name)
+			continue
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return fmt.Errorf("invalid selector %q", part)
+		}
+		step.indexes = append(step.indexes, n)
+	}
+	return nil
+}
+
+// splitUnion splits a union selector on the commas outside quoted names
+func splitUnion(sel string) []string {
+	var parts []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(sel); i++ {
+		switch {
+		case quote != 0 && sel[i] == '\\':
+			i++
+		case quote != 0 && sel[i] == quote:
+			quote = 0
+		case quote == 0 && (sel[i] == '\'' || sel[i] == '"'):
+			quote = sel[i]
+		case quote == 0 && sel[i] == ',':
+			parts = append(parts, sel[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, sel[start:])
+}
+
+// Select returns the values matched by the expression in document order,
+// members of an object being visited in key order. The result is empty when
+// nothing matches.
+func (p *JSONPath) Select(value map[string]any) []any {
+	nodes := []any{value}
+	for _, step := range p.steps {
+		var next []any
+		for _, node := range nodes {
+			if step.recursive {
+				for _, d := range descendants(node) {
+					next = step.apply(d, next)
+				}
+			} else {
+				next = step.apply(node, next)
+			}
+		}
+		nodes = next
+	}
+	if nodes == nil {
+		return []any{}
+	}
+	return nodes
+}
+
+// apply appends the children of node selected by the step to out
+func (step *pathStep) apply(node any, out []any) []any {
+	switch n := node.(type) {
+	case map[string]any:
+		if step.wildcard {
+			for _, k := range sortedKeys(n) {
+				out = append(out, n[k])
+			}
+		}
+		for _, name := range step.names {
+			if v, ok := n[name]; ok {
+				out = append(out, v)
+			}
+		}
+	case []any:
+		if step.wildcard {
+			out = append(out, n...)
+		}
+		for _, i := range step.indexes {
+			if i < 0 {
+				i += len(n)
+			}
+			if i >= 0 && i < len(n) {
+				out = append(out, n[i])
+			}
+		}
+		if step.slice != nil {
+			start, end := sliceBound(step.slice[0], 0, len(n)), sliceBound(step.slice[1], len(n), len(n))
+			for i := start; i < end; i++ {
+				out = append(out, n[i])
+			}
+		}
+	}
+	return out
+}
+
+func sliceBound(b *int, def, length int) int {
+	if b == nil {
+		return def
+	}
+	i := *b
+	if i < 0 {
+		i += length
+	}
+	return min(max(i, 0), length)
+}
+
+// descendants returns node and every value nested in it
+func descendants(node any) []any {
+	out := []any{node}
+	switch n := node.(type) {
+	case map[string]any:
+		for _, k := range sortedKeys(n) {
+			out = append(out, descendants(n[k])...)
+		}
+	case []any:
+		for _, v := range n {
+			out = append(out, descendants(v)...)
+		}
+	}
+	return out
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
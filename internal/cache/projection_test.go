@@ -0,0 +1,55 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProject(t *testing.T) {
+	value := decode(t, `{"a":1,"b":{"c":2,"d":3},"e":[1,2]}`)
+
+	// Test nested fields keep their nesting
+	assert.Equal(t, decode(t, `{"a":1,"b":{"c":2}}`), Project(value, []string{"a", "b.c"}))
+
+	// Test missing fields are left out
+	assert.Equal(t, map[string]any{}, Project(value, []string{"x", "b.x", "e.0"}))
+}
+
+func TestJSONPath(t *testing.T) {
+	value := decode(t, `{
+		"user": {"name": "a", "address": {"city": "x"}},
+		"items": [{"id": 1, "name": "b"}, {"id": 2, "name": "c"}, {"id": 3}],
+		"odd key": true
+	}`)
+
+	tests := map[string][]any{
+		"$":                  {value},
+		"$.user.name":        {"a"},
+		"$['user']['name']":  {"a"},
+		"$['odd key']":       {true},
+		"$.items[0].id":      {float64(1)},
+		"$.items[-1].id":     {float64(3)},
+		"$.items[0,2].id":    {float64(1), float64(3)},
+		"$.items[1:].id":     {float64(2), float64(3)},
+		"$.items[*].name":    {"b", "c"},
+		"$.user.*":           {map[string]any{"city": "x"}, "a"},
+		"$..name":            {"b", "c", "a"},
+		"$..city":            {"x"},
+		"$.missing":          {},
+		"$.items[9]":         {},
+		"$.user.name.length": {},
+	}
+	for expr, expected := range tests {
+		p, err := ParseJSONPath(expr)
+		assert.NoError(t, err, expr)
+		assert.Equal(t, expected, p.Select(value), expr)
+	}
+}
+
+func TestParseJSONPathInvalid(t *testing.T) {
+	for _, expr := range []string{"user.name", "$.", "$[0", "$[?(@.id)]", "$[a]", "$[1:2:3]", "$x"} {
+		_, err := ParseJSONPath(expr)
+		assert.ErrorIs(t, err, ErrorInvalidPath, expr)
+	}
+}
@@ -0,0 +1,162 @@
+package cache
+
+import (
+	"encoding/base64"
+	"errors"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// DEFAULT_SCAN_LIMIT is the page size used when a scan does not set a limit
+	DEFAULT_SCAN_LIMIT = 100
+
+	// MAX_SCAN_LIMIT is the largest page a single scan returns
+	MAX_SCAN_LIMIT = 1000
+)
+
+var ErrorInvalidCursor = errors.New("invalid cursor")
+var ErrorInvalidPattern = errors.New("invalid match pattern")
+
+// ScanOptions filters and pages through the keyspace
+type ScanOptions struct {
+	// Prefix restricts the scan to keys starting with it
+	Prefix string
+	// Match is a glob where * matches any run of characters, ? a single
+	// character and [...] a character class
+	Match string
+	// Cursor is the value returned by the previous page, empty to start
+	Cursor string
+	// Limit is the maximum number of keys returned
+	Limit int
+}
+
+// KeyInfo describes a key returned by a scan
+type KeyInfo struct {
+	Key string `json:"key"`
+	// Size is the number of bytes used by the encoded value
+	Size int `json:"size"`
+	// TTL is the remaining time to live in seconds, -1 if the key does not expire
+	TTL int64 `json:"ttl"`
+}
+
+// ScanResult is a page of keys, Cursor is empty once the scan is complete
+type ScanResult struct {
+	Keys   []KeyInfo
+	Cursor string
+}
+
+// Scan returns a page of keys of the default namespace
+func Scan(opts ScanOptions) (ScanResult, error) {
+	return c.Scan(opts)
+}
+
+// Scan returns a page of keys in lexical order. The cursor encodes the last
+// key of the page so the store is only locked while a single page is built;
+// keys that exist for the whole scan are returned exactly once even if other
+// keys are written or deleted between pages. A page seeks to the cursor or
+// prefix in the sorted keys and only walks the keys it returns or skips.
+func (c *Cache) Scan(opts ScanOptions) (ScanResult, error) {
+	after, err := decodeCursor(opts.Cursor)
+	if err != nil {
+		return ScanResult{}, err
+	}
+
+	var match *regexp.Regexp
+	if opts.Match != "" {
+		if match, err = compileGlob(opts.Match); err != nil {
+			return ScanResult{}, err
+		}
+	}
+
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = DEFAULT_SCAN_LIMIT
+	}
+	limit = min(limit, MAX_SCAN_LIMIT)
+
+	now := time.Now()
+	var result ScanResult
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	start, _ := slices.BinarySearch(c.sorted, opts.Prefix)
+	if opts.Cursor != "" && after >= opts.Prefix {
+		start, _ = slices.BinarySearch(c.sorted, after+"\x00")
+	}
+	for _, k := range c.sorted[start:] {
+		if !strings.HasPrefix(k, opts.Prefix) {
+			break
+		}
+		item := c.store[k]
+		if item.expired(now) || (match != nil && !match.MatchString(k)) {
+			continue
+		}
+		if len(result.Keys) == limit {
+			result.Cursor = encodeCursor(result.Keys[limit-1].Key)
+			break
+		}
+		result.Keys = append(result.Keys, KeyInfo{Key: k, Size: item.Size(), TTL: ttlSeconds(item.TTL(now))})
+	}
+	return result, nil
+}
+
+func ttlSeconds(ttl time.Duration) int64 {
+	if ttl == 0 {
+		return -1
+	}
+	return int64(ttl.Round(time.Second) / time.Second)
+}
+
+func encodeCursor(key string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(key))
+}
+
+func decodeCursor(cursor string) (string, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", ErrorInvalidCursor
+	}
+	return string(b), nil
+}
+
+// compileGlob translates a glob pattern into an anchored regular expression
+func compileGlob(pattern string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch ch := pattern[i]; ch {
+		case '*':
+			b.WriteString("(?s:.*)")
+		case '?':
+			b.WriteString("(?s:.)")
+		case '\\':
+			if i+1 == len(pattern) {
+				return nil, ErrorInvalidPattern
+			}
+			i++
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				return nil, ErrorInvalidPattern
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		default:
+			b.WriteString(regexp.QuoteMeta(string(ch)))
+		}
+	}
+	b.WriteString("$")
+
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil, ErrorInvalidPattern
+	}
+	return re, nil
+}
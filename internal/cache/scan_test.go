@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanPagination(t *testing.T) {
+	for _, k := range []string{"scan:a", "scan:b", "scan:c", "scan:d", "scan:e"} {
+		Set(k, map[string]any{"field1": "value1"})
+	}
+
+	// Test paging through the keyspace two keys at a time
+	var keys []string
+	opts := ScanOptions{Prefix: "scan:", Limit: 2}
+	for {
+		page, err := Scan(opts)
+		assert.Nil(t, err, "Expected no error on Scan")
+		for _, k := range page.Keys {
+			keys = append(keys, k.Key)
+		}
+		if page.Cursor == "" {
+			break
+		}
+
+		// Keys written behind the cursor must not be returned again
+		Set("scan:0", map[string]any{"field1": "value1"})
+		opts.Cursor = page.Cursor
+	}
+	assert.Equal(t, []string{"scan:a", "scan:b", "scan:c", "scan:d", "scan:e"}, keys)
+}
+
+func TestScanMatch(t *testing.T) {
+	Set("match:user:1:session", map[string]any{"field1": "value1"})
+	Set("match:user:2:session", map[string]any{"field1": "value1"})
+	Set("match:user:2:profile", map[string]any{"field1": "value1"})
+
+	page, err := Scan(ScanOptions{Match: "match:user:*:session"})
+	assert.Nil(t, err, "Expected no error on Scan")
+	assert.Len(t, page.Keys, 2)
+	assert.Equal(t, "match:user:1:session", page.Keys[0].Key)
+	assert.Equal(t, "match:user:2:session", page.Keys[1].Key)
+
+	page, err = Scan(ScanOptions{Match: "match:user:[!1]:?????*"})
+	assert.Nil(t, err, "Expected no error on Scan")
+	assert.Len(t, page.Keys, 2)
+
+	_, err = Scan(ScanOptions{Match: "match:[abc"})
+	assert.Equal(t, ErrorInvalidPattern, err)
+}
+
+func TestScanDetails(t *testing.T) {
+	SetWithTTL("details:ttl", map[string]any{"field1": "value1"}, time.Minute)
+	Set("details:forever", map[string]any{"field1": "value1"})
+
+	page, err := Scan(ScanOptions{Prefix: "details:"})
+	assert.Nil(t, err, "Expected no error on Scan")
+	assert.Len(t, page.Keys, 2)
+	assert.Equal(t, int64(-1), page.Keys[0].TTL)
+	assert.Equal(t, int64(60), page.Keys[1].TTL)
+	assert.Greater(t, page.Keys[1].Size, 0)
+}
+
+func TestScanInvalidCursor(t *testing.T) {
+	_, err := Scan(ScanOptions{Cursor: "not a cursor"})
+	assert.Equal(t, ErrorInvalidCursor, err)
+}
+
+func TestScanSortedKeys(t *testing.T) {
+	ns := newCache("scanSorted")
+	for _, k := range []string{"c", "a", "e", "b", "d"} {
+		ns.Set(k, map[string]any{"field1": "value1"})
+	}
+	ns.Delete("b")
+	ns.Set("a", map[string]any{"field1": "value2"})
+
+	// Test the sorted keys follow writes and deletes
+	assert.Equal(t, []string{"a", "c", "d", "e"}, ns.sorted)
+
+	// Test a page starts after the cursor and ends with the limit
+	page, err := ns.Scan(ScanOptions{Cursor: encodeCursor("a"), Limit: 2})
+	assert.Nil(t, err, "Expected no error on Scan")
+	assert.Len(t, page.Keys, 2)
+	assert.Equal(t, "c", page.Keys[0].Key)
+	assert.Equal(t, "d", page.Keys[1].Key)
+	assert.Equal(t, encodeCursor("d"), page.Cursor)
+
+	// Test the last page has no cursor
+	page, err = ns.Scan(ScanOptions{Cursor: page.Cursor, Limit: 2})
+	assert.Nil(t, err, "Expected no error on Scan")
+	assert.Len(t, page.Keys, 1)
+	assert.Empty(t, page.Cursor)
+
+	ns.Flush()
+	assert.Empty(t, ns.sorted)
+}
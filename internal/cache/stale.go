@@ -0,0 +1,44 @@
+package cache
+
+import "time"
+
+// Staleness describes how old a value returned by GetStale is
+type Staleness struct {
+	// Stale is set when the value outlived its ttl and is served during its
+	// grace period
+	Stale bool
+	// Age is the time since the value was written
+	Age time.Duration
+}
+
+// GetStale returns the value stored under key like GetVersioned, and also
+// returns an expired value during its grace period, reporting it as stale
+func (c *Cache) GetStale(key string) (map[string]any, uint64, Staleness, error) {
+	now := time.Now()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	item, ok := c.store[key]
+	if !ok || item.dead(now) {
+		c.misses.Add(1)
+		return nil, 0, Staleness{}, ErrorKeyNotFound
+	}
+	c.hits.Add(1)
+	if item.kind != "" {
+		return nil, 0, Staleness{}, ErrorWrongType
+	}
+	staleness := Staleness{Stale: item.expired(now), Age: now.Sub(item.storedAt)}
+	return item.Value(), item.version, staleness, nil
+}
+
+// dead reports whether the item expired and its grace period is over
+func (c CacheItem) dead(now time.Time) bool {
+	return c.expired(now) && (c.staleUntil.IsZero() || !now.Before(c.staleUntil))
+}
+
+// grace returns the grace period of the item after it expires
+func (c CacheItem) grace() time.Duration {
+	if c.staleUntil.IsZero() {
+		return 0
+	}
+	return c.staleUntil.Sub(c.expiresAt)
+}
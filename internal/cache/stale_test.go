@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetStale(t *testing.T) {
+	ns := newCache("stale")
+	value := map[string]any{"a": float64(1)}
+	version, err := ns.SetWithOptions("graced", value, SetOptions{TTL: 20 * time.Millisecond, Stale: time.Hour})
+	assert.NoError(t, err)
+	_, err = ns.SetWithOptions("expiring", value, SetOptions{TTL: 20 * time.Millisecond})
+	assert.NoError(t, err)
+
+	// Test a fresh value is not stale
+	got, gotVersion, staleness, err := ns.GetStale("graced")
+	assert.NoError(t, err)
+	assert.Equal(t, value, got)
+	assert.Equal(t, version, gotVersion)
+	assert.False(t, staleness.Stale)
+
+	time.Sleep(30 * time.Millisecond)
+
+	// Test an expired value is only returned by GetStale during its grace period
+	_, err = ns.Get("graced")
+	assert.Equal(t, ErrorKeyNotFound, err)
+	got, _, staleness, err = ns.GetStale("graced")
+	assert.NoError(t, err)
+	assert.Equal(t, value, got)
+	assert.True(t, staleness.Stale)
+	assert.GreaterOrEqual(t, staleness.Age, 20*time.Millisecond)
+	_, _, _, err = ns.GetStale("expiring")
+	assert.Equal(t, ErrorKeyNotFound, err)
+
+	// Test the janitor keeps keys during their grace period
+	ns.removeExpired(time.Now())
+	assert.Equal(t, 1, len(ns.store))
+	ns.removeExpired(time.Now().Add(2 * time.Hour))
+	assert.Equal(t, 0, len(ns.store))
+}
+
+func TestUpdateKeepsGrace(t *testing.T) {
+	ns := newCache("stale")
+	_, err := ns.SetWithOptions("key", map[string]any{"a": float64(1)}, SetOptions{TTL: time.Minute, Stale: time.Hour})
+	assert.NoError(t, err)
+
+	// Test updates keep the grace period and report it for replication
+	_, opts, err := ns.Update("key", Precondition{}, func(v map[string]any) (map[string]any, error) {
+		v["a"] = float64(2)
+		return v, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, time.Hour, opts.Stale)
+}
@@ -0,0 +1,210 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// MAX_TXN_OPS is the maximum number of conditions of a transaction and the
+// default maximum number of its operations
+const MAX_TXN_OPS = 1000
+
+const (
+	// TxnSet writes the value of a key
+	TxnSet = "set"
+
+	// TxnDelete removes a key
+	TxnDelete = "delete"
+)
+
+var ErrorInvalidTxn = errors.New("invalid transaction")
+
+// Txn is a group of writes to keys of a namespace applied all or nothing.
+// Every condition must hold for the operations to be applied.
+type Txn struct {
+	If  []TxnCondition `json:"if,omitempty"`
+	Ops []TxnOp        `json:"ops"`
+	// Version forces the version of the keys written by the transaction,
+	// used when applying a transaction replicated from another worker. Keys
+	// holding this version or a newer one are left untouched.
+	Version uint64 `json:"version,omitempty"`
+}
+
+// TxnCondition requires a key to have a version or to exist or not
+type TxnCondition struct {
+	Key string `json:"key"`
+	// Version requires the current version of the key
+	Version uint64 `json:"version,omitempty"`
+	// Exists requires the key to exist when true and to be missing when false
+	Exists *bool `json:"exists,omitempty"`
+}
+
+// TxnOp is a write of a transaction
+type TxnOp struct {
+	Op    string
+	Key   string
+	Value map[string]any
+	// TTL expires a written key, zero uses the default ttl of the namespace
+	TTL  time.Duration
+	Tags []string
+}
+
+type txnOpJSON struct {
+	Op    string         `json:"op"`
+	Key   string         `json:"key"`
+	Value map[string]any `json:"value,omitempty"`
+	TTL   string         `json:"ttl,omitempty"`
+	Tags  []string       `json:"tags,omitempty"`
+}
+
+// MarshalJSON encodes the ttl as a duration string such as 30m
+func (o TxnOp) MarshalJSON() ([]byte, error) {
+	v := txnOpJSON{Op: o.Op, Key: o.Key, Value: o.Value, Tags: o.Tags}
+	if o.TTL > 0 {
+		v.TTL = o.TTL.String()
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON decodes the ttl from a duration string such as 30m
+func (o *TxnOp) UnmarshalJSON(b []byte) error {
+	var v txnOpJSON
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*o = TxnOp{Op: v.Op, Key: v.Key, Value: v.Value, Tags: v.Tags}
+	if v.TTL != "" {
+		ttl, err := time.ParseDuration(v.TTL)
+		if err != nil {
+			return err
+		}
+		if ttl < 0 {
+			return errors.New("ttl must not be negative")
+		}
+		o.TTL = ttl
+	}
+	return nil
+}
+
+// validate checks the shape of the transaction before any key is locked
+func (t Txn) validate() error {
+	if len(t.Ops) == 0 {
+		return fmt.Errorf("%w: no operations", ErrorInvalidTxn)
+	}
+	if max := limits.Load().MaxBatchSize; len(t.Ops) > max {
+		return fmt.Errorf("%w: %d operations, the limit is %d", ErrorBatchTooLarge, len(t.Ops), max)
+	}
+	if len(t.If) > MAX_TXN_OPS {
+		return fmt.Errorf("%w: more than %d conditions", ErrorInvalidTxn, MAX_TXN_OPS)
+	}
+	for i, cond := range t.If {
+		if cond.Key == "" {
+			return fmt.Errorf("%w: condition %d has no key", ErrorInvalidTxn, i)
+		}
+	}
+	for i, op := range t.Ops {
+		switch {
+		case op.Key == "":
+			return fmt.Errorf("%w: operation %d has no key", ErrorInvalidTxn, i)
+		case op.Op == TxnSet && op.Value == nil:
+			return fmt.Errorf("%w: operation %d has no value", ErrorInvalidTxn, i)
+		case op.Op != TxnSet && op.Op != TxnDelete:
+			return fmt.Errorf("%w: operation %d has unknown op %q", ErrorInvalidTxn, i, op.Op)
+		}
+	}
+	return nil
+}
+
+// Commit applies the operations of the transaction in order if every
+// condition holds, under a single lock so readers never observe part of it.
+// The keys written share the returned version. A replicated transaction
+// skips the operations on keys that held its version or a newer one before it.
+// A failed condition returns
+// ErrorPreconditionFailed naming the key and nothing is written, as when an
+// operation is over the limits.
+func (c *Cache) Commit(txn Txn) (uint64, error) {
+	if err := txn.validate(); err != nil {
+		return 0, err
+	}
+	items := make([]CacheItem, len(txn.Ops))
+	for i, op := range txn.Ops {
+		if op.Op != TxnSet {
+			continue
+		}
+		item, err := newItem(op.Value)
+		if err == nil {
+			err = checkItem(op.Key, item)
+		}
+		if err != nil {
+			return 0, err
+		}
+		item.tags = op.Tags
+		items[i] = item
+	}
+
+	now := time.Now()
+	c.mu.Lock()
+	for _, cond := range txn.If {
+		item, exists := c.store[cond.Key]
+		exists = exists && !item.expired(now)
+		if (cond.Exists != nil && *cond.Exists != exists) || (cond.Version > 0 && (!exists || item.version != cond.Version)) {
+			c.mu.Unlock()
+			return 0, fmt.Errorf("%w: %q", ErrorPreconditionFailed, cond.Key)
+		}
+	}
+
+	version := txn.Version
+	if version == 0 {
+		version = nextVersion()
+	} else {
+		observeVersion(version)
+	}
+
+	// a key is superseded when a later write of it was applied before this
+	// replicated transaction, decided before any of its operations so every
+	// operation of a key still applies in order
+	skipped := make(map[string]bool)
+	for _, op := range txn.Ops {
+		if old, exists := c.store[op.Key]; superseded(old, exists, txn.Version) {
+			skipped[op.Key] = true
+		}
+	}
+
+	events := make([]Event, 0, len(txn.Ops))
+	var written []string
+	for i, op := range txn.Ops {
+		if skipped[op.Key] {
+			continue
+		}
+		if op.Op == TxnDelete {
+			if c.remove(op.Key) {
+				events = append(events, Event{Op: EventDelete, Key: op.Key, Time: now})
+			}
+			continue
+		}
+		item := items[i]
+		ttl := op.TTL
+		if ttl == 0 {
+			ttl = c.config.DefaultTTL
+		}
+		if ttl > 0 {
+			item.expiresAt = now.Add(ttl)
+		}
+		item.version = version
+		c.put(op.Key, item)
+		written = append(written, op.Key)
+		events = append(events, Event{Op: EventSet, Key: op.Key, Value: op.Value, Version: version, Time: now})
+	}
+	evicted := c.evict(written...)
+	c.mu.Unlock()
+
+	for _, k := range evicted {
+		c.publish(Event{Op: EventEvict, Key: k, Time: now})
+	}
+	for _, e := range events {
+		c.publish(e)
+	}
+	return version, nil
+}
@@ -0,0 +1,161 @@
+package cache
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommit(t *testing.T) {
+	ns := newCache("txn")
+	v1, err := ns.SetWithOptions("order:1", map[string]any{"status": "pending"}, SetOptions{})
+	assert.NoError(t, err)
+	assert.NoError(t, ns.Set("orders:pending:1", map[string]any{"id": float64(1)}))
+
+	absent := false
+	version, err := ns.Commit(Txn{
+		If: []TxnCondition{{Key: "order:1", Version: v1}, {Key: "orders:shipped:1", Exists: &absent}},
+		Ops: []TxnOp{
+			{Op: TxnSet, Key: "order:1", Value: map[string]any{"status": "shipped"}},
+			{Op: TxnDelete, Key: "orders:pending:1"},
+			{Op: TxnSet, Key: "orders:shipped:1", Value: map[string]any{"id": float64(1)}, TTL: time.Hour},
+		},
+	})
+	assert.NoError(t, err)
+
+	// Check every operation was applied with the same version
+	value, got, err := ns.GetVersioned("order:1")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"status": "shipped"}, value)
+	assert.Equal(t, version, got)
+	got, err = ns.Version("orders:shipped:1")
+	assert.NoError(t, err)
+	assert.Equal(t, version, got)
+	_, err = ns.Get("orders:pending:1")
+	assert.Equal(t, ErrorKeyNotFound, err)
+}
+
+func TestCommitConditionFailed(t *testing.T) {
+	ns := newCache("txnConflict")
+	assert.NoError(t, ns.Set("a", map[string]any{"n": float64(1)}))
+
+	exists := true
+	_, err := ns.Commit(Txn{
+		If: []TxnCondition{{Key: "a", Version: 1}},
+		Ops: []TxnOp{
+			{Op: TxnDelete, Key: "a"},
+			{Op: TxnSet, Key: "b", Value: map[string]any{"n": float64(2)}},
+		},
+	})
+	assert.ErrorIs(t, err, ErrorPreconditionFailed)
+
+	_, err = ns.Commit(Txn{
+		If:  []TxnCondition{{Key: "missing", Exists: &exists}},
+		Ops: []TxnOp{{Op: TxnDelete, Key: "a"}},
+	})
+	assert.ErrorIs(t, err, ErrorPreconditionFailed)
+
+	// Check nothing was applied
+	_, err = ns.Get("a")
+	assert.NoError(t, err)
+	_, err = ns.Get("b")
+	assert.Equal(t, ErrorKeyNotFound, err)
+}
+
+func TestCommitInvalid(t *testing.T) {
+	ns := newCache("txnInvalid")
+	for _, txn := range []Txn{
+		{},
+		{Ops: []TxnOp{{Op: TxnSet, Key: "a"}}},
+		{Ops: []TxnOp{{Op: TxnDelete}}},
+		{Ops: []TxnOp{{Op: "incr", Key: "a"}}},
+		{If: []TxnCondition{{}}, Ops: []TxnOp{{Op: TxnDelete, Key: "a"}}},
+	} {
+		_, err := ns.Commit(txn)
+		assert.ErrorIs(t, err, ErrorInvalidTxn)
+	}
+}
+
+func TestCommitEvictsOtherKeys(t *testing.T) {
+	ns := newCache("txnEvict")
+	ns.Configure(NamespaceConfig{MaxKeys: 2})
+	assert.NoError(t, ns.Set("old", map[string]any{"n": float64(0)}))
+
+	// Test the keys written by the transaction are not evicted by it
+	_, err := ns.Commit(Txn{Ops: []TxnOp{
+		{Op: TxnSet, Key: "a", Value: map[string]any{"n": float64(1)}},
+		{Op: TxnSet, Key: "b", Value: map[string]any{"n": float64(2)}},
+	}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, ns.Keys(""))
+}
+
+func TestTxnJSON(t *testing.T) {
+	var txn Txn
+	err := json.Unmarshal([]byte(`{"ops":[{"op":"set","key":"a","value":{"n":1},"ttl":"30s","tags":["t"]}]}`), &txn)
+	assert.NoError(t, err)
+	assert.Equal(t, 30*time.Second, txn.Ops[0].TTL)
+
+	b, err := json.Marshal(txn)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"ops":[{"op":"set","key":"a","value":{"n":1},"ttl":"30s","tags":["t"]}]}`, string(b))
+}
+
+func TestCommitReplicatedVersionOrder(t *testing.T) {
+	v1 := nextVersion() + 1000
+	v2 := v1 + 1
+	_, err := SetWithOptions("txnReordered:a", map[string]any{"n": float64(2)}, SetOptions{Version: v2})
+	assert.Nil(t, err, "Expected no error on SetWithOptions")
+
+	// Test the operations of an older replicated transaction skip newer keys
+	_, err = c.Commit(Txn{Version: v1, Ops: []TxnOp{
+		{Op: TxnSet, Key: "txnReordered:a", Value: map[string]any{"n": float64(1)}},
+		{Op: TxnSet, Key: "txnReordered:b", Value: map[string]any{"n": float64(1)}},
+	}})
+	assert.Nil(t, err, "Expected no error on Commit")
+	value, version, _ := c.GetVersioned("txnReordered:a")
+	assert.Equal(t, map[string]any{"n": float64(2)}, value)
+	assert.Equal(t, v2, version)
+	_, version, _ = c.GetVersioned("txnReordered:b")
+	assert.Equal(t, v1, version)
+
+	// Test an older replicated delete does not remove a newer write
+	_, err = c.Commit(Txn{Version: v1, Ops: []TxnOp{{Op: TxnDelete, Key: "txnReordered:a"}}})
+	assert.Nil(t, err, "Expected no error on Commit")
+	_, err = c.Get("txnReordered:a")
+	assert.Nil(t, err, "Expected the newer key to be kept")
+}
+
+func TestCommitReplicatedRepeatedKeys(t *testing.T) {
+	txn := Txn{Ops: []TxnOp{
+		{Op: TxnSet, Key: "txnRepeated:a", Value: map[string]any{"v": float64(1)}},
+		{Op: TxnSet, Key: "txnRepeated:a", Value: map[string]any{"v": float64(2)}},
+		{Op: TxnSet, Key: "txnRepeated:b", Value: map[string]any{"v": float64(1)}},
+		{Op: TxnDelete, Key: "txnRepeated:b"},
+	}}
+	origin, replica := newCache("txnOrigin"), newCache("txnReplica")
+	version, err := origin.Commit(txn)
+	assert.Nil(t, err, "Expected no error on Commit")
+
+	// Test a replica applies every operation of a key like the origin did
+	txn.Version = version
+	_, err = replica.Commit(txn)
+	assert.Nil(t, err, "Expected no error on Commit")
+	for _, ns := range []*Cache{origin, replica} {
+		value, _ := ns.Get("txnRepeated:a")
+		assert.Equal(t, map[string]any{"v": float64(2)}, value, ns.Name())
+		_, err = ns.Get("txnRepeated:b")
+		assert.Equal(t, ErrorKeyNotFound, err, ns.Name())
+	}
+
+	// Test the transaction delivered again does not undo a newer write
+	replica.Set("txnRepeated:b", map[string]any{"v": float64(3)})
+	_, err = replica.Commit(txn)
+	assert.Nil(t, err, "Expected no error on Commit")
+	value, _ := replica.Get("txnRepeated:a")
+	assert.Equal(t, map[string]any{"v": float64(2)}, value)
+	value, _ = replica.Get("txnRepeated:b")
+	assert.Equal(t, map[string]any{"v": float64(3)}, value)
+}
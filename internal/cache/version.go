@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"errors"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+var ErrorPreconditionFailed = errors.New("precondition failed")
+var ErrorStaleVersion = errors.New("stale version")
+
+// lastVersion is the most recent version assigned or observed by this worker
+var lastVersion atomic.Uint64
+
+// nextVersion returns a version greater than any seen by this worker. Versions
+// start from the current time so they keep increasing across restarts and a
+// key that is deleted and written again never reuses a version.
+func nextVersion() uint64 {
+	for {
+		last := lastVersion.Load()
+		next := max(last+1, uint64(time.Now().UnixNano()))
+		if lastVersion.CompareAndSwap(last, next) {
+			return next
+		}
+	}
+}
+
+// observeVersion records a version assigned by another worker
+func observeVersion(v uint64) {
+	for {
+		last := lastVersion.Load()
+		if v <= last || lastVersion.CompareAndSwap(last, v) {
+			return
+		}
+	}
+}
+
+// superseded reports whether a write replicated with version must be ignored
+// because the key already holds that version or a newer one. Replicated
+// writes are sent concurrently and may arrive out of order, the most recent
+// version wins so every worker converges on the same value.
+func superseded(item CacheItem, exists bool, version uint64) bool {
+	return version > 0 && exists && item.version >= version
+}
+
+// Precondition restricts a write to the current state of the key, the zero
+// value allows every write
+type Precondition struct {
+	// Exists requires the key to exist
+	Exists bool
+	// Absent requires the key to not exist
+	Absent bool
+	// Versions requires the current version of the key to be one of them
+	Versions []uint64
+}
+
+// satisfied reports whether the write is allowed given the current item
+func (p Precondition) satisfied(item CacheItem, exists bool) bool {
+	if p.Absent && exists {
+		return false
+	}
+	if p.Exists && !exists {
+		return false
+	}
+	if len(p.Versions) > 0 && (!exists || !slices.Contains(p.Versions, item.version)) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVersionIncreases(t *testing.T) {
+	v1, err := SetWithOptions("versioned", map[string]any{"field1": "value1"}, SetOptions{})
+	assert.Nil(t, err, "Expected no error on SetWithOptions")
+	v2, err := SetWithOptions("versioned", map[string]any{"field1": "value2"}, SetOptions{})
+	assert.Nil(t, err, "Expected no error on SetWithOptions")
+	assert.Greater(t, v2, v1)
+
+	_, version, err := c.GetVersioned("versioned")
+	assert.Nil(t, err, "Expected no error on GetVersioned")
+	assert.Equal(t, v2, version)
+}
+
+func TestCompareAndSwap(t *testing.T) {
+	v1, _ := SetWithOptions("cas", map[string]any{"field1": "value1"}, SetOptions{})
+
+	// Test a write with the current version succeeds
+	v2, err := SetWithOptions("cas", map[string]any{"field1": "value2"}, SetOptions{If: Precondition{Versions: []uint64{v1}}})
+	assert.Nil(t, err, "Expected no error on matching version")
+
+	// Test a write with a stale version fails without writing
+	_, err = SetWithOptions("cas", map[string]any{"field1": "value3"}, SetOptions{If: Precondition{Versions: []uint64{v1}}})
+	assert.Equal(t, ErrorPreconditionFailed, err)
+	value, version, _ := c.GetVersioned("cas")
+	assert.Equal(t, map[string]any{"field1": "value2"}, value)
+	assert.Equal(t, v2, version)
+
+	// Test a versioned write to a missing key fails
+	_, err = SetWithOptions("casMissing", map[string]any{"field1": "value1"}, SetOptions{If: Precondition{Versions: []uint64{v1}}})
+	assert.Equal(t, ErrorPreconditionFailed, err)
+}
+
+func TestSetIfAbsentAndExists(t *testing.T) {
+	ifAbsent := SetOptions{If: Precondition{Absent: true}}
+	_, err := SetWithOptions("absent", map[string]any{"field1": "value1"}, ifAbsent)
+	assert.Nil(t, err, "Expected no error when the key is absent")
+	_, err = SetWithOptions("absent", map[string]any{"field1": "value2"}, ifAbsent)
+	assert.Equal(t, ErrorPreconditionFailed, err)
+
+	ifExists := SetOptions{If: Precondition{Exists: true}}
+	_, err = SetWithOptions("exists", map[string]any{"field1": "value1"}, ifExists)
+	assert.Equal(t, ErrorPreconditionFailed, err)
+}
+
+func TestForcedVersion(t *testing.T) {
+	forced := nextVersion() + 1000
+
+	// Test a replicated write keeps the version of the origin
+	version, err := SetWithOptions("forced", map[string]any{"field1": "value1"}, SetOptions{Version: forced})
+	assert.Nil(t, err, "Expected no error on SetWithOptions")
+	assert.Equal(t, forced, version)
+
+	// Test later local writes get a greater version
+	assert.Greater(t, nextVersion(), forced)
+}
+
+func TestReplicatedVersionOrder(t *testing.T) {
+	v1 := nextVersion() + 1000
+	v2 := v1 + 1
+
+	// Test a replicated write arriving after a newer one is ignored
+	_, err := SetWithOptions("reordered", map[string]any{"field1": "value2"}, SetOptions{Version: v2})
+	assert.Nil(t, err, "Expected no error on SetWithOptions")
+	version, err := SetWithOptions("reordered", map[string]any{"field1": "value1"}, SetOptions{Version: v1})
+	assert.Equal(t, ErrorStaleVersion, err)
+	assert.Equal(t, v2, version)
+	value, version, _ := c.GetVersioned("reordered")
+	assert.Equal(t, map[string]any{"field1": "value2"}, value)
+	assert.Equal(t, v2, version)
+
+	// Test a redelivered write is ignored too
+	_, err = SetWithOptions("reordered", map[string]any{"field1": "value1"}, SetOptions{Version: v2})
+	assert.Equal(t, ErrorStaleVersion, err)
+}
@@ -0,0 +1,416 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vishaldc/go-cache/internal/apierror"
+	"github.com/vishaldc/go-cache/internal/cache"
+)
+
+// DEFAULT_TIMEOUT is the timeout used for non streaming requests
+const DEFAULT_TIMEOUT = 5 * time.Second
+
+var ErrorKeyNotFound = errors.New("key not found")
+
+// Client talks to the client facing port of a go-cache worker
+type Client struct {
+	baseURL string
+	ns      string
+	http    *http.Client
+	stream  *http.Client
+	// credentials are the headers authenticating every request
+	credentials http.Header
+}
+
+// New creates a client for the worker listening on addr, e.g. http://localhost:8086
+func New(addr string) *Client {
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+	return &Client{
+		baseURL: strings.TrimRight(addr, "/"),
+		http:    &http.Client{Timeout: DEFAULT_TIMEOUT},
+		stream:  &http.Client{},
+	}
+}
+
+// Namespace returns a client whose key operations address the named
+// namespace, the empty name is the default namespace
+func (c *Client) Namespace(name string) *Client {
+	nc := *c
+	nc.ns = name
+	return &nc
+}
+
+// WithAPIKey returns a client authenticating its requests with an API key
+func (c *Client) WithAPIKey(key string) *Client {
+	nc := *c
+	nc.credentials = http.Header{}
+	nc.credentials.Set(API_KEY_HEADER, key)
+	return &nc
+}
+
+// WithToken returns a client authenticating its requests with a JWT bearer
+// token
+func (c *Client) WithToken(token string) *Client {
+	nc := *c
+	nc.credentials = http.Header{}
+	nc.credentials.Set("Authorization", "Bearer "+token)
+	return &nc
+}
+
+// Namespaces lists every namespace of the worker with its limits and usage
+func (c *Client) Namespaces() ([]cache.NamespaceStats, error) {
+	resp, err := c.do(http.MethodGet, "/ns", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var stats []cache.NamespaceStats
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// Configure creates the client namespace across the cluster or replaces its
+// limits, namespaces must be configured before their keys are written
+func (c *Client) Configure(config cache.NamespaceConfig) (*cache.NamespaceStats, error) {
+	if c.ns == "" {
+		return nil, errors.New("configure requires a namespace")
+	}
+	b, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.do(http.MethodPut, "/ns/"+url.PathEscape(c.ns), nil, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var stats cache.NamespaceStats
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// Flush removes every key of the client namespace across the cluster
+func (c *Client) Flush() (int, error) {
+	if c.ns == "" {
+		return 0, errors.New("flush requires a namespace")
+	}
+	resp, err := c.do(http.MethodPost, "/ns/"+url.PathEscape(c.ns)+"/flush", nil, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	var result deletedResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, err
+	}
+	return result.Deleted, nil
+}
+
+// Get returns the value stored under key
+func (c *Client) Get(key string) (map[string]any, error) {
+	resp, err := c.do(http.MethodGet, c.cachePath("/cache"), url.Values{"key": {key}}, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var value map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// Set stores value under key
+func (c *Client) Set(key string, value map[string]any) error {
+	return c.SetWithOptions(key, value, cache.SetOptions{})
+}
+
+// SetWithOptions stores value under key with the given expiry and tags
+func (c *Client) SetWithOptions(key string, value map[string]any, opts cache.SetOptions) error {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	params := url.Values{"key": {key}}
+	if opts.TTL > 0 {
+		params.Set("ttl", opts.TTL.String())
+	}
+	header := http.Header{}
+	if len(opts.Tags) > 0 {
+		header.Set(TAGS_HEADER, strings.Join(opts.Tags, ","))
+	}
+	resp, err := c.doWithHeader(http.MethodPost, c.cachePath("/cache"), params, header, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// Delete removes key
+func (c *Client) Delete(key string) error {
+	resp, err := c.do(http.MethodDelete, c.cachePath("/cache"), url.Values{"key": {key}}, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// Invalidate deletes every key matching sel across the cluster and returns
+// the number of keys removed from the worker
+func (c *Client) Invalidate(sel cache.Selector) (int, error) {
+	path, params := c.cachePath("/cache"), sel.Params()
+	if sel.Prefix == "" && sel.Match == "" && sel.Tag != "" {
+		path, params = c.cachePath("/cache/tags/"+url.PathEscape(sel.Tag)), nil
+	}
+	resp, err := c.do(http.MethodDelete, path, params, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	var result deletedResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, err
+	}
+	return result.Deleted, nil
+}
+
+// Scan returns a single page of keys, pass the returned cursor back in opts
+// to fetch the next page. Sizes and ttls are included when details is set.
+func (c *Client) Scan(opts cache.ScanOptions, details bool) (*KeysResponse, error) {
+	params := url.Values{}
+	for name, v := range map[string]string{"prefix": opts.Prefix, "match": opts.Match, "cursor": opts.Cursor} {
+		if v != "" {
+			params.Set(name, v)
+		}
+	}
+	if opts.Limit > 0 {
+		params.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	if details {
+		params.Set("details", "true")
+	}
+
+	resp, err := c.do(http.MethodGet, c.cachePath("/cache/keys"), params, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var keys KeysResponse
+	if err := json.NewDecoder(resp.Body).Decode(&keys); err != nil {
+		return nil, err
+	}
+	return &keys, nil
+}
+
+// Keys lists every key starting with prefix, following cursors until the scan completes
+func (c *Client) Keys(prefix string) ([]string, error) {
+	var keys []string
+	opts := cache.ScanOptions{Prefix: prefix}
+	for {
+		page, err := c.Scan(opts, false)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, page.Keys...)
+		if page.Cursor == "" {
+			return keys, nil
+		}
+		opts.Cursor = page.Cursor
+	}
+}
+
+// Dump calls fn for every entry in the cache whose key starts with prefix
+func (c *Client) Dump(prefix string, fn func(cache.Entry) error) error {
+	resp, err := c.doStream(context.Background(), c.cachePath("/cache/dump"), url.Values{"prefix": {prefix}})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return decodeLines(resp.Body, func(decoder *json.Decoder) error {
+		var e cache.Entry
+		if err := decoder.Decode(&e); err != nil {
+			return err
+		}
+		return fn(e)
+	})
+}
+
+// Members returns the worker and the pool it replicates to
+func (c *Client) Members() (*Membership, error) {
+	resp, err := c.do(http.MethodGet, "/cluster/members", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var members Membership
+	if err := json.NewDecoder(resp.Body).Decode(&members); err != nil {
+		return nil, err
+	}
+	return &members, nil
+}
+
+// Watch calls fn for every change applied to keys starting with prefix until
+// ctx is cancelled or the server closes the stream
+func (c *Client) Watch(ctx context.Context, prefix string, fn func(cache.Event) error) error {
+	resp, err := c.doStream(ctx, c.cachePath("/cache/watch"), url.Values{"prefix": {prefix}})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	err = decodeLines(resp.Body, func(decoder *json.Decoder) error {
+		var e cache.Event
+		if err := decoder.Decode(&e); err != nil {
+			return err
+		}
+		return fn(e)
+	})
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+func (c *Client) do(method, path string, params url.Values, body io.Reader) (*http.Response, error) {
+	return c.doWithHeader(method, path, params, nil, body)
+}
+
+func (c *Client) doWithHeader(method, path string, params url.Values, header http.Header, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, c.url(path, params), body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	for name, values := range c.credentials {
+		req.Header[name] = values
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkResponse(resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *Client) doStream(ctx context.Context, path string, params url.Values) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url(path, params), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/x-ndjson")
+	for name, values := range c.credentials {
+		req.Header[name] = values
+	}
+
+	resp, err := c.stream.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkResponse(resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// cachePath prefixes a key route with the namespace of the client
+func (c *Client) cachePath(path string) string {
+	if c.ns == "" {
+		return path
+	}
+	return "/ns/" + url.PathEscape(c.ns) + path
+}
+
+func (c *Client) url(path string, params url.Values) string {
+	u := c.baseURL + path
+	if len(params) > 0 {
+		u += "?" + params.Encode()
+	}
+	return u
+}
+
+// checkResponse closes the body and returns an error for non successful
+// responses. A missing key, a key_not_found code or a 404 without a body, is
+// ErrorKeyNotFound, any other 404 such as an unknown namespace is an *Error.
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode < http.StatusBadRequest {
+		return nil
+	}
+	defer resp.Body.Close()
+
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	e := &Error{Status: resp.Status, StatusCode: resp.StatusCode, RequestID: resp.Header.Get(apierror.REQUEST_ID_HEADER)}
+	var body apierror.Error
+	if err := json.Unmarshal(msg, &body); err == nil && body.Code != "" {
+		e.Code, e.Message, e.Key = body.Code, body.Message, body.Key
+		if body.RequestID != "" {
+			e.RequestID = body.RequestID
+		}
+	} else {
+		e.Message = strings.TrimSpace(string(msg))
+	}
+
+	if e.Code == cache.Code(cache.ErrorKeyNotFound) || (resp.StatusCode == http.StatusNotFound && e.Code == "" && e.Message == "") {
+		return ErrorKeyNotFound
+	}
+	return e
+}
+
+// Error is a request the worker failed, with the stable code of the failure
+// such as precondition_failed or value_too_large
+type Error struct {
+	Status     string
+	StatusCode int
+	Code       string
+	Message    string
+	Key        string
+	RequestID  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Status, e.Message)
+}
+
+// decodeLines calls decode until the newline delimited JSON stream in r is exhausted
+func decodeLines(r io.Reader, decode func(*json.Decoder) error) error {
+	decoder := json.NewDecoder(bufio.NewReader(r))
+	for decoder.More() {
+		if err := decode(decoder); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+package client
+
+import (
+	"context"
+	"go/build"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vishaldc/go-cache/internal/auth"
+	"github.com/vishaldc/go-cache/internal/cache"
+	"github.com/vishaldc/go-cache/internal/handlers"
+)
+
+func newTestServer(t *testing.T) *Client {
+	mux := http.NewServeMux()
+	for _, prefix := range []string{"", "/ns/{name}"} {
+		mux.HandleFunc("GET "+prefix+"/cache", handlers.GetHandler)
+		mux.HandleFunc("POST "+prefix+"/cache", handlers.PostHandler)
+		mux.HandleFunc("DELETE "+prefix+"/cache", handlers.DeleteHandler)
+		mux.HandleFunc("DELETE "+prefix+"/cache/tags/{tag}", handlers.TagDeleteHandler)
+		mux.HandleFunc("GET "+prefix+"/cache/keys", handlers.KeysHandler)
+		mux.HandleFunc("GET "+prefix+"/cache/dump", handlers.DumpHandler)
+		mux.HandleFunc("GET "+prefix+"/cache/watch", handlers.WatchHandler)
+	}
+	mux.HandleFunc("GET /ns", handlers.NamespacesHandler)
+	mux.HandleFunc("PUT /ns/{name}", handlers.NamespacePutHandler)
+	mux.HandleFunc("POST /ns/{name}/flush", handlers.FlushHandler)
+	mux.HandleFunc("GET /cluster/members", handlers.MembersHandler)
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return New(server.URL)
+}
+
+func TestSetGetDelete(t *testing.T) {
+	c := newTestServer(t)
+	value := map[string]any{"field1": "value1", "field2": float64(2)}
+
+	// Test Set
+	err := c.Set("client:key", value)
+	assert.NoError(t, err)
+
+	// Test Get
+	got, err := c.Get("client:key")
+	assert.NoError(t, err)
+	assert.Equal(t, value, got)
+
+	// Test Delete
+	err = c.Delete("client:key")
+	assert.NoError(t, err)
+
+	// Test Get after Delete
+	_, err = c.Get("client:key")
+	assert.Equal(t, ErrorKeyNotFound, err)
+}
+
+func TestKeysAndDump(t *testing.T) {
+	c := newTestServer(t)
+	assert.NoError(t, c.Set("clientDump:a", map[string]any{"n": float64(1)}))
+	assert.NoError(t, c.Set("clientDump:b", map[string]any{"n": float64(2)}))
+
+	// Test Keys
+	keys, err := c.Keys("clientDump:")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"clientDump:a", "clientDump:b"}, keys)
+
+	// Test Dump
+	var entries []cache.Entry
+	err = c.Dump("clientDump:", func(e cache.Entry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []cache.Entry{
+		{Key: "clientDump:a", Value: map[string]any{"n": float64(1)}},
+		{Key: "clientDump:b", Value: map[string]any{"n": float64(2)}},
+	}, entries)
+}
+
+func TestMembers(t *testing.T) {
+	c := newTestServer(t)
+
+	members, err := c.Members()
+	assert.NoError(t, err)
+	assert.Nil(t, members.Self)
+	assert.Empty(t, members.Pool)
+}
+
+func TestWatch(t *testing.T) {
+	c := newTestServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	events := make(chan cache.Event, 16)
+	done := make(chan error)
+	go func() {
+		done <- c.Watch(ctx, "clientWatch:", func(e cache.Event) error {
+			events <- e
+			return nil
+		})
+	}()
+
+	// Keep writing until the watcher is subscribed and reports the change
+	var e cache.Event
+	for received := false; !received; {
+		assert.NoError(t, c.Set("clientWatch:key", map[string]any{"field1": "value1"}))
+		select {
+		case e = <-events:
+			received = true
+		case <-time.After(20 * time.Millisecond):
+		case <-ctx.Done():
+			t.Fatal("timed out waiting for event")
+		}
+	}
+	assert.Equal(t, cache.EventSet, e.Op)
+	assert.Equal(t, "clientWatch:key", e.Key)
+
+	// Cancelling the context ends the watch without an error
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestInvalidate(t *testing.T) {
+	c := newTestServer(t)
+	tagged := cache.SetOptions{Tags: []string{"clientTag"}}
+	assert.NoError(t, c.SetWithOptions("clientBulk:a", map[string]any{"n": float64(1)}, tagged))
+	assert.NoError(t, c.SetWithOptions("clientBulk:b", map[string]any{"n": float64(2)}, tagged))
+	assert.NoError(t, c.Set("clientBulk:c", map[string]any{"n": float64(3)}))
+
+	// Test Invalidate by tag
+	deleted, err := c.Invalidate(cache.Selector{Tag: "clientTag"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, deleted)
+
+	// Test Invalidate by prefix
+	deleted, err = c.Invalidate(cache.Selector{Prefix: "clientBulk:"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+}
+
+func TestNamespace(t *testing.T) {
+	c := newTestServer(t)
+	ns := c.Namespace("clientNs")
+
+	// Test an unknown namespace is not created by a write nor reported as a missing key
+	err := ns.Set("key", map[string]any{"n": float64(1)})
+	var apiErr *Error
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+		assert.Equal(t, "namespace_not_found", apiErr.Code)
+	}
+	_, err = ns.Get("key")
+	assert.ErrorAs(t, err, &apiErr)
+	assert.NotEqual(t, ErrorKeyNotFound, err)
+
+	// Test Configure creates the namespace
+	created, err := ns.Configure(cache.NamespaceConfig{MaxKeys: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, "clientNs", created.Name)
+	assert.Equal(t, 10, created.Config.MaxKeys)
+
+	// Test the namespace is isolated from the default one
+	assert.NoError(t, ns.Set("key", map[string]any{"n": float64(1)}))
+	_, err = c.Get("key")
+	assert.Equal(t, ErrorKeyNotFound, err)
+
+	// Test Namespaces reports the usage
+	stats, err := c.Namespaces()
+	assert.NoError(t, err)
+	found := false
+	for _, s := range stats {
+		if s.Name == "clientNs" {
+			found = true
+			assert.Equal(t, 1, s.Keys)
+		}
+	}
+	assert.True(t, found, "Expected namespace to be listed")
+
+	// Test Flush
+	deleted, err := ns.Flush()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+}
+
+func TestCredentials(t *testing.T) {
+	var headers []http.Header
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /cache", func(w http.ResponseWriter, r *http.Request) {
+		headers = append(headers, r.Header)
+		w.Write([]byte(`{}`))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	c := New(server.URL)
+
+	// Test the credentials are sent with every request of the client
+	_, err := c.WithAPIKey("key").Get("a")
+	assert.NoError(t, err)
+	_, err = c.WithToken("token").Namespace("").Get("a")
+	assert.NoError(t, err)
+	_, err = c.Get("a")
+	assert.NoError(t, err)
+	assert.Equal(t, "key", headers[0].Get("X-API-Key"))
+	assert.Equal(t, "Bearer token", headers[1].Get("Authorization"))
+	assert.Empty(t, headers[2].Get("X-API-Key"))
+	assert.Empty(t, headers[2].Get("Authorization"))
+}
+
+func TestError(t *testing.T) {
+	c := newTestServer(t)
+
+	// Test a failed request returns the code and message of the error
+	err := c.Namespace("not a namespace").Set("client:key", map[string]any{"a": 1})
+	var e *Error
+	assert.ErrorAs(t, err, &e)
+	assert.Equal(t, http.StatusBadRequest, e.StatusCode)
+	assert.Equal(t, "invalid_parameter", e.Code)
+	assert.Equal(t, "400 Bad Request: invalid namespace in request", err.Error())
+
+	// Test a 404 without a body is a missing key
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	t.Cleanup(server.Close)
+	_, err = New(server.URL).Get("client:key")
+	assert.Equal(t, ErrorKeyNotFound, err)
+}
+
+func TestWireTypes(t *testing.T) {
+	// Test the headers match the ones read by the worker
+	assert.Equal(t, handlers.TAGS_HEADER, TAGS_HEADER)
+	assert.Equal(t, auth.API_KEY_HEADER, API_KEY_HEADER)
+
+	// Test the client does not link the server packages
+	pkg, err := build.ImportDir(".", 0)
+	assert.NoError(t, err)
+	for _, server := range []string{"auth", "handlers", "registry", "store", "loader", "metrics", "tracing"} {
+		assert.NotContains(t, pkg.Imports, "github.com/vishaldc/go-cache/internal/"+server)
+	}
+}
@@ -0,0 +1,47 @@
+package client
+
+import (
+	"time"
+
+	"github.com/vishaldc/go-cache/internal/cache"
+)
+
+// The headers and response bodies of the worker API are declared here rather
+// than imported from the server packages, which would link the stores,
+// registry, metrics and tracing into every client binary.
+
+// TAGS_HEADER lists the comma separated tags a written key is grouped under
+const TAGS_HEADER = "X-Cache-Tags"
+
+// API_KEY_HEADER is the header carrying a static API key
+const API_KEY_HEADER = "X-API-Key"
+
+// KeysResponse is a page of keys returned by a scan
+type KeysResponse struct {
+	Keys []string `json:"keys"`
+	// Entries holds the size and ttl of each key when details are requested
+	Entries []cache.KeyInfo `json:"entries,omitempty"`
+	// Cursor is passed back to fetch the next page, empty on the last page
+	Cursor string `json:"cursor,omitempty"`
+}
+
+// deletedResponse is the response body of invalidations and flushes
+type deletedResponse struct {
+	Deleted int `json:"deleted"`
+}
+
+// Worker is a member of the cluster
+type Worker struct {
+	ID        int       `json:"id"`
+	Hostname  string    `json:"hostname"`
+	Port      int       `json:"port"`
+	SyncPort  int       `json:"sync_port"`
+	CreatedAt time.Time `json:"created_at"`
+	Updated   time.Time `json:"updated_at"`
+}
+
+// Membership is the worker and the pool it replicates to
+type Membership struct {
+	Self *Worker  `json:"self"`
+	Pool []Worker `json:"pool"`
+}
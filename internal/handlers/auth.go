@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/vishaldc/go-cache/internal/apierror"
+	"github.com/vishaldc/go-cache/internal/auth"
+	"github.com/vishaldc/go-cache/internal/cache"
+	"github.com/vishaldc/go-cache/internal/log"
+	"go.uber.org/zap"
+)
+
+// allowed answers 403 and returns false unless the principal of the request
+// is granted op on key. auth.Require only checks the key and prefix
+// parameters, so handlers touching other keys check each of them.
+func allowed(w http.ResponseWriter, r *http.Request, ns *cache.Cache, op auth.Operation, key string) bool {
+	if auth.Allowed(r.Context(), op, ns.Name(), key) {
+		return true
+	}
+	log.Logger.Warn("permission denied", zap.String("operation", string(op)), zap.String("namespace", ns.Name()), zap.String("key", key))
+	apierror.Write(w, r, http.StatusForbidden, apierror.CodePermissionDenied, "permission denied", key)
+	return false
+}
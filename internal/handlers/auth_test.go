@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vishaldc/go-cache/internal/auth"
+	"github.com/vishaldc/go-cache/internal/cache"
+)
+
+// serveAuth routes a request with the API key of a principal limited to the
+// public: prefix through handler wrapped by auth.Require
+func serveAuth(t *testing.T, op auth.Operation, pattern string, handler http.HandlerFunc, req *http.Request) *httptest.ResponseRecorder {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "auth.json")
+	config := `{"api_keys": [{"name": "public", "key": "p-key", "permissions": [{"namespace": "", "prefix": "public:", "operations": ["admin"]}]}]}`
+	assert.NoError(t, os.WriteFile(path, []byte(config), 0o600))
+	assert.NoError(t, auth.Setup(path))
+	t.Cleanup(auth.Disable)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(pattern, auth.Require(op, handler))
+	req.Header.Set(auth.API_KEY_HEADER, "p-key")
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestTxnHandlerPrefixBypass(t *testing.T) {
+	// Test a prefix parameter does not authorise the keys of the operations
+	body := `{"ops": [{"op": "set", "key": "secret:1", "value": {"a": 1}}]}`
+	req := httptest.NewRequest("POST", "/cache/txn?prefix=public:", bytes.NewBufferString(body))
+	rr := serveAuth(t, auth.Write, "POST /cache/txn", TxnHandler, req)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	_, err := cache.Get("secret:1")
+	assert.Equal(t, cache.ErrorKeyNotFound, err)
+
+	// Test the keys of the conditions are checked too
+	body = `{"if": [{"key": "secret:2", "exists": true}], "ops": [{"op": "set", "key": "public:1", "value": {"a": 1}}]}`
+	req = httptest.NewRequest("POST", "/cache/txn?prefix=public:", bytes.NewBufferString(body))
+	assert.Equal(t, http.StatusForbidden, serveAuth(t, auth.Write, "POST /cache/txn", TxnHandler, req).Code)
+
+	// Test a transaction within the prefix is committed
+	body = `{"ops": [{"op": "set", "key": "public:1", "value": {"a": 1}}]}`
+	req = httptest.NewRequest("POST", "/cache/txn?prefix=public:", bytes.NewBufferString(body))
+	assert.Equal(t, http.StatusOK, serveAuth(t, auth.Write, "POST /cache/txn", TxnHandler, req).Code)
+}
+
+func TestTagDeleteHandlerPrefixBypass(t *testing.T) {
+	cache.SetWithOptions("public:tagged", map[string]any{"a": 1}, cache.SetOptions{Tags: []string{"authTag"}})
+	cache.SetWithOptions("secret:tagged", map[string]any{"a": 1}, cache.SetOptions{Tags: []string{"authTag"}})
+
+	// Test every key matched by the tag is checked before any is deleted
+	req := httptest.NewRequest("DELETE", "/cache/tags/authTag?prefix=public:", nil)
+	rr := serveAuth(t, auth.Delete, "DELETE /cache/tags/{tag}", TagDeleteHandler, req)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	_, err := cache.Get("public:tagged")
+	assert.NoError(t, err)
+	_, err = cache.Get("secret:tagged")
+	assert.NoError(t, err)
+}
+
+func TestQueryHandlerPrefixBypass(t *testing.T) {
+	post(t, "public:indexed", `{"owner":"authOwner"}`, nil)
+	post(t, "secret:indexed", `{"owner":"authOwner"}`, nil)
+	ns, _ := cache.Namespace("")
+	assert.NoError(t, ns.CreateIndex("authOwner", "owner"))
+	defer ns.DropIndex("authOwner")
+
+	// Test only the entries the principal may read are returned
+	req := httptest.NewRequest("GET", "/cache/query?index=authOwner&eq=authOwner&prefix=public:", nil)
+	rr := serveAuth(t, auth.Read, "GET /cache/query", QueryHandler, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"entries":[{"key":"public:indexed","value":{"owner":"authOwner"}}]}`, rr.Body.String())
+}
+
+func TestFlushHandlerPrefixBypass(t *testing.T) {
+	post(t, "secret:flushed", `{"a":1}`, nil)
+
+	// Test a permission limited to a prefix can not flush the namespace
+	req := httptest.NewRequest("POST", "/ns/flush?prefix=public:", nil)
+	rr := serveAuth(t, auth.Admin, "POST /ns/flush", FlushHandler, req)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	_, err := cache.Get("secret:flushed")
+	assert.NoError(t, err)
+}
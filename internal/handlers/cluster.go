@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/vishaldc/go-cache/internal/apierror"
+	"github.com/vishaldc/go-cache/internal/log"
+	"github.com/vishaldc/go-cache/internal/notify"
+	"github.com/vishaldc/go-cache/internal/registry"
+	"go.uber.org/zap"
+)
+
+// MembersHandler returns the current worker and the pool of workers it replicates to
+func MembersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		log.Logger.Warn("invalid request method", zap.String("method", r.Method))
+		apierror.Write(w, r, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "invalid request method", "")
+		return
+	}
+
+	reg := registry.GetRegistry()
+	members := registry.Membership{
+		Self: reg.GetSelfWorker(),
+		Pool: reg.GetPool(),
+	}
+
+	responseBody, err := json.Marshal(members)
+	if err != nil {
+		log.Logger.Error("failed to marshall response", zap.Error(err))
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "failed to marshall response", "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(responseBody)
+}
+
+// NotificationsHandler returns the state of the Postgres invalidation listener
+func NotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		log.Logger.Warn("invalid request method", zap.String("method", r.Method))
+		apierror.Write(w, r, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "invalid request method", "")
+		return
+	}
+	writeJSON(w, r, notify.Stats())
+}
@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMembersHandler(t *testing.T) {
+	// Create a request to pass to our handler
+	req, err := http.NewRequest("GET", "/cluster/members", nil)
+	assert.NoError(t, err)
+
+	// Create a ResponseRecorder to record the response
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(MembersHandler)
+
+	// Call the handler
+	handler.ServeHTTP(rr, req)
+
+	// Check the status code
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	// The registry is not set up in tests so there is no self worker or pool
+	assert.JSONEq(t, `{"self":null,"pool":[]}`, rr.Body.String())
+}
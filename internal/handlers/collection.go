@@ -0,0 +1,290 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+
+	"github.com/vishaldc/go-cache/internal/apierror"
+	"github.com/vishaldc/go-cache/internal/cache"
+	"github.com/vishaldc/go-cache/internal/log"
+	"github.com/vishaldc/go-cache/internal/registry"
+	"github.com/vishaldc/go-cache/internal/tracing"
+	"go.uber.org/zap"
+)
+
+// CollectionHandler applies the collection write named by the last segment
+// of the path, e.g. POST /cache/lpush?key=queue with {"values":[1,2]}. The
+// operation rather than the resulting collection is replicated to the pool.
+func CollectionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apierror.Write(w, r, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "invalid request method", "")
+		return
+	}
+
+	ns, ok := namespaceFor(w, r)
+	if !ok {
+		return
+	}
+
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		log.Logger.Warn("missing key in request")
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeMissingKey, "missing key in request", "")
+		return
+	}
+
+	ttl, err := parseTTL(r.URL.Query().Get("ttl"))
+	if err != nil {
+		log.Logger.Warn("invalid ttl in request", zap.Error(err))
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidParameter, "invalid ttl in request", key)
+		return
+	}
+
+	var op cache.CollectionOp
+	// pops take no body
+	limitBody(w, r)
+	if err := json.NewDecoder(r.Body).Decode(&op); tooLarge(w, r, key, err) {
+		return
+	} else if err != nil && err != io.EOF {
+		log.Logger.Error("invalid request body", zap.Error(err))
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidBody, "invalid request body", key)
+		return
+	}
+	op.Op = path.Base(r.URL.Path)
+	if count := r.URL.Query().Get("count"); count != "" {
+		if op.Count, err = strconv.Atoi(count); err != nil {
+			log.Logger.Warn("invalid count in request", zap.Error(err))
+			apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidParameter, "invalid count in request", key)
+			return
+		}
+	}
+
+	opts := cache.CollectionOptions{TTL: ttl}
+	before := ns.Snapshot(key)
+	_, span := tracing.StartCache(r.Context(), op.Op, ns.Name(), key)
+	result, version, err := ns.Apply(key, op, opts)
+	tracing.End(span, err)
+	if !collectionError(w, r, key, err) {
+		return
+	}
+	// a collection left empty was removed and has no version
+	if err := persist(ns, change{before: before, version: version}); err != nil {
+		apierror.Write(w, r, http.StatusBadGateway, apierror.CodeStoreFailed, "failed to write to store", key)
+		return
+	}
+
+	if version > 0 {
+		w.Header().Set("ETag", formatETag(version))
+	}
+	opts.Version = version
+	ctx := context.WithoutCancel(r.Context())
+	reg := registry.GetRegistry()
+
+	registry.Go(func() {
+		err := reg.ApplyInPool(ctx, ns.Name(), key, op, opts)
+		if err != nil {
+			log.Logger.Error("failed to write to pool", zap.Error(err))
+		}
+	})
+
+	log.Logger.Info("collection request completed", zap.String("key", key), zap.String("op", op.Op))
+	switch op.Op {
+	case cache.OpHSet, cache.OpSAdd, cache.OpZAdd:
+		writeJSON(w, r, map[string]int{"added": result.Count})
+	case cache.OpHDel, cache.OpSRem, cache.OpZRem:
+		writeJSON(w, r, map[string]int{"removed": result.Count})
+	case cache.OpLPush, cache.OpRPush:
+		writeJSON(w, r, map[string]int{"length": result.Count})
+	default:
+		writeJSON(w, r, map[string][]any{"values": result.Values})
+	}
+}
+
+// SyncCollectionHandler applies a collection write replicated from another worker
+func SyncCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apierror.Write(w, r, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "invalid request method", "")
+		return
+	}
+
+	ns, ok := createNamespaceFor(w, r)
+	if !ok {
+		return
+	}
+
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		log.Logger.Warn("missing key in request")
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeMissingKey, "missing key in request", "")
+		return
+	}
+
+	ttl, err := parseTTL(r.URL.Query().Get("ttl"))
+	if err != nil {
+		log.Logger.Warn("invalid ttl in request", zap.Error(err))
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidParameter, "invalid ttl in request", key)
+		return
+	}
+
+	var op cache.CollectionOp
+	limitSyncBody(w, r)
+	err = json.NewDecoder(r.Body).Decode(&op)
+	if tooLarge(w, r, key, err) {
+		return
+	}
+	if err != nil {
+		log.Logger.Error("invalid request body", zap.Error(err))
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidBody, "invalid request body", key)
+		return
+	}
+
+	version, err := strconv.ParseUint(r.URL.Query().Get("version"), 10, 64)
+	if err != nil {
+		version = 0
+	}
+
+	_, span := tracing.StartCache(r.Context(), op.Op, ns.Name(), key)
+	_, _, err = ns.Apply(key, op, cache.CollectionOptions{TTL: ttl, Version: version})
+	tracing.End(span, err)
+	if !collectionError(w, r, key, err) {
+		return
+	}
+
+	log.Logger.Info("sync request completed", zap.String("key", key))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HGetHandler returns the fields of a hash named by the field parameters,
+// every field when none is given
+func HGetHandler(w http.ResponseWriter, r *http.Request) {
+	ns, key, ok := collectionRead(w, r)
+	if !ok {
+		return
+	}
+	fields, err := ns.HGet(key, r.URL.Query()["field"]...)
+	if !collectionError(w, r, key, err) {
+		return
+	}
+	writeJSON(w, r, fields)
+}
+
+// LRangeHandler returns the values of a list between the start and stop
+// positions inclusive, the whole list by default
+func LRangeHandler(w http.ResponseWriter, r *http.Request) {
+	ns, key, ok := collectionRead(w, r)
+	if !ok {
+		return
+	}
+	start, stop, ok := parseRange(w, r)
+	if !ok {
+		return
+	}
+	values, err := ns.LRange(key, start, stop)
+	if !collectionError(w, r, key, err) {
+		return
+	}
+	writeJSON(w, r, map[string][]any{"values": values})
+}
+
+// SMembersHandler returns the sorted members of a set
+func SMembersHandler(w http.ResponseWriter, r *http.Request) {
+	ns, key, ok := collectionRead(w, r)
+	if !ok {
+		return
+	}
+	members, err := ns.SMembers(key)
+	if !collectionError(w, r, key, err) {
+		return
+	}
+	writeJSON(w, r, map[string][]string{"members": members})
+}
+
+// ZRangeHandler returns the members of a sorted set between the start and
+// stop ranks inclusive, from the highest score when rev=true
+func ZRangeHandler(w http.ResponseWriter, r *http.Request) {
+	ns, key, ok := collectionRead(w, r)
+	if !ok {
+		return
+	}
+	start, stop, ok := parseRange(w, r)
+	if !ok {
+		return
+	}
+	members, err := ns.ZRange(key, start, stop, r.URL.Query().Get("rev") == "true")
+	if !collectionError(w, r, key, err) {
+		return
+	}
+	writeJSON(w, r, map[string][]cache.ZMember{"members": members})
+}
+
+// collectionRead validates a collection read and returns its namespace and key
+func collectionRead(w http.ResponseWriter, r *http.Request) (*cache.Cache, string, bool) {
+	if r.Method != http.MethodGet {
+		log.Logger.Warn("invalid request method", zap.String("method", r.Method))
+		apierror.Write(w, r, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "invalid request method", "")
+		return nil, "", false
+	}
+
+	ns, ok := namespaceFor(w, r)
+	if !ok {
+		return nil, "", false
+	}
+
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		log.Logger.Warn("missing key in request")
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeMissingKey, "missing key in request", "")
+		return nil, "", false
+	}
+	return ns, key, true
+}
+
+// collectionError writes the response for a failed collection operation and
+// reports whether err is nil
+func collectionError(w http.ResponseWriter, r *http.Request, key string, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case err == cache.ErrorKeyNotFound:
+		log.Logger.Warn("key not found in cache", zap.String("key", key))
+		apierror.Write(w, r, http.StatusNotFound, cache.Code(err), "key not found in cache", key)
+	case err == cache.ErrorWrongType:
+		log.Logger.Warn("wrong type", zap.String("key", key))
+		apierror.Write(w, r, http.StatusConflict, cache.Code(err), err.Error(), key)
+	case errors.Is(err, cache.ErrorInvalidOperation):
+		log.Logger.Warn("invalid collection operation", zap.Error(err))
+		apierror.Write(w, r, http.StatusBadRequest, cache.Code(err), err.Error(), key)
+	case tooLarge(w, r, key, err):
+	default:
+		log.Logger.Error("failed to apply collection operation", zap.Error(err))
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "failed to apply collection operation", key)
+	}
+	return false
+}
+
+// parseRange parses the inclusive start and stop positions, defaulting to
+// the whole collection
+func parseRange(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	start, stop := 0, -1
+	var err error
+	if s := r.URL.Query().Get("start"); s != "" {
+		if start, err = strconv.Atoi(s); err != nil {
+			log.Logger.Warn("invalid start in request", zap.Error(err))
+			apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidParameter, "invalid start in request", "")
+			return 0, 0, false
+		}
+	}
+	if s := r.URL.Query().Get("stop"); s != "" {
+		if stop, err = strconv.Atoi(s); err != nil {
+			log.Logger.Warn("invalid stop in request", zap.Error(err))
+			apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidParameter, "invalid stop in request", "")
+			return 0, 0, false
+		}
+	}
+	return start, stop, true
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func collectionRequest(t *testing.T, handler http.HandlerFunc, method, url, body string) *httptest.ResponseRecorder {
+	// Create a request to pass to our handler
+	req, err := http.NewRequest(method, url, bytes.NewBufferString(body))
+	assert.NoError(t, err)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestCollectionHandlerList(t *testing.T) {
+	rr := collectionRequest(t, CollectionHandler, "POST", "/cache/rpush?key=handlerQueue", `{"values":["a","b","c"]}`)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"length":3}`, rr.Body.String())
+	assert.NotEmpty(t, rr.Header().Get("ETag"))
+
+	// Test a pop without a body
+	rr = collectionRequest(t, CollectionHandler, "POST", "/cache/lpop?key=handlerQueue&count=2", "")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"values":["a","b"]}`, rr.Body.String())
+
+	rr = collectionRequest(t, LRangeHandler, "GET", "/cache/lrange?key=handlerQueue", "")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"values":["c"]}`, rr.Body.String())
+}
+
+func TestCollectionHandlerHashSetZSet(t *testing.T) {
+	rr := collectionRequest(t, CollectionHandler, "POST", "/cache/hset?key=handlerHash", `{"fields":{"a":1,"b":{"c":2}}}`)
+	assert.JSONEq(t, `{"added":2}`, rr.Body.String())
+	rr = collectionRequest(t, HGetHandler, "GET", "/cache/hget?key=handlerHash&field=b", "")
+	assert.JSONEq(t, `{"b":{"c":2}}`, rr.Body.String())
+
+	rr = collectionRequest(t, CollectionHandler, "POST", "/cache/sadd?key=handlerSet", `{"members":["b","a"]}`)
+	assert.JSONEq(t, `{"added":2}`, rr.Body.String())
+	rr = collectionRequest(t, CollectionHandler, "POST", "/cache/srem?key=handlerSet", `{"members":["b"]}`)
+	assert.JSONEq(t, `{"removed":1}`, rr.Body.String())
+	rr = collectionRequest(t, SMembersHandler, "GET", "/cache/smembers?key=handlerSet", "")
+	assert.JSONEq(t, `{"members":["a"]}`, rr.Body.String())
+
+	rr = collectionRequest(t, CollectionHandler, "POST", "/cache/zadd?key=handlerBoard", `{"scores":{"a":1,"b":5,"c":3}}`)
+	assert.JSONEq(t, `{"added":3}`, rr.Body.String())
+	rr = collectionRequest(t, ZRangeHandler, "GET", "/cache/zrange?key=handlerBoard&stop=1&rev=true", "")
+	assert.JSONEq(t, `{"members":[{"member":"b","score":5},{"member":"c","score":3}]}`, rr.Body.String())
+}
+
+func TestCollectionHandlerErrors(t *testing.T) {
+	post(t, "handlerObject", `{"field1":"value1"}`, nil)
+	collectionRequest(t, CollectionHandler, "POST", "/cache/sadd?key=handlerMembers", `{"members":["a"]}`)
+
+	tests := []struct {
+		handler     http.HandlerFunc
+		method, url string
+		status      int
+	}{
+		{CollectionHandler, "POST", "/cache/sadd", http.StatusBadRequest},
+		{CollectionHandler, "POST", "/cache/sadd?key=handlerObject", http.StatusConflict},
+		{CollectionHandler, "POST", "/cache/rpop?key=handlerQueue&count=x", http.StatusBadRequest},
+		{CollectionHandler, "POST", "/cache/lset?key=handlerQueue", http.StatusBadRequest},
+		{SMembersHandler, "GET", "/cache/smembers?key=handlerObject", http.StatusConflict},
+		{SMembersHandler, "GET", "/cache/smembers?key=handlerMissing", http.StatusNotFound},
+		{LRangeHandler, "GET", "/cache/lrange?key=handlerQueue&start=x", http.StatusBadRequest},
+		{GetHandler, "GET", "/cache?key=handlerMembers", http.StatusConflict},
+	}
+	for _, tt := range tests {
+		rr := collectionRequest(t, tt.handler, tt.method, tt.url, `{"members":["a"]}`)
+		assert.Equal(t, tt.status, rr.Code, tt.url)
+	}
+}
+
+func TestSyncCollectionHandler(t *testing.T) {
+	rr := collectionRequest(t, SyncCollectionHandler, "POST", "/cache/sync/collection?key=syncList&version=42", `{"op":"rpush","values":[1]}`)
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	// Check the operation was applied
+	rr = collectionRequest(t, LRangeHandler, "GET", "/cache/lrange?key=syncList", "")
+	assert.JSONEq(t, `{"values":[1]}`, rr.Body.String())
+}
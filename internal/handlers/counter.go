@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/vishaldc/go-cache/internal/apierror"
+	"github.com/vishaldc/go-cache/internal/cache"
+	"github.com/vishaldc/go-cache/internal/log"
+	"github.com/vishaldc/go-cache/internal/registry"
+	"github.com/vishaldc/go-cache/internal/tracing"
+	"go.uber.org/zap"
+)
+
+// CounterResponse is the response body of an increment
+type CounterResponse struct {
+	Value any `json:"value"`
+}
+
+// IncrHandler atomically adds by, default 1, to the counter stored under key
+func IncrHandler(w http.ResponseWriter, r *http.Request) {
+	counter(w, r, false)
+}
+
+// DecrHandler atomically subtracts by, default 1, from the counter stored
+// under key
+func DecrHandler(w http.ResponseWriter, r *http.Request) {
+	counter(w, r, true)
+}
+
+// counter applies an increment locally and replicates the increment rather
+// than the resulting value, so increments applied concurrently on different
+// workers add up
+func counter(w http.ResponseWriter, r *http.Request, negate bool) {
+	if r.Method != http.MethodPost {
+		apierror.Write(w, r, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "invalid request method", "")
+		return
+	}
+
+	ns, ok := namespaceFor(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	key := query.Get("key")
+	if key == "" {
+		log.Logger.Warn("missing key in request")
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeMissingKey, "missing key in request", "")
+		return
+	}
+
+	by := query.Get("by")
+	if by == "" {
+		by = "1"
+	}
+	if negate {
+		by = negateNumber(by)
+	}
+
+	ttl, err := parseTTL(query.Get("ttl"))
+	if err != nil {
+		log.Logger.Warn("invalid ttl in request", zap.Error(err))
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidParameter, "invalid ttl in request", key)
+		return
+	}
+
+	opts := cache.IncrOptions{Field: query.Get("field"), TTL: ttl}
+	if opts.Min, err = parseBound(query.Get("min")); err != nil {
+		log.Logger.Warn("invalid min in request", zap.Error(err))
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidParameter, "invalid min in request", key)
+		return
+	}
+	if opts.Max, err = parseBound(query.Get("max")); err != nil {
+		log.Logger.Warn("invalid max in request", zap.Error(err))
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidParameter, "invalid max in request", key)
+		return
+	}
+
+	before := ns.Snapshot(key)
+	_, span := tracing.StartCache(r.Context(), "incr", ns.Name(), key)
+	value, version, err := incr(ns, key, by, opts)
+	tracing.End(span, err)
+	if err == errInvalidIncrement {
+		log.Logger.Warn("invalid by in request", zap.String("by", by))
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidParameter, "invalid by in request", key)
+		return
+	}
+	if err == cache.ErrorNotCounter || err == cache.ErrorOutOfRange || err == cache.ErrorWrongType {
+		log.Logger.Info("increment rejected", zap.String("key", key), zap.Error(err))
+		apierror.Write(w, r, http.StatusConflict, cache.Code(err), err.Error(), key)
+		return
+	}
+	if tooLarge(w, r, key, err) {
+		return
+	}
+	if err != nil {
+		log.Logger.Error("failed to increment cache", zap.Error(err))
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "failed to increment cache", key)
+		return
+	}
+
+	if err := persist(ns, change{before: before, version: version}); err != nil {
+		apierror.Write(w, r, http.StatusBadGateway, apierror.CodeStoreFailed, "failed to write to store", key)
+		return
+	}
+
+	// replicas apply the same increment without the bounds, which were
+	// already checked here, and keep the version of the latest increment
+	opts.Min, opts.Max = nil, nil
+	opts.Version = version
+	ctx := context.WithoutCancel(r.Context())
+	reg := registry.GetRegistry()
+
+	registry.Go(func() {
+		err := reg.IncrInPool(ctx, ns.Name(), key, by, opts)
+		if err != nil {
+			log.Logger.Error("failed to write to pool", zap.Error(err))
+		}
+	})
+
+	log.Logger.Info("increment request completed", zap.String("key", key), zap.String("by", by))
+	w.Header().Set("ETag", formatETag(version))
+	writeJSON(w, r, CounterResponse{Value: value})
+}
+
+// SyncIncrHandler applies an increment replicated from another worker
+func SyncIncrHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apierror.Write(w, r, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "invalid request method", "")
+		return
+	}
+
+	ns, ok := createNamespaceFor(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	key := query.Get("key")
+	if key == "" {
+		log.Logger.Warn("missing key in request")
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeMissingKey, "missing key in request", "")
+		return
+	}
+
+	ttl, err := parseTTL(query.Get("ttl"))
+	if err != nil {
+		log.Logger.Warn("invalid ttl in request", zap.Error(err))
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidParameter, "invalid ttl in request", key)
+		return
+	}
+
+	version, err := strconv.ParseUint(query.Get("version"), 10, 64)
+	if err != nil {
+		version = 0
+	}
+
+	_, span := tracing.StartCache(r.Context(), "incr", ns.Name(), key)
+	_, _, err = incr(ns, key, query.Get("by"), cache.IncrOptions{Field: query.Get("field"), TTL: ttl, Version: version})
+	tracing.End(span, err)
+	if err == errInvalidIncrement {
+		log.Logger.Warn("invalid by in request", zap.String("by", query.Get("by")))
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidParameter, "invalid by in request", key)
+		return
+	}
+	if err == cache.ErrorNotCounter || err == cache.ErrorOutOfRange || err == cache.ErrorWrongType {
+		log.Logger.Warn("increment rejected", zap.String("key", key), zap.Error(err))
+		apierror.Write(w, r, http.StatusConflict, cache.Code(err), err.Error(), key)
+		return
+	}
+	if tooLarge(w, r, key, err) {
+		return
+	}
+	if err != nil {
+		log.Logger.Error("failed to increment cache", zap.Error(err))
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "failed to increment cache", key)
+		return
+	}
+
+	log.Logger.Info("sync request completed", zap.String("key", key))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+var errInvalidIncrement = errors.New("invalid increment")
+
+// incr applies an integer increment when by is an integer and a float
+// increment otherwise, so every worker picks the same operation for a
+// replicated increment. An integer increment of a fractional counter adds
+// to the fraction.
+func incr(ns *cache.Cache, key, by string, opts cache.IncrOptions) (any, uint64, error) {
+	if n, err := strconv.ParseInt(by, 10, 64); err == nil {
+		return ns.Incr(key, n, opts)
+	}
+	f, err := strconv.ParseFloat(by, 64)
+	if err != nil {
+		return nil, 0, errInvalidIncrement
+	}
+	value, version, err := ns.IncrFloat(key, f, opts)
+	return value, version, err
+}
+
+// negateNumber negates a number without parsing it, which keeps an integer
+// an integer and a float a float
+func negateNumber(s string) string {
+	if strings.HasPrefix(s, "-") {
+		return s[1:]
+	}
+	return "-" + strings.TrimPrefix(s, "+")
+}
+
+// parseBound parses an optional counter bound
+func parseBound(s string) (*float64, error) {
+	if s == "" {
+		return nil, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIncrHandler(t *testing.T) {
+	// Create a request to pass to our handler
+	req, err := http.NewRequest("POST", "/cache/incr?key=incrKey&by=5", nil)
+	assert.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(IncrHandler)
+	handler.ServeHTTP(rr, req)
+
+	// Check the status code and the new value
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"value":5}`, rr.Body.String())
+	assert.NotEmpty(t, rr.Header().Get("ETag"))
+
+	// Test DecrHandler with the default step
+	req, err = http.NewRequest("POST", "/cache/decr?key=incrKey", nil)
+	assert.NoError(t, err)
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(DecrHandler).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"value":4}`, rr.Body.String())
+
+	// Test a float increment
+	req, err = http.NewRequest("POST", "/cache/incr?key=incrKey&by=0.5", nil)
+	assert.NoError(t, err)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"value":4.5}`, rr.Body.String())
+
+	// Test the default step still applies to a fractional counter
+	req, err = http.NewRequest("POST", "/cache/incr?key=incrKey", nil)
+	assert.NoError(t, err)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"value":5.5}`, rr.Body.String())
+}
+
+func TestIncrHandlerErrors(t *testing.T) {
+	post(t, "incrText", `{"value":"text"}`, nil)
+
+	for url, status := range map[string]int{
+		"/cache/incr":                          http.StatusBadRequest,
+		"/cache/incr?key=incrErr&by=one":       http.StatusBadRequest,
+		"/cache/incr?key=incrErr&max=x":        http.StatusBadRequest,
+		"/cache/incr?key=incrText":             http.StatusConflict,
+		"/cache/incr?key=incrBound&by=3&max=2": http.StatusConflict,
+	} {
+		req, err := http.NewRequest("POST", url, nil)
+		assert.NoError(t, err)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(IncrHandler).ServeHTTP(rr, req)
+		assert.Equal(t, status, rr.Code, url)
+	}
+}
+
+func TestSyncIncrHandler(t *testing.T) {
+	// Create a request to pass to our handler
+	req, err := http.NewRequest("POST", "/cache/sync/incr?key=syncIncrKey&by=2&version=42", nil)
+	assert.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(SyncIncrHandler).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	// Check the increment was applied with the replicated version
+	req, err = http.NewRequest("GET", "/cache?key=syncIncrKey", nil)
+	assert.NoError(t, err)
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(GetHandler).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"value":2}`, rr.Body.String())
+	assert.Equal(t, `"42"`, rr.Header().Get("ETag"))
+}
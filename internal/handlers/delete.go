@@ -1,53 +1,82 @@
 package handlers
 
 import (
+	"context"
 	"net/http"
 
+	"github.com/vishaldc/go-cache/internal/apierror"
 	"github.com/vishaldc/go-cache/internal/cache"
 	"github.com/vishaldc/go-cache/internal/log"
 	"github.com/vishaldc/go-cache/internal/registry"
+	"github.com/vishaldc/go-cache/internal/store"
+	"github.com/vishaldc/go-cache/internal/tracing"
 	"go.uber.org/zap"
 )
 
 func DeleteHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodDelete {
-		http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+		apierror.Write(w, r, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "invalid request method", "")
+		return
+	}
+
+	ns, ok := namespaceFor(w, r)
+	if !ok {
 		return
 	}
 
 	key := r.URL.Query().Get("key")
 	if key == "" {
+		// without a key the request may select a group of keys instead
+		if sel := cache.SelectorFromParams(r.URL.Query()); !sel.IsEmpty() {
+			invalidate(w, r, ns, sel)
+			return
+		}
 		log.Logger.Warn("missing key in request")
-		http.Error(w, "missing key in request", http.StatusBadRequest)
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeMissingKey, "missing key in request", "")
 		return
 	}
-	cache.Delete(key)
+	// the key is kept in the cache while the write through store holds it
+	if err := store.Delete(ns.Name(), key); err != nil {
+		apierror.Write(w, r, http.StatusBadGateway, apierror.CodeStoreFailed, "failed to delete from store", key)
+		return
+	}
+	_, span := tracing.StartCache(r.Context(), "delete", ns.Name(), key)
+	ns.Delete(key)
+	tracing.End(span, nil)
 	w.WriteHeader(http.StatusNoContent)
 
+	ctx := context.WithoutCancel(r.Context())
 	reg := registry.GetRegistry()
-	go func() {
-		err := reg.DeleteFromPool(key)
+	registry.Go(func() {
+		err := reg.DeleteFromPool(ctx, ns.Name(), key)
 		if err != nil {
 			log.Logger.Error("failed to write to pool", zap.Error(err))
 		}
-	}()
+	})
 
 	log.Logger.Debug("delete request completed", zap.String("key", key))
 }
 
 func SyncDeleteHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodDelete {
-		http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+		apierror.Write(w, r, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "invalid request method", "")
+		return
+	}
+
+	ns, ok := createNamespaceFor(w, r)
+	if !ok {
 		return
 	}
 
 	key := r.URL.Query().Get("key")
 	if key == "" {
 		log.Logger.Warn("missing key in request")
-		http.Error(w, "missing key in request", http.StatusBadRequest)
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeMissingKey, "missing key in request", "")
 		return
 	}
-	cache.Delete(key)
+	_, span := tracing.StartCache(r.Context(), "delete", ns.Name(), key)
+	ns.Delete(key)
+	tracing.End(span, nil)
 	w.WriteHeader(http.StatusNoContent)
 
 	log.Logger.Debug("delete request completed", zap.String("key", key))
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/vishaldc/go-cache/internal/apierror"
+	"github.com/vishaldc/go-cache/internal/auth"
+	"github.com/vishaldc/go-cache/internal/cache"
+	"github.com/vishaldc/go-cache/internal/log"
+	"go.uber.org/zap"
+)
+
+// DumpHandler streams every entry in the cache as newline delimited JSON.
+// Keys are read one at a time so the store is never locked for the whole dump.
+func DumpHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		log.Logger.Warn("invalid request method", zap.String("method", r.Method))
+		apierror.Write(w, r, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "invalid request method", "")
+		return
+	}
+
+	ns, ok := namespaceFor(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	encoder := json.NewEncoder(w)
+	count := 0
+	for _, key := range ns.Keys(r.URL.Query().Get("prefix")) {
+		if !auth.Allowed(r.Context(), auth.Read, ns.Name(), key) {
+			continue
+		}
+		value, err := ns.Get(key)
+		// collections have no object value and are left out of the dump
+		if err == cache.ErrorKeyNotFound || err == cache.ErrorWrongType {
+			continue
+		}
+		if err != nil {
+			log.Logger.Error("failed to get cache", zap.String("key", key), zap.Error(err))
+			return
+		}
+		if err := encoder.Encode(cache.Entry{Key: key, Value: value}); err != nil {
+			log.Logger.Error("failed to write dump", zap.Error(err))
+			return
+		}
+		count++
+	}
+	log.Logger.Info("dump request completed", zap.Int("count", count))
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vishaldc/go-cache/internal/cache"
+)
+
+func TestDumpHandler(t *testing.T) {
+	// Set up test cache items
+	cache.Set("dump:1", map[string]any{"field1": "value1"})
+	cache.Set("dump:2", map[string]any{"field1": "value2"})
+
+	// Create a request to pass to our handler
+	req, err := http.NewRequest("GET", "/cache/dump?prefix=dump:", nil)
+	assert.NoError(t, err)
+
+	// Create a ResponseRecorder to record the response
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(DumpHandler)
+
+	// Call the handler
+	handler.ServeHTTP(rr, req)
+
+	// Check the status code
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/x-ndjson", rr.Header().Get("Content-Type"))
+
+	// Check the response body has one entry per line
+	expected := `{"key":"dump:1","value":{"field1":"value1"}}` + "\n" +
+		`{"key":"dump:2","value":{"field1":"value2"}}` + "\n"
+	assert.Equal(t, expected, rr.Body.String())
+}
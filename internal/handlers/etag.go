@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/vishaldc/go-cache/internal/cache"
+)
+
+var errInvalidETag = errors.New("invalid entity tag")
+
+// formatETag renders a version as a strong entity tag
+func formatETag(version uint64) string {
+	return `"` + strconv.FormatUint(version, 10) + `"`
+}
+
+// parseETags parses an If-Match or If-None-Match header into the listed
+// versions, any reports a * wildcard. Weak tags are only accepted when weak is
+// set since If-Match requires the strong comparison.
+func parseETags(header string, weak bool) (versions []uint64, any bool, err error) {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+		if tag == "*" {
+			any = true
+			continue
+		}
+		if strings.HasPrefix(tag, "W/") {
+			if !weak {
+				// a weak tag never matches with the strong comparison
+				versions = append(versions, 0)
+				continue
+			}
+			tag = tag[2:]
+		}
+		if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+			return nil, false, errInvalidETag
+		}
+		v, err := strconv.ParseUint(tag[1:len(tag)-1], 10, 64)
+		if err != nil {
+			// tags not issued by this cache can never match
+			v = 0
+		}
+		versions = append(versions, v)
+	}
+	return versions, any, nil
+}
+
+// writePrecondition reads the If-Match and If-None-Match headers of a write
+func writePrecondition(r *http.Request) (cache.Precondition, error) {
+	var p cache.Precondition
+	if h := r.Header.Get("If-Match"); h != "" {
+		versions, any, err := parseETags(h, false)
+		if err != nil {
+			return p, err
+		}
+		p.Exists = any
+		p.Versions = versions
+		if !any && len(versions) == 0 {
+			return p, errInvalidETag
+		}
+	}
+	if h := r.Header.Get("If-None-Match"); h != "" {
+		// only set-if-absent is supported for writes
+		if strings.TrimSpace(h) != "*" {
+			return p, errInvalidETag
+		}
+		p.Absent = true
+	}
+	return p, nil
+}
+
+// notModified reports whether the If-None-Match header of a read matches version
+func notModified(r *http.Request, version uint64) bool {
+	h := r.Header.Get("If-None-Match")
+	if h == "" {
+		return false
+	}
+	versions, any, err := parseETags(h, true)
+	if err != nil {
+		return false
+	}
+	if any {
+		return true
+	}
+	for _, v := range versions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vishaldc/go-cache/internal/cache"
+)
+
+func post(t *testing.T, key, body string, header http.Header) *httptest.ResponseRecorder {
+	req, err := http.NewRequest("POST", "/cache?key="+key, bytes.NewBuffer([]byte(body)))
+	assert.NoError(t, err)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(PostHandler).ServeHTTP(rr, req)
+	return rr
+}
+
+func TestGetHandlerETag(t *testing.T) {
+	rr := post(t, "etagKey", `{"field1":"value1"}`, nil)
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	etag := rr.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	// Check the read returns the same entity tag as the write
+	req, err := http.NewRequest("GET", "/cache?key=etagKey", nil)
+	assert.NoError(t, err)
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(GetHandler).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, etag, rr.Header().Get("ETag"))
+
+	// Check a conditional read with the current tag is not modified
+	req.Header.Set("If-None-Match", `"1", `+etag)
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(GetHandler).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotModified, rr.Code)
+	assert.Empty(t, rr.Body.String())
+
+	// Check a conditional read with a stale tag returns the value
+	req.Header.Set("If-None-Match", `"1"`)
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(GetHandler).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestPostHandlerIfMatch(t *testing.T) {
+	rr := post(t, "casKey", `{"field1":"value1"}`, nil)
+	etag := rr.Header().Get("ETag")
+
+	// Check a write with the current tag succeeds and returns a new tag
+	rr = post(t, "casKey", `{"field1":"value2"}`, http.Header{"If-Match": {etag}})
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	assert.NotEqual(t, etag, rr.Header().Get("ETag"))
+
+	// Check a write with the stale tag is rejected
+	rr = post(t, "casKey", `{"field1":"value3"}`, http.Header{"If-Match": {etag}})
+	assert.Equal(t, http.StatusPreconditionFailed, rr.Code)
+	value, _ := cache.Get("casKey")
+	assert.Equal(t, map[string]any{"field1": "value2"}, value)
+
+	// Check a weak tag never matches
+	rr = post(t, "casKey", `{"field1":"value3"}`, http.Header{"If-Match": {"W/" + etag}})
+	assert.Equal(t, http.StatusPreconditionFailed, rr.Code)
+}
+
+func TestPostHandlerIfNoneMatch(t *testing.T) {
+	ifNoneMatch := http.Header{"If-None-Match": {"*"}}
+
+	// Check set-if-absent succeeds once
+	rr := post(t, "absentKey", `{"field1":"value1"}`, ifNoneMatch)
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	rr = post(t, "absentKey", `{"field1":"value2"}`, ifNoneMatch)
+	assert.Equal(t, http.StatusPreconditionFailed, rr.Code)
+
+	// Check only the wildcard is supported for writes
+	rr = post(t, "absentKey", `{"field1":"value2"}`, http.Header{"If-None-Match": {`"1"`}})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestSyncPostHandlerVersion(t *testing.T) {
+	// Create a replicated write carrying the version of the origin
+	req, err := http.NewRequest("POST", "/cache/sync?key=syncVersioned&version=42", bytes.NewBuffer([]byte(`{"field1":"value1"}`)))
+	assert.NoError(t, err)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(SyncPostHandler).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	// Check the replica serves the same entity tag
+	req, err = http.NewRequest("GET", "/cache?key=syncVersioned", nil)
+	assert.NoError(t, err)
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(GetHandler).ServeHTTP(rr, req)
+	assert.Equal(t, `"42"`, rr.Header().Get("ETag"))
+}
@@ -3,49 +3,147 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
+	"strings"
 
+	"github.com/vishaldc/go-cache/internal/apierror"
 	"github.com/vishaldc/go-cache/internal/cache"
+	"github.com/vishaldc/go-cache/internal/loader"
 	"github.com/vishaldc/go-cache/internal/log"
+	"github.com/vishaldc/go-cache/internal/tracing"
 	"go.uber.org/zap"
 )
 
+const (
+	// STALE_WARNING is the Warning header of a response served stale
+	STALE_WARNING = `110 - "Response is Stale"`
+
+	// REVALIDATION_FAILED_WARNING is the Warning header of a response served
+	// stale because refreshing it failed
+	REVALIDATION_FAILED_WARNING = `111 - "Revalidation Failed"`
+)
+
 func GetHandler(w http.ResponseWriter, r *http.Request) {
 
 	if r.Method != http.MethodGet {
 		log.Logger.Warn("invalid request method", zap.String("method", r.Method))
-		http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+		apierror.Write(w, r, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "invalid request method", "")
+		return
+	}
+
+	ns, ok := namespaceFor(w, r)
+	if !ok {
 		return
 	}
 
 	key := r.URL.Query().Get("key")
 	if key == "" {
 		log.Logger.Warn("missing key in request")
-		http.Error(w, "missing key in request", http.StatusBadRequest)
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeMissingKey, "missing key in request", "")
 		return
 	}
 	defer log.Logger.Info("get request completed", zap.String("key", key))
 
-	value, err := cache.Get(key)
+	fields := r.URL.Query().Get("fields")
+	path := r.URL.Query().Get("path")
+	if fields != "" && path != "" {
+		log.Logger.Warn("both fields and path in request")
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidParameter, "fields and path can not be combined", key)
+		return
+	}
+	var jsonPath *cache.JSONPath
+	if path != "" {
+		var err error
+		if jsonPath, err = cache.ParseJSONPath(path); err != nil {
+			log.Logger.Warn("invalid path in request", zap.Error(err))
+			apierror.Write(w, r, http.StatusBadRequest, cache.Code(err), err.Error(), key)
+			return
+		}
+	}
+
+	// compare the version first so a matching conditional read never decodes the value
+	if version, err := ns.Version(key); err == nil && notModified(r, version) {
+		w.Header().Set("ETag", projectionETag(version, fields != "" || jsonPath != nil))
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	_, span := tracing.StartCache(r.Context(), "get", ns.Name(), key)
+	value, version, staleness, err := ns.GetStale(key)
+	tracing.Hit(span, err == nil)
+	if err == cache.ErrorKeyNotFound {
+		tracing.End(span, nil)
+	} else {
+		tracing.End(span, err)
+	}
+	if err == cache.ErrorKeyNotFound {
+		// read through to the database when a loader covers the key
+		value, version, err = loader.Load(r.Context(), ns, key)
+		if err == loader.ErrorNoLoader {
+			err = cache.ErrorKeyNotFound
+		}
+	}
 	if err == cache.ErrorKeyNotFound {
 		log.Logger.Warn("key not found in cache", zap.String("key", key))
-		http.Error(w, "key not found in cache", http.StatusNotFound)
+		apierror.Write(w, r, http.StatusNotFound, cache.Code(err), "key not found in cache", key)
+		return
+	}
+	if err == cache.ErrorWrongType {
+		log.Logger.Warn("wrong type", zap.String("key", key))
+		apierror.Write(w, r, http.StatusConflict, cache.Code(err), err.Error(), key)
 		return
 	}
 
 	if err != nil {
 		log.Logger.Error("failed to get cache", zap.Error(err))
-		http.Error(w, "failed to get cache", http.StatusInternalServerError)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "failed to get cache", key)
 		return
 	}
 
-	responseBody, err := json.Marshal(value)
+	// a missing key is a 404 while paths missing from the value select nothing,
+	// an empty object for fields and an empty list for a JSONPath expression
+	var response any = value
+	if fields != "" {
+		response = cache.Project(value, strings.Split(fields, ","))
+	} else if jsonPath != nil {
+		response = jsonPath.Select(value)
+	}
+
+	responseBody, err := json.Marshal(response)
 	if err != nil {
 		log.Logger.Error("failed to marshall response", zap.Error(err))
-		http.Error(w, "failed to marshall response", http.StatusInternalServerError)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "failed to marshall response", key)
 		return
 	}
 
+	if staleness.Stale {
+		staleHeaders(w, r, ns, key, staleness)
+	}
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", projectionETag(version, fields != "" || jsonPath != nil))
 	w.Write(responseBody)
 
 }
+
+// staleHeaders marks a response served from an expired key during its grace
+// period and starts refreshing the key through its loader
+func staleHeaders(w http.ResponseWriter, r *http.Request, ns *cache.Cache, key string, staleness cache.Staleness) {
+	failed := loader.RevalidationFailed(ns, key)
+	loader.Revalidate(r.Context(), ns, key)
+
+	w.Header().Set("Age", strconv.Itoa(int(staleness.Age.Seconds())))
+	w.Header().Add("Warning", STALE_WARNING)
+	if failed {
+		w.Header().Add("Warning", REVALIDATION_FAILED_WARNING)
+	}
+	log.Logger.Info("served stale key", zap.String("key", key), zap.Duration("age", staleness.Age))
+}
+
+// projectionETag returns a weak entity tag for projections, which are not byte
+// for byte the stored value but change exactly when it does
+func projectionETag(version uint64, projected bool) string {
+	if projected {
+		return "W/" + formatETag(version)
+	}
+	return formatETag(version)
+}
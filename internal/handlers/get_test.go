@@ -3,9 +3,13 @@ package handlers
 import (
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/vishaldc/go-cache/internal/apierror"
 	"github.com/vishaldc/go-cache/internal/cache"
 )
 
@@ -89,3 +93,93 @@ func TestGetHandlerKeyNotFound(t *testing.T) {
 
 	assert.Equal(t, "key not found in cache\n", rr.Body.String())
 }
+
+func TestGetHandlerKeyNotFoundJSON(t *testing.T) {
+	// Create a request accepting a JSON error
+	req, err := http.NewRequest("GET", "/get?key=nonExistentKey", nil)
+	assert.NoError(t, err)
+	req.Header.Set("Accept", "application/json")
+
+	// Call the handler through the request id middleware
+	rr := httptest.NewRecorder()
+	apierror.Middleware(http.HandlerFunc(GetHandler)).ServeHTTP(rr, req)
+
+	// Check the error envelope carries the code, the key and the request id
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	requestID := rr.Header().Get(apierror.REQUEST_ID_HEADER)
+	assert.NotEmpty(t, requestID)
+	assert.JSONEq(t, `{"code":"key_not_found","message":"key not found in cache","key":"nonExistentKey","request_id":"`+requestID+`"}`, rr.Body.String())
+}
+
+func TestGetHandlerProjection(t *testing.T) {
+	post(t, "projectKey", `{"field1":"value1","nested":{"a":1,"b":2},"list":[1,2,3]}`, nil)
+
+	tests := []struct {
+		query, expected string
+		status          int
+	}{
+		{"fields=field1,nested.b", `{"field1":"value1","nested":{"b":2}}`, http.StatusOK},
+		{"fields=missing", `{}`, http.StatusOK},
+		{"path=$.list[-1]", `[3]`, http.StatusOK},
+		{"path=$.missing", `[]`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		// Create a request to pass to our handler
+		req, err := http.NewRequest("GET", "/cache?key=projectKey&"+url.PathEscape(tt.query), nil)
+		assert.NoError(t, err)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(GetHandler).ServeHTTP(rr, req)
+
+		assert.Equal(t, tt.status, rr.Code, tt.query)
+		assert.JSONEq(t, tt.expected, rr.Body.String(), tt.query)
+		assert.True(t, strings.HasPrefix(rr.Header().Get("ETag"), "W/"), tt.query)
+	}
+
+	// Check a missing key is still not found
+	for _, query := range []string{"fields=field1", "path=$.field1"} {
+		req, err := http.NewRequest("GET", "/cache?key=projectMissing&"+query, nil)
+		assert.NoError(t, err)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(GetHandler).ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusNotFound, rr.Code, query)
+	}
+
+	// Check an invalid or ambiguous projection is rejected
+	for _, query := range []string{"path=field1", "fields=a&path=$.a"} {
+		req, err := http.NewRequest("GET", "/cache?key=projectKey&"+query, nil)
+		assert.NoError(t, err)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(GetHandler).ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+	}
+}
+
+func TestGetHandlerStale(t *testing.T) {
+	ns, err := cache.CreateNamespace("stale")
+	assert.NoError(t, err)
+	_, err = ns.SetWithOptions("key", map[string]any{"a": float64(1)}, cache.SetOptions{TTL: time.Millisecond, Stale: time.Hour})
+	assert.NoError(t, err)
+	time.Sleep(5 * time.Millisecond)
+
+	// Test an expired key is served stale during its grace period
+	req := httptest.NewRequest("GET", "/cache?ns=stale&key=key", nil)
+	rr := httptest.NewRecorder()
+	GetHandler(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"a": 1}`, rr.Body.String())
+	assert.Equal(t, []string{STALE_WARNING}, rr.Header().Values("Warning"))
+	assert.Equal(t, "0", rr.Header().Get("Age"))
+
+	// Test a fresh key has no staleness headers
+	req = httptest.NewRequest("POST", "/cache?ns=stale&key=key&ttl=1m&stale=1h", strings.NewReader(`{"a": 2}`))
+	rr = httptest.NewRecorder()
+	PostHandler(rr, req)
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	req = httptest.NewRequest("GET", "/cache?ns=stale&key=key", nil)
+	rr = httptest.NewRecorder()
+	GetHandler(rr, req)
+	assert.JSONEq(t, `{"a": 2}`, rr.Body.String())
+	assert.Empty(t, rr.Header().Values("Warning"))
+	assert.Empty(t, rr.Header().Get("Age"))
+}
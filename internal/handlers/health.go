@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/vishaldc/go-cache/internal/apierror"
+	"github.com/vishaldc/go-cache/internal/log"
+	"github.com/vishaldc/go-cache/internal/registry"
+	"go.uber.org/zap"
+)
+
+// HealthzHandler reports the process is alive, it does not depend on the
+// registry so a broken database never gets the worker restarted
+func HealthzHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		log.Logger.Warn("invalid request method", zap.String("method", r.Method))
+		apierror.Write(w, r, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "invalid request method", "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte("ok\n"))
+}
+
+// ReadyzHandler reports whether the worker should receive traffic, with the
+// reason it should not as a 503
+func ReadyzHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		log.Logger.Warn("invalid request method", zap.String("method", r.Method))
+		apierror.Write(w, r, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "invalid request method", "")
+		return
+	}
+
+	if err := registry.GetRegistry().Ready(r.Context()); err != nil {
+		log.Logger.Warn("worker not ready", zap.Error(err))
+		apierror.Write(w, r, http.StatusServiceUnavailable, apierror.CodeNotReady, err.Error(), "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte("ok\n"))
+}
+
+// StatusHandler returns the current worker, its pool and the state of the
+// heartbeat and pool refresh loops
+func StatusHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		log.Logger.Warn("invalid request method", zap.String("method", r.Method))
+		apierror.Write(w, r, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "invalid request method", "")
+		return
+	}
+
+	responseBody, err := json.Marshal(registry.GetRegistry().Status())
+	if err != nil {
+		log.Logger.Error("failed to marshall response", zap.Error(err))
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "failed to marshall response", "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(responseBody)
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthzHandler(t *testing.T) {
+	rr := httptest.NewRecorder()
+	HealthzHandler(rr, httptest.NewRequest("GET", "/healthz", nil))
+
+	// The process is alive even though the registry is not set up
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "ok\n", rr.Body.String())
+}
+
+func TestReadyzHandler(t *testing.T) {
+	rr := httptest.NewRecorder()
+	ReadyzHandler(rr, httptest.NewRequest("GET", "/readyz", nil))
+
+	// The registry is not set up in tests so the worker is not ready
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Equal(t, "worker is not registered in the pool yet\n", rr.Body.String())
+}
+
+func TestStatusHandler(t *testing.T) {
+	rr := httptest.NewRecorder()
+	StatusHandler(rr, httptest.NewRequest("GET", "/status", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"self":null,"pool":[],"bootstrapped":false,"draining":false}`, rr.Body.String())
+}
@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+
+	"github.com/vishaldc/go-cache/internal/apierror"
+	"github.com/vishaldc/go-cache/internal/auth"
+	"github.com/vishaldc/go-cache/internal/cache"
+	"github.com/vishaldc/go-cache/internal/log"
+	"github.com/vishaldc/go-cache/internal/registry"
+	"go.uber.org/zap"
+)
+
+// QueryResponse is the response body of an index query
+type QueryResponse struct {
+	Entries []cache.Entry `json:"entries"`
+}
+
+// IndexRequest is the request body declaring an index
+type IndexRequest struct {
+	Field string `json:"field"`
+}
+
+// QueryHandler returns the entries whose indexed field equals the eq
+// parameter. The parameter is compared as JSON, so eq=42 matches the number
+// 42 and eq="42" or eq=abc match strings.
+func QueryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		log.Logger.Warn("invalid request method", zap.String("method", r.Method))
+		apierror.Write(w, r, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "invalid request method", "")
+		return
+	}
+
+	ns, ok := namespaceFor(w, r)
+	if !ok {
+		return
+	}
+
+	name := r.URL.Query().Get("index")
+	if name == "" {
+		log.Logger.Warn("missing index in request")
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeMissingParameter, "missing index in request", "")
+		return
+	}
+	if !r.URL.Query().Has("eq") {
+		log.Logger.Warn("missing eq in request")
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeMissingParameter, "missing eq in request", "")
+		return
+	}
+
+	eq := r.URL.Query().Get("eq")
+	var value any
+	if err := json.Unmarshal([]byte(eq), &value); err != nil {
+		// unquoted strings are accepted for convenience
+		value = eq
+	}
+
+	entries, err := ns.Query(name, value)
+	if err == cache.ErrorIndexNotFound {
+		log.Logger.Warn("index not found", zap.String("index", name))
+		apierror.Write(w, r, http.StatusNotFound, cache.Code(cache.ErrorIndexNotFound), "index not found", "")
+		return
+	}
+	if err == cache.ErrorInvalidIndex {
+		log.Logger.Warn("invalid eq in request", zap.String("eq", eq))
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidParameter, "eq must be a string, number, boolean or null", "")
+		return
+	}
+	if err != nil {
+		log.Logger.Error("failed to query cache", zap.Error(err))
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "failed to query cache", "")
+		return
+	}
+
+	// the index spans every key of the namespace, the caller only sees the
+	// ones it may read
+	entries = slices.DeleteFunc(entries, func(e cache.Entry) bool {
+		return !auth.Allowed(r.Context(), auth.Read, ns.Name(), e.Key)
+	})
+
+	log.Logger.Info("query request completed", zap.String("index", name), zap.Int("entries", len(entries)))
+	writeJSON(w, r, QueryResponse{Entries: entries})
+}
+
+// IndexesHandler lists the indexes of a namespace
+func IndexesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		log.Logger.Warn("invalid request method", zap.String("method", r.Method))
+		apierror.Write(w, r, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "invalid request method", "")
+		return
+	}
+
+	ns, ok := namespaceFor(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, r, ns.Indexes())
+}
+
+// IndexPutHandler declares an index of the namespace on every worker
+func IndexPutHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		apierror.Write(w, r, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "invalid request method", "")
+		return
+	}
+
+	ns, ok := namespaceFor(w, r)
+	if !ok {
+		return
+	}
+
+	var body IndexRequest
+	limitBody(w, r)
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if tooLarge(w, r, "", err) {
+		return
+	}
+	if err != nil {
+		log.Logger.Error("invalid request body", zap.Error(err))
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidBody, "invalid request body", "")
+		return
+	}
+
+	name := r.PathValue("index")
+	if err := ns.CreateIndex(name, body.Field); err != nil {
+		log.Logger.Warn("invalid index in request", zap.String("index", name), zap.String("field", body.Field))
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidParameter, "invalid index in request", "")
+		return
+	}
+
+	ctx := context.WithoutCancel(r.Context())
+	reg := registry.GetRegistry()
+	registry.Go(func() {
+		err := reg.CreateIndexInPool(ctx, ns.Name(), name, body.Field)
+		if err != nil {
+			log.Logger.Error("failed to write to pool", zap.Error(err))
+		}
+	})
+
+	log.Logger.Info("index created", zap.String("index", name), zap.String("field", body.Field))
+	for _, info := range ns.Indexes() {
+		if info.Name == name {
+			writeJSON(w, r, info)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// IndexDeleteHandler drops an index of the namespace on every worker
+func IndexDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		apierror.Write(w, r, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "invalid request method", "")
+		return
+	}
+
+	ns, ok := namespaceFor(w, r)
+	if !ok {
+		return
+	}
+
+	name := r.PathValue("index")
+	if !ns.DropIndex(name) {
+		log.Logger.Warn("index not found", zap.String("index", name))
+		apierror.Write(w, r, http.StatusNotFound, cache.Code(cache.ErrorIndexNotFound), "index not found", "")
+		return
+	}
+
+	ctx := context.WithoutCancel(r.Context())
+	reg := registry.GetRegistry()
+	registry.Go(func() {
+		err := reg.DropIndexInPool(ctx, ns.Name(), name)
+		if err != nil {
+			log.Logger.Error("failed to write to pool", zap.Error(err))
+		}
+	})
+
+	log.Logger.Info("index dropped", zap.String("index", name))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SyncIndexPutHandler applies an index declaration replicated from another worker
+func SyncIndexPutHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		apierror.Write(w, r, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "invalid request method", "")
+		return
+	}
+
+	ns, ok := createNamespaceFor(w, r)
+	if !ok {
+		return
+	}
+
+	name, field := r.URL.Query().Get("index"), r.URL.Query().Get("field")
+	if err := ns.CreateIndex(name, field); err != nil {
+		log.Logger.Warn("invalid index in request", zap.String("index", name), zap.String("field", field))
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidParameter, "invalid index in request", "")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+
+	log.Logger.Debug("sync index request completed", zap.String("index", name))
+}
+
+// SyncIndexDeleteHandler applies an index drop replicated from another worker
+func SyncIndexDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		apierror.Write(w, r, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "invalid request method", "")
+		return
+	}
+
+	ns, ok := createNamespaceFor(w, r)
+	if !ok {
+		return
+	}
+
+	name := r.URL.Query().Get("index")
+	ns.DropIndex(name)
+	w.WriteHeader(http.StatusNoContent)
+
+	log.Logger.Debug("sync index delete request completed", zap.String("index", name))
+}
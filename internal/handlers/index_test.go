@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIndexHandlers(t *testing.T) {
+	post(t, "indexSession:1", `{"user_id":42,"name":"a"}`, nil)
+	post(t, "indexSession:2", `{"user_id":"42"}`, nil)
+
+	// Create a request to pass to our handler
+	req, err := http.NewRequest("PUT", "/cache/indexes/indexUser", bytes.NewBufferString(`{"field":"user_id"}`))
+	assert.NoError(t, err)
+	req.SetPathValue("index", "indexUser")
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(IndexPutHandler).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"name":"indexUser","field":"user_id","keys":2}`, rr.Body.String())
+
+	// Test the query compares the eq parameter as JSON
+	for query, expected := range map[string]string{
+		"eq=42":      `{"entries":[{"key":"indexSession:1","value":{"user_id":42,"name":"a"}}]}`,
+		`eq="42"`:    `{"entries":[{"key":"indexSession:2","value":{"user_id":"42"}}]}`,
+		"eq=missing": `{"entries":[]}`,
+	} {
+		req, err := http.NewRequest("GET", "/cache/query?index=indexUser&"+query, nil)
+		assert.NoError(t, err)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(QueryHandler).ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code, query)
+		assert.JSONEq(t, expected, rr.Body.String(), query)
+	}
+
+	// Test DELETE drops the index
+	req, err = http.NewRequest("DELETE", "/cache/indexes/indexUser", nil)
+	assert.NoError(t, err)
+	req.SetPathValue("index", "indexUser")
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(IndexDeleteHandler).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	req, err = http.NewRequest("GET", "/cache/query?index=indexUser&eq=42", nil)
+	assert.NoError(t, err)
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(QueryHandler).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestQueryHandlerMissingParams(t *testing.T) {
+	for _, query := range []string{"eq=1", "index=indexUser", "index=indexUser&eq={}"} {
+		req, err := http.NewRequest("GET", "/cache/query?"+query, nil)
+		assert.NoError(t, err)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(QueryHandler).ServeHTTP(rr, req)
+		assert.NotEqual(t, http.StatusOK, rr.Code, query)
+	}
+}
+
+func TestSyncIndexHandlers(t *testing.T) {
+	// Create a request to pass to our handler
+	req, err := http.NewRequest("PUT", "/cache/sync/indexes?ns=syncIndexNs&index=byStatus&field=job.status", nil)
+	assert.NoError(t, err)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(SyncIndexPutHandler).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	// Check the index was declared in the namespace
+	req, err = http.NewRequest("GET", "/cache/indexes?ns=syncIndexNs", nil)
+	assert.NoError(t, err)
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(IndexesHandler).ServeHTTP(rr, req)
+	assert.JSONEq(t, `[{"name":"byStatus","field":"job.status","keys":0}]`, rr.Body.String())
+
+	req, err = http.NewRequest("DELETE", "/cache/sync/indexes?ns=syncIndexNs&index=byStatus", nil)
+	assert.NoError(t, err)
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(SyncIndexDeleteHandler).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+}
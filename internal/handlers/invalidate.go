@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/vishaldc/go-cache/internal/apierror"
+	"github.com/vishaldc/go-cache/internal/auth"
+	"github.com/vishaldc/go-cache/internal/cache"
+	"github.com/vishaldc/go-cache/internal/log"
+	"github.com/vishaldc/go-cache/internal/registry"
+	"go.uber.org/zap"
+)
+
+// InvalidateResponse is the response body of a bulk invalidation
+type InvalidateResponse struct {
+	Deleted int `json:"deleted"`
+}
+
+// SyncInvalidateRequest is the request body of a replicated bulk
+// invalidation, the keys the selector matched on the worker that received it
+type SyncInvalidateRequest struct {
+	Keys []string `json:"keys"`
+}
+
+// TagDeleteHandler deletes every key written with the tag in the path
+func TagDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		apierror.Write(w, r, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "invalid request method", "")
+		return
+	}
+
+	ns, ok := namespaceFor(w, r)
+	if !ok {
+		return
+	}
+
+	tag := r.PathValue("tag")
+	if tag == "" {
+		log.Logger.Warn("missing tag in request")
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeMissingParameter, "missing tag in request", "")
+		return
+	}
+	invalidate(w, r, ns, cache.Selector{Tag: tag})
+}
+
+// invalidate deletes the selected keys from the store then locally, replies
+// with the number of keys removed and replicates the same keys to the pool,
+// so no worker removes a key that was not authorized and removed here
+func invalidate(w http.ResponseWriter, r *http.Request, ns *cache.Cache, sel cache.Selector) {
+	keys, err := ns.Select(sel)
+	if err == cache.ErrorInvalidPattern || err == cache.ErrorEmptySelector {
+		log.Logger.Warn("invalid invalidation request", zap.Error(err))
+		apierror.Write(w, r, http.StatusBadRequest, cache.Code(err), err.Error(), "")
+		return
+	}
+	if err != nil {
+		log.Logger.Error("failed to invalidate cache", zap.Error(err))
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "failed to invalidate cache", "")
+		return
+	}
+	for _, key := range keys {
+		if !allowed(w, r, ns, auth.Delete, key) {
+			return
+		}
+	}
+	if err := persistKeys(ns, keys); err != nil {
+		apierror.Write(w, r, http.StatusBadGateway, apierror.CodeStoreFailed, "failed to delete from store", "")
+		return
+	}
+	deleted := ns.DeleteKeys(keys)
+
+	ctx := context.WithoutCancel(r.Context())
+	reg := registry.GetRegistry()
+	registry.Go(func() {
+		err := reg.InvalidateInPool(ctx, ns.Name(), keys)
+		if err != nil {
+			log.Logger.Error("failed to write to pool", zap.Error(err))
+		}
+	})
+
+	responseBody, err := json.Marshal(InvalidateResponse{Deleted: deleted})
+	if err != nil {
+		log.Logger.Error("failed to marshall response", zap.Error(err))
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "failed to marshall response", "")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(responseBody)
+
+	log.Logger.Info("invalidate request completed",
+		zap.String("prefix", sel.Prefix), zap.String("match", sel.Match), zap.String("tag", sel.Tag), zap.Int("deleted", deleted))
+}
+
+// SyncInvalidateHandler applies a bulk invalidation replicated from another worker
+func SyncInvalidateHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		apierror.Write(w, r, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "invalid request method", "")
+		return
+	}
+
+	ns, ok := createNamespaceFor(w, r)
+	if !ok {
+		return
+	}
+
+	var body SyncInvalidateRequest
+	limitSyncBody(w, r)
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if tooLarge(w, r, "", err) {
+		return
+	}
+	if err != nil {
+		log.Logger.Error("invalid request body", zap.Error(err))
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidBody, "invalid request body", "")
+		return
+	}
+	deleted := ns.DeleteKeys(body.Keys)
+	w.WriteHeader(http.StatusNoContent)
+
+	log.Logger.Debug("sync invalidate request completed", zap.Int("deleted", deleted))
+}
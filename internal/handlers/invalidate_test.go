@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vishaldc/go-cache/internal/cache"
+)
+
+func TestDeleteHandlerByPrefix(t *testing.T) {
+	// Set up test cache items
+	cache.Set("bulk:1", map[string]any{"field1": "value1"})
+	cache.Set("bulk:2", map[string]any{"field1": "value1"})
+
+	// Create a request to pass to our handler
+	req, err := http.NewRequest("DELETE", "/cache?prefix=bulk:", nil)
+	assert.NoError(t, err)
+
+	// Create a ResponseRecorder to record the response
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(DeleteHandler)
+
+	// Call the handler
+	handler.ServeHTTP(rr, req)
+
+	// Check the status code and the number of deleted keys
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"deleted":2}`, rr.Body.String())
+	assert.Empty(t, cache.Keys("bulk:"))
+}
+
+func TestDeleteHandlerInvalidMatch(t *testing.T) {
+	// Create a request to pass to our handler
+	req, err := http.NewRequest("DELETE", "/cache?match=bulk:[", nil)
+	assert.NoError(t, err)
+
+	// Create a ResponseRecorder to record the response
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(DeleteHandler)
+
+	// Call the handler
+	handler.ServeHTTP(rr, req)
+
+	// Check the status code
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, "invalid match pattern\n", rr.Body.String())
+}
+
+func TestTagDeleteHandler(t *testing.T) {
+	// Write keys tagged through the header
+	for _, key := range []string{"tag:1", "tag:2"} {
+		req, err := http.NewRequest("POST", "/cache?key="+key, bytes.NewBuffer([]byte(`{"field1":"value1"}`)))
+		assert.NoError(t, err)
+		req.Header.Set(TAGS_HEADER, "catalog, products")
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(PostHandler).ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusNoContent, rr.Code)
+	}
+	cache.Set("tag:3", map[string]any{"field1": "value1"})
+
+	// Delete the tag through a mux so the path value is set
+	mux := http.NewServeMux()
+	mux.HandleFunc("DELETE /cache/tags/{tag}", TagDeleteHandler)
+	req, err := http.NewRequest("DELETE", "/cache/tags/products", nil)
+	assert.NoError(t, err)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	// Check only the tagged keys were deleted
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"deleted":2}`, rr.Body.String())
+	assert.Equal(t, []string{"tag:3"}, cache.Keys("tag:"))
+}
+
+func TestSyncInvalidateHandler(t *testing.T) {
+	// Set up test cache items
+	cache.Set("syncBulk:1", map[string]any{"field1": "value1"})
+	cache.Set("syncBulk:2", map[string]any{"field1": "value1"})
+	cache.Set("syncBulk:3", map[string]any{"field1": "value1"})
+
+	// Create a request to pass to our handler
+	req, err := http.NewRequest("DELETE", "/cache/sync/invalidate", bytes.NewBufferString(`{"keys": ["syncBulk:1", "syncBulk:2"]}`))
+	assert.NoError(t, err)
+
+	// Create a ResponseRecorder to record the response
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(SyncInvalidateHandler)
+
+	// Call the handler
+	handler.ServeHTTP(rr, req)
+
+	// Check only the keys sent were deleted, not every key of the selector
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	assert.Equal(t, []string{"syncBulk:3"}, cache.Keys("syncBulk:"))
+}
+
+func TestSyncInvalidateHandlerInvalidBody(t *testing.T) {
+	// Create a request to pass to our handler
+	req := httptest.NewRequest("DELETE", "/cache/sync/invalidate?match=syncBulk:*", nil)
+
+	// Create a ResponseRecorder to record the response
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(SyncInvalidateHandler)
+
+	// Call the handler
+	handler.ServeHTTP(rr, req)
+
+	// Check the status code
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/vishaldc/go-cache/internal/apierror"
+	"github.com/vishaldc/go-cache/internal/cache"
+	"github.com/vishaldc/go-cache/internal/log"
+	"go.uber.org/zap"
+)
+
+// KeysResponse is the response body of the keys handler
+type KeysResponse struct {
+	Keys []string `json:"keys"`
+	// Entries holds the size and ttl of each key when details are requested
+	Entries []cache.KeyInfo `json:"entries,omitempty"`
+	// Cursor is passed back to fetch the next page, empty on the last page
+	Cursor string `json:"cursor,omitempty"`
+}
+
+// KeysHandler lists a page of keys in the cache filtered by prefix and glob
+func KeysHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		log.Logger.Warn("invalid request method", zap.String("method", r.Method))
+		apierror.Write(w, r, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "invalid request method", "")
+		return
+	}
+
+	ns, ok := namespaceFor(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	opts := cache.ScanOptions{
+		Prefix: query.Get("prefix"),
+		Match:  query.Get("match"),
+		Cursor: query.Get("cursor"),
+	}
+	if l := query.Get("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit <= 0 {
+			log.Logger.Warn("invalid limit in request", zap.String("limit", l))
+			apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidParameter, "invalid limit in request", "")
+			return
+		}
+		opts.Limit = limit
+	}
+
+	result, err := ns.Scan(opts)
+	if err == cache.ErrorInvalidCursor || err == cache.ErrorInvalidPattern {
+		log.Logger.Warn("invalid scan request", zap.Error(err))
+		apierror.Write(w, r, http.StatusBadRequest, cache.Code(err), err.Error(), "")
+		return
+	}
+	if err != nil {
+		log.Logger.Error("failed to scan cache", zap.Error(err))
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "failed to scan cache", "")
+		return
+	}
+
+	response := KeysResponse{Keys: make([]string, 0, len(result.Keys)), Cursor: result.Cursor}
+	for _, k := range result.Keys {
+		response.Keys = append(response.Keys, k.Key)
+	}
+	if query.Get("details") == "true" {
+		response.Entries = result.Keys
+	}
+
+	responseBody, err := json.Marshal(response)
+	if err != nil {
+		log.Logger.Error("failed to marshall response", zap.Error(err))
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "failed to marshall response", "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(responseBody)
+	log.Logger.Debug("keys request completed", zap.String("prefix", opts.Prefix), zap.String("match", opts.Match), zap.Int("count", len(response.Keys)))
+}
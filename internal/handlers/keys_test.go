@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vishaldc/go-cache/internal/cache"
+)
+
+func TestKeysHandler(t *testing.T) {
+	// Set up test cache items
+	cache.Set("keysHandler:1", map[string]any{"field1": "value1"})
+	cache.Set("keysHandler:2", map[string]any{"field1": "value2"})
+
+	// Create a request to pass to our handler
+	req, err := http.NewRequest("GET", "/cache/keys?prefix=keysHandler:", nil)
+	assert.NoError(t, err)
+
+	// Create a ResponseRecorder to record the response
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(KeysHandler)
+
+	// Call the handler
+	handler.ServeHTTP(rr, req)
+
+	// Check the status code
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	// Check the response body
+	assert.JSONEq(t, `{"keys":["keysHandler:1","keysHandler:2"]}`, rr.Body.String())
+}
+
+func TestKeysHandlerInvalidMethod(t *testing.T) {
+	// Create a request to pass to our handler
+	req, err := http.NewRequest("POST", "/cache/keys", nil)
+	assert.NoError(t, err)
+
+	// Create a ResponseRecorder to record the response
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(KeysHandler)
+
+	// Call the handler
+	handler.ServeHTTP(rr, req)
+
+	// Check the status code
+	assert.Equal(t, http.StatusMethodNotAllowed, rr.Code)
+}
+
+func TestKeysHandlerPagination(t *testing.T) {
+	// Set up test cache items
+	cache.Set("keysPage:1", map[string]any{"field1": "value1"})
+	cache.Set("keysPage:2", map[string]any{"field1": "value2"})
+
+	// Request the first page
+	req, err := http.NewRequest("GET", "/cache/keys?prefix=keysPage:&limit=1&details=true", nil)
+	assert.NoError(t, err)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(KeysHandler).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var page KeysResponse
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+	assert.Equal(t, []string{"keysPage:1"}, page.Keys)
+	assert.Equal(t, "keysPage:1", page.Entries[0].Key)
+	assert.Equal(t, int64(-1), page.Entries[0].TTL)
+	assert.NotEmpty(t, page.Cursor)
+
+	// Request the second page with the returned cursor
+	req, err = http.NewRequest("GET", "/cache/keys?prefix=keysPage:&limit=1&cursor="+page.Cursor, nil)
+	assert.NoError(t, err)
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(KeysHandler).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"keys":["keysPage:2"]}`, rr.Body.String())
+}
+
+func TestKeysHandlerInvalidCursor(t *testing.T) {
+	// Create a request to pass to our handler
+	req, err := http.NewRequest("GET", "/cache/keys?cursor=%21%21", nil)
+	assert.NoError(t, err)
+
+	// Create a ResponseRecorder to record the response
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(KeysHandler)
+
+	// Call the handler
+	handler.ServeHTTP(rr, req)
+
+	// Check the status code
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, "invalid cursor\n", rr.Body.String())
+}
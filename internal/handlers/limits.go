@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/vishaldc/go-cache/internal/apierror"
+	"github.com/vishaldc/go-cache/internal/cache"
+	"github.com/vishaldc/go-cache/internal/log"
+	"github.com/vishaldc/go-cache/internal/metrics"
+	"go.uber.org/zap"
+)
+
+// limitBody stops reading the body of a client write after the maximum value
+// size, the decoding of a larger body fails with a *http.MaxBytesError. A
+// transaction carries at most that many bytes in total.
+func limitBody(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, int64(cache.CurrentLimits().MaxValueSize))
+}
+
+// limitSyncBody stops reading the body of a sync request after the largest
+// write another worker can replicate
+func limitSyncBody(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, cache.CurrentLimits().MaxSyncSize())
+}
+
+// tooLarge answers 413 and counts the rejected write when err is a body or a
+// write over a limit, reporting whether it did
+func tooLarge(w http.ResponseWriter, r *http.Request, key string, err error) bool {
+	var reason string
+	var maxBytes *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytes):
+		reason = apierror.CodeBodyTooLarge
+		err = errors.New("request body too large")
+	case errors.Is(err, cache.ErrorKeyTooLong), errors.Is(err, cache.ErrorValueTooLarge), errors.Is(err, cache.ErrorBatchTooLarge):
+		reason = cache.Code(err)
+	default:
+		return false
+	}
+
+	metrics.RejectedWrites.WithLabelValues(reason).Inc()
+	log.Logger.Warn("write too large", zap.String("key", key), zap.String("reason", reason), zap.Error(err))
+	apierror.Write(w, r, http.StatusRequestEntityTooLarge, reason, err.Error(), key)
+	return true
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/vishaldc/go-cache/internal/cache"
+	"github.com/vishaldc/go-cache/internal/metrics"
+)
+
+func TestWriteLimits(t *testing.T) {
+	cache.SetLimits(cache.Limits{MaxKeyLength: 16, MaxValueSize: 512, MaxBatchSize: 1})
+	t.Cleanup(func() { cache.SetLimits(cache.Limits{}) })
+	rejected := func(reason string) float64 {
+		return testutil.ToFloat64(metrics.RejectedWrites.WithLabelValues(reason))
+	}
+	before := rejected("body_too_large")
+
+	// Test a body over the maximum value size is refused before it is read
+	body := `{"a":"` + strings.Repeat("x", 1024) + `"}`
+	rr := httptest.NewRecorder()
+	PostHandler(rr, httptest.NewRequest("POST", "/cache?key=limits:body", strings.NewReader(body)))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+	assert.Equal(t, before+1, rejected("body_too_large"))
+	_, err := cache.Get("limits:body")
+	assert.Equal(t, cache.ErrorKeyNotFound, err)
+
+	// Test a key over the limit is refused
+	before = rejected("key_too_long")
+	rr = httptest.NewRecorder()
+	PostHandler(rr, httptest.NewRequest("POST", "/cache?key=limits:"+strings.Repeat("k", 16), strings.NewReader(`{"a":1}`)))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+	assert.Equal(t, before+1, rejected("key_too_long"))
+
+	// Test a transaction over the batch size is refused
+	before = rejected("batch_too_large")
+	txn := `{"ops": [{"op": "delete", "key": "a"}, {"op": "delete", "key": "b"}]}`
+	rr = httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/cache/txn", bytes.NewReader([]byte(txn)))
+	req.Header.Set("Accept", "application/json")
+	TxnHandler(rr, req)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+	assert.JSONEq(t, `{"code":"batch_too_large","message":"batch too large: 2 operations, the limit is 1"}`, rr.Body.String())
+	assert.Equal(t, before+1, rejected("batch_too_large"))
+
+	// Test a push growing a list over the maximum value size is refused
+	rr = httptest.NewRecorder()
+	CollectionHandler(rr, httptest.NewRequest("POST", "/cache/rpush?key=limits:list", strings.NewReader(`{"values":["`+strings.Repeat("x", 200)+`"]}`)))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	rr = httptest.NewRecorder()
+	CollectionHandler(rr, httptest.NewRequest("POST", "/cache/rpush?key=limits:list", strings.NewReader(`{"values":["`+strings.Repeat("x", 200)+`"]}`)))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+
+	// Test the other write paths, sync requests included, refuse a body over the limit
+	for name, handler := range map[string]http.HandlerFunc{
+		"index":          IndexPutHandler,
+		"namespace":      NamespacePutHandler,
+		"sync post":      SyncPostHandler,
+		"sync txn":       SyncTxnHandler,
+		"sync rpush":     SyncCollectionHandler,
+		"sync namespace": SyncNamespacePutHandler,
+		"sync ratelimit": SyncRateLimitHandler,
+	} {
+		method := "POST"
+		if name == "index" || name == "namespace" || name == "sync namespace" {
+			method = "PUT"
+		}
+		rr = httptest.NewRecorder()
+		req := httptest.NewRequest(method, "/?key=limits:sync", strings.NewReader(`{"a":"`+strings.Repeat("x", 2048)+`"}`))
+		req.SetPathValue("index", "limitsIndex")
+		handler(rr, req)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code, name)
+	}
+}
@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/vishaldc/go-cache/internal/apierror"
+	"github.com/vishaldc/go-cache/internal/auth"
+	"github.com/vishaldc/go-cache/internal/cache"
+	"github.com/vishaldc/go-cache/internal/log"
+	"github.com/vishaldc/go-cache/internal/registry"
+	"github.com/vishaldc/go-cache/internal/store"
+	"go.uber.org/zap"
+)
+
+// FlushResponse is the response body of a namespace flush
+type FlushResponse struct {
+	Deleted int `json:"deleted"`
+}
+
+// namespaceFor returns the namespace addressed by the request, named by the
+// /ns/{name} path on client routes and by the ns parameter on sync routes.
+// The default namespace is used when neither is set, unknown namespaces are
+// not found.
+func namespaceFor(w http.ResponseWriter, r *http.Request) (*cache.Cache, bool) {
+	return lookupNamespace(w, r, cache.Namespace)
+}
+
+// createNamespaceFor returns the namespace addressed by the request like
+// namespaceFor, creating it if it does not exist. It is used by the admin
+// route configuring a namespace and by the sync routes, which replicate
+// namespaces that exist on another worker.
+func createNamespaceFor(w http.ResponseWriter, r *http.Request) (*cache.Cache, bool) {
+	return lookupNamespace(w, r, cache.CreateNamespace)
+}
+
+func lookupNamespace(w http.ResponseWriter, r *http.Request, lookup func(string) (*cache.Cache, error)) (*cache.Cache, bool) {
+	name := r.PathValue("name")
+	if name == "" {
+		name = r.URL.Query().Get("ns")
+	}
+	ns, err := lookup(name)
+	if err == cache.ErrorNamespaceNotFound {
+		log.Logger.Warn("namespace not found", zap.String("namespace", name))
+		apierror.Write(w, r, http.StatusNotFound, cache.Code(err), "namespace not found", "")
+		return nil, false
+	}
+	if err != nil {
+		log.Logger.Warn("invalid namespace in request", zap.String("namespace", name))
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidParameter, "invalid namespace in request", "")
+		return nil, false
+	}
+	return ns, true
+}
+
+// NamespacesHandler lists every namespace with its limits and usage
+func NamespacesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		log.Logger.Warn("invalid request method", zap.String("method", r.Method))
+		apierror.Write(w, r, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "invalid request method", "")
+		return
+	}
+	writeJSON(w, r, cache.Namespaces())
+}
+
+// NamespaceGetHandler returns the limits and usage of a namespace
+func NamespaceGetHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		log.Logger.Warn("invalid request method", zap.String("method", r.Method))
+		apierror.Write(w, r, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "invalid request method", "")
+		return
+	}
+
+	ns, ok := namespaceFor(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, r, ns.Stats())
+}
+
+// NamespacePutHandler replaces the limits of a namespace on every worker
+func NamespacePutHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		apierror.Write(w, r, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "invalid request method", "")
+		return
+	}
+
+	ns, ok := createNamespaceFor(w, r)
+	if !ok {
+		return
+	}
+	limitBody(w, r)
+	config, ok := decodeNamespaceConfig(w, r)
+	if !ok {
+		return
+	}
+	ns.Configure(config)
+
+	ctx := context.WithoutCancel(r.Context())
+	reg := registry.GetRegistry()
+	registry.Go(func() {
+		err := reg.ConfigureInPool(ctx, ns.Name(), config)
+		if err != nil {
+			log.Logger.Error("failed to write to pool", zap.Error(err))
+		}
+	})
+
+	log.Logger.Info("namespace configured", zap.String("namespace", ns.Name()))
+	writeJSON(w, r, ns.Stats())
+}
+
+// FlushHandler removes every key of a namespace from its store and on every
+// worker without touching the other namespaces
+func FlushHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apierror.Write(w, r, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "invalid request method", "")
+		return
+	}
+
+	ns, ok := namespaceFor(w, r)
+	if !ok {
+		return
+	}
+	// a permission limited to a prefix can not flush the whole namespace
+	if !allowed(w, r, ns, auth.Admin, "") {
+		return
+	}
+	if err := store.Clear(ns.Name()); err != nil {
+		apierror.Write(w, r, http.StatusBadGateway, apierror.CodeStoreFailed, "failed to clear store", "")
+		return
+	}
+	deleted := ns.Flush()
+
+	ctx := context.WithoutCancel(r.Context())
+	reg := registry.GetRegistry()
+	registry.Go(func() {
+		err := reg.FlushInPool(ctx, ns.Name())
+		if err != nil {
+			log.Logger.Error("failed to write to pool", zap.Error(err))
+		}
+	})
+
+	log.Logger.Info("flush request completed", zap.String("namespace", ns.Name()), zap.Int("deleted", deleted))
+	writeJSON(w, r, FlushResponse{Deleted: deleted})
+}
+
+// SyncNamespacePutHandler applies namespace limits replicated from another worker
+func SyncNamespacePutHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		apierror.Write(w, r, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "invalid request method", "")
+		return
+	}
+
+	ns, ok := createNamespaceFor(w, r)
+	if !ok {
+		return
+	}
+	limitSyncBody(w, r)
+	config, ok := decodeNamespaceConfig(w, r)
+	if !ok {
+		return
+	}
+	ns.Configure(config)
+	w.WriteHeader(http.StatusNoContent)
+
+	log.Logger.Debug("sync namespace request completed", zap.String("namespace", ns.Name()))
+}
+
+// SyncNamespacesHandler returns the namespaces and indexes of the worker to a
+// peer joining the cluster
+func SyncNamespacesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apierror.Write(w, r, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "invalid request method", "")
+		return
+	}
+	writeJSON(w, r, cache.Schemas())
+}
+
+// SyncFlushHandler applies a namespace flush replicated from another worker
+func SyncFlushHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		apierror.Write(w, r, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "invalid request method", "")
+		return
+	}
+
+	ns, ok := createNamespaceFor(w, r)
+	if !ok {
+		return
+	}
+	deleted := ns.Flush()
+	w.WriteHeader(http.StatusNoContent)
+
+	log.Logger.Debug("sync flush request completed", zap.String("namespace", ns.Name()), zap.Int("deleted", deleted))
+}
+
+func decodeNamespaceConfig(w http.ResponseWriter, r *http.Request) (cache.NamespaceConfig, bool) {
+	var config cache.NamespaceConfig
+	err := json.NewDecoder(r.Body).Decode(&config)
+	if tooLarge(w, r, "", err) {
+		return config, false
+	}
+	if err != nil {
+		log.Logger.Error("invalid request body", zap.Error(err))
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidBody, "invalid request body", "")
+		return config, false
+	}
+	return config, true
+}
+
+// writeJSON writes v as the JSON response body
+func writeJSON(w http.ResponseWriter, r *http.Request, v any) {
+	responseBody, err := json.Marshal(v)
+	if err != nil {
+		log.Logger.Error("failed to marshall response", zap.Error(err))
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "failed to marshall response", "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(responseBody)
+}
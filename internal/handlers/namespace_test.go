@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vishaldc/go-cache/internal/cache"
+)
+
+func newNamespaceMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /ns/{name}/cache", GetHandler)
+	mux.HandleFunc("POST /ns/{name}/cache", PostHandler)
+	mux.HandleFunc("GET /ns/{name}", NamespaceGetHandler)
+	mux.HandleFunc("PUT /ns/{name}", NamespacePutHandler)
+	mux.HandleFunc("POST /ns/{name}/flush", FlushHandler)
+	return mux
+}
+
+func TestNamespacedPostAndGet(t *testing.T) {
+	mux := newNamespaceMux()
+
+	// Check a write does not create an unknown namespace
+	req, err := http.NewRequest("POST", "/ns/orders/cache?key=nsKey", bytes.NewBuffer([]byte(`{"field1":"value1"}`)))
+	assert.NoError(t, err)
+	req.Header.Set("Accept", "application/json")
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"code":"namespace_not_found"`)
+	_, err = cache.Namespace("orders")
+	assert.Equal(t, cache.ErrorNamespaceNotFound, err)
+
+	// Create the namespace
+	req, err = http.NewRequest("PUT", "/ns/orders", bytes.NewBuffer([]byte(`{}`)))
+	assert.NoError(t, err)
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	// Write a key to the namespace
+	req, err = http.NewRequest("POST", "/ns/orders/cache?key=nsKey", bytes.NewBuffer([]byte(`{"field1":"value1"}`)))
+	assert.NoError(t, err)
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	// Read it back from the namespace
+	req, err = http.NewRequest("GET", "/ns/orders/cache?key=nsKey", nil)
+	assert.NoError(t, err)
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"field1":"value1"}`, rr.Body.String())
+
+	// Check the default namespace does not see it
+	_, err = cache.Get("nsKey")
+	assert.Equal(t, cache.ErrorKeyNotFound, err)
+}
+
+func TestNamespaceInvalidName(t *testing.T) {
+	// Create a request to pass to our handler
+	req, err := http.NewRequest("GET", "/cache?key=testKey&ns=not%2Fvalid", nil)
+	assert.NoError(t, err)
+
+	// Create a ResponseRecorder to record the response
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(GetHandler)
+
+	// Call the handler
+	handler.ServeHTTP(rr, req)
+
+	// Check the status code
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, "invalid namespace in request\n", rr.Body.String())
+}
+
+func TestNamespacePutAndFlushHandler(t *testing.T) {
+	mux := newNamespaceMux()
+	ns, _ := cache.CreateNamespace("flushed")
+	ns.Set("a", map[string]any{"field1": "value1"})
+	ns.Set("b", map[string]any{"field1": "value1"})
+
+	// Configure the namespace limits
+	req, err := http.NewRequest("PUT", "/ns/flushed", bytes.NewBuffer([]byte(`{"max_keys":100,"default_ttl":"5m"}`)))
+	assert.NoError(t, err)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"config":{"max_keys":100,"default_ttl":"5m0s"},"keys":2`)
+	assert.Equal(t, cache.NamespaceConfig{MaxKeys: 100, DefaultTTL: 5 * time.Minute}, ns.Config())
+
+	// Flush the namespace
+	req, err = http.NewRequest("POST", "/ns/flushed/flush", nil)
+	assert.NoError(t, err)
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"deleted":2}`, rr.Body.String())
+	assert.Empty(t, ns.Keys(""))
+}
+
+func TestSyncFlushHandler(t *testing.T) {
+	ns, _ := cache.CreateNamespace("syncFlushed")
+	ns.Set("a", map[string]any{"field1": "value1"})
+	cache.Set("syncFlushKeep", map[string]any{"field1": "value1"})
+
+	// Create a request to pass to our handler
+	req, err := http.NewRequest("DELETE", "/cache/sync/flush?ns=syncFlushed", nil)
+	assert.NoError(t, err)
+
+	// Create a ResponseRecorder to record the response
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(SyncFlushHandler)
+
+	// Call the handler
+	handler.ServeHTTP(rr, req)
+
+	// Check only the namespace was flushed
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	assert.Empty(t, ns.Keys(""))
+	_, err = cache.Get("syncFlushKeep")
+	assert.NoError(t, err)
+}
+
+func TestSyncNamespacesHandler(t *testing.T) {
+	// Replicate a write to a namespace the worker does not know yet
+	req, err := http.NewRequest("POST", "/cache/sync?ns=syncCreated&key=a", bytes.NewBuffer([]byte(`{"field1":"value1"}`)))
+	assert.NoError(t, err)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(SyncPostHandler).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	ns, err := cache.Namespace("syncCreated")
+	assert.NoError(t, err)
+	assert.NoError(t, ns.CreateIndex("byField", "field1"))
+
+	// Check the namespaces are listed with their indexes for a joining worker
+	req, err = http.NewRequest("GET", "/cache/sync/ns", nil)
+	assert.NoError(t, err)
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(SyncNamespacesHandler).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `{"name":"syncCreated","config":{},"indexes":[{"name":"byField","field":"field1","keys":1}]}`)
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+
+	"github.com/vishaldc/go-cache/internal/apierror"
+	"github.com/vishaldc/go-cache/internal/cache"
+	"github.com/vishaldc/go-cache/internal/log"
+	"github.com/vishaldc/go-cache/internal/registry"
+	"github.com/vishaldc/go-cache/internal/tracing"
+	"go.uber.org/zap"
+)
+
+const (
+	// MERGE_PATCH_CONTENT_TYPE selects an RFC 7396 merge patch
+	MERGE_PATCH_CONTENT_TYPE = "application/merge-patch+json"
+
+	// JSON_PATCH_CONTENT_TYPE selects an RFC 6902 JSON Patch
+	JSON_PATCH_CONTENT_TYPE = "application/json-patch+json"
+)
+
+// PatchHandler applies a merge patch or a JSON Patch, chosen by the content
+// type, to the value stored under key. The patch is applied atomically and
+// the resulting value is replicated to the pool.
+func PatchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		apierror.Write(w, r, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "invalid request method", "")
+		return
+	}
+
+	ns, ok := namespaceFor(w, r)
+	if !ok {
+		return
+	}
+
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		log.Logger.Warn("missing key in request")
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeMissingKey, "missing key in request", "")
+		return
+	}
+
+	precondition, err := writePrecondition(r)
+	if err != nil {
+		log.Logger.Warn("invalid precondition in request", zap.Error(err))
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidParameter, "invalid precondition in request", key)
+		return
+	}
+
+	limitBody(w, r)
+	var apply func(value map[string]any) (map[string]any, error)
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch contentType {
+	case MERGE_PATCH_CONTENT_TYPE:
+		var patch any
+		if err := json.NewDecoder(r.Body).Decode(&patch); tooLarge(w, r, key, err) {
+			return
+		} else if err != nil {
+			log.Logger.Error("invalid request body", zap.Error(err))
+			apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidBody, "invalid request body", key)
+			return
+		}
+		// a patch that is not an object replaces the whole value, which
+		// must remain an object
+		if _, err := cache.MergePatch(nil, patch); err != nil {
+			log.Logger.Warn("invalid patch", zap.String("key", key), zap.Error(err))
+			apierror.Write(w, r, http.StatusBadRequest, cache.Code(err), err.Error(), key)
+			return
+		}
+		apply = func(value map[string]any) (map[string]any, error) {
+			return cache.MergePatch(value, patch)
+		}
+	case JSON_PATCH_CONTENT_TYPE:
+		var ops []cache.PatchOperation
+		if err := json.NewDecoder(r.Body).Decode(&ops); tooLarge(w, r, key, err) {
+			return
+		} else if err != nil {
+			log.Logger.Error("invalid request body", zap.Error(err))
+			apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidBody, "invalid request body", key)
+			return
+		}
+		apply = func(value map[string]any) (map[string]any, error) {
+			return cache.JSONPatch(value, ops)
+		}
+	default:
+		log.Logger.Warn("unsupported patch content type", zap.String("content_type", contentType))
+		apierror.Write(w, r, http.StatusUnsupportedMediaType, apierror.CodeUnsupportedMediaType, "unsupported patch content type", key)
+		return
+	}
+
+	before := ns.Snapshot(key)
+	_, span := tracing.StartCache(r.Context(), "update", ns.Name(), key)
+	value, opts, err := ns.Update(key, precondition, apply)
+	tracing.End(span, err)
+	switch {
+	case err == cache.ErrorKeyNotFound:
+		log.Logger.Info("key not found", zap.String("key", key))
+		apierror.Write(w, r, http.StatusNotFound, cache.Code(err), "key not found", key)
+		return
+	case err == cache.ErrorPreconditionFailed:
+		log.Logger.Info("precondition failed", zap.String("key", key))
+		apierror.Write(w, r, http.StatusPreconditionFailed, cache.Code(err), "precondition failed", key)
+		return
+	case err == cache.ErrorWrongType:
+		log.Logger.Warn("wrong type", zap.String("key", key))
+		apierror.Write(w, r, http.StatusConflict, cache.Code(err), err.Error(), key)
+		return
+	case errors.Is(err, cache.ErrorPatchTestFailed):
+		log.Logger.Info("patch test failed", zap.String("key", key), zap.Error(err))
+		apierror.Write(w, r, http.StatusConflict, cache.Code(err), err.Error(), key)
+		return
+	case errors.Is(err, cache.ErrorInvalidPatch):
+		log.Logger.Warn("invalid patch", zap.String("key", key), zap.Error(err))
+		apierror.Write(w, r, http.StatusUnprocessableEntity, cache.Code(err), err.Error(), key)
+		return
+	case tooLarge(w, r, key, err):
+		return
+	case err != nil:
+		log.Logger.Error("failed to patch cache", zap.Error(err))
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "failed to patch cache", key)
+		return
+	}
+
+	if err := persist(ns, change{before: before, version: opts.Version}); err != nil {
+		apierror.Write(w, r, http.StatusBadGateway, apierror.CodeStoreFailed, "failed to write to store", key)
+		return
+	}
+
+	// the resulting value is replicated so replicas converge even when they
+	// missed an earlier write of the key
+	ctx := context.WithoutCancel(r.Context())
+	reg := registry.GetRegistry()
+	registry.Go(func() {
+		err := reg.WriteToPool(ctx, ns.Name(), key, value, opts)
+		if err != nil {
+			log.Logger.Error("failed to write to pool", zap.Error(err))
+		}
+	})
+
+	log.Logger.Info("patch request completed", zap.String("key", key))
+	w.Header().Set("ETag", formatETag(opts.Version))
+	w.WriteHeader(http.StatusNoContent)
+}
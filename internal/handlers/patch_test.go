@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func patch(t *testing.T, key, contentType, body string, header http.Header) *httptest.ResponseRecorder {
+	req, err := http.NewRequest("PATCH", "/cache?key="+key, bytes.NewBuffer([]byte(body)))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", contentType)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(PatchHandler).ServeHTTP(rr, req)
+	return rr
+}
+
+func TestPatchHandlerMergePatch(t *testing.T) {
+	post(t, "mergeKey", `{"field1":"value1","nested":{"a":1,"b":2}}`, nil)
+
+	rr := patch(t, "mergeKey", MERGE_PATCH_CONTENT_TYPE, `{"field1":null,"nested":{"b":3}}`, nil)
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	assert.NotEmpty(t, rr.Header().Get("ETag"))
+
+	// Check the patch was merged into the stored value
+	req, err := http.NewRequest("GET", "/cache?key=mergeKey", nil)
+	assert.NoError(t, err)
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(GetHandler).ServeHTTP(rr, req)
+	assert.JSONEq(t, `{"nested":{"a":1,"b":3}}`, rr.Body.String())
+}
+
+func TestPatchHandlerJSONPatch(t *testing.T) {
+	rr := post(t, "jsonPatchKey", `{"items":["a"]}`, nil)
+	etag := rr.Header().Get("ETag")
+
+	rr = patch(t, "jsonPatchKey", JSON_PATCH_CONTENT_TYPE+"; charset=utf-8", `[{"op":"add","path":"/items/-","value":"b"}]`, http.Header{"If-Match": {etag}})
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	// Check the patch was applied
+	req, err := http.NewRequest("GET", "/cache?key=jsonPatchKey", nil)
+	assert.NoError(t, err)
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(GetHandler).ServeHTTP(rr, req)
+	assert.JSONEq(t, `{"items":["a","b"]}`, rr.Body.String())
+
+	// Check a patch with a stale tag is rejected
+	rr = patch(t, "jsonPatchKey", JSON_PATCH_CONTENT_TYPE, `[]`, http.Header{"If-Match": {etag}})
+	assert.Equal(t, http.StatusPreconditionFailed, rr.Code)
+}
+
+func TestPatchHandlerErrors(t *testing.T) {
+	post(t, "patchErrKey", `{"n":1}`, nil)
+
+	tests := []struct {
+		key, contentType, body string
+		status                 int
+	}{
+		{"patchErrKey", "application/json", `{}`, http.StatusUnsupportedMediaType},
+		{"patchErrKey", MERGE_PATCH_CONTENT_TYPE, `[]`, http.StatusBadRequest},
+		{"patchErrKey", MERGE_PATCH_CONTENT_TYPE, `null`, http.StatusBadRequest},
+		{"patchErrKey", MERGE_PATCH_CONTENT_TYPE, `"value"`, http.StatusBadRequest},
+		{"patchMissing", MERGE_PATCH_CONTENT_TYPE, `{}`, http.StatusNotFound},
+		{"patchErrKey", JSON_PATCH_CONTENT_TYPE, `[{"op":"test","path":"/n","value":2}]`, http.StatusConflict},
+		{"patchErrKey", JSON_PATCH_CONTENT_TYPE, `[{"op":"remove","path":"/x"}]`, http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		rr := patch(t, tt.key, tt.contentType, tt.body, nil)
+		assert.Equal(t, tt.status, rr.Code, tt.body)
+	}
+
+	// Check a merge patch replacing the value with a non-object left it as is
+	req := httptest.NewRequest("GET", "/cache?key=patchErrKey", nil)
+	req.Header.Set("Accept", "application/json")
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(GetHandler).ServeHTTP(rr, req)
+	assert.JSONEq(t, `{"n":1}`, rr.Body.String())
+	rr = patch(t, "patchErrKey", MERGE_PATCH_CONTENT_TYPE, `null`, http.Header{"Accept": {"application/json"}})
+	assert.JSONEq(t, `{"code":"invalid_patch","message":"invalid patch: a merge patch that is not an object replaces the value, which must remain an object","key":"patchErrKey"}`, rr.Body.String())
+}
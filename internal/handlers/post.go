@@ -1,87 +1,219 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/vishaldc/go-cache/internal/apierror"
 	"github.com/vishaldc/go-cache/internal/cache"
 	"github.com/vishaldc/go-cache/internal/log"
 	"github.com/vishaldc/go-cache/internal/registry"
+	"github.com/vishaldc/go-cache/internal/tracing"
 	"go.uber.org/zap"
 )
 
+// TAGS_HEADER lists the comma separated tags a written key is grouped under
+const TAGS_HEADER = "X-Cache-Tags"
+
 func PostHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+		apierror.Write(w, r, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "invalid request method", "")
+		return
+	}
+
+	ns, ok := namespaceFor(w, r)
+	if !ok {
 		return
 	}
 
 	key := r.URL.Query().Get("key")
 	if key == "" {
 		log.Logger.Warn("missing key in request")
-		http.Error(w, "missing key in request", http.StatusBadRequest)
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeMissingKey, "missing key in request", "")
+		return
+	}
+
+	ttl, err := parseTTL(r.URL.Query().Get("ttl"))
+	if err != nil {
+		log.Logger.Warn("invalid ttl in request", zap.Error(err))
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidParameter, "invalid ttl in request", key)
 		return
 	}
 
+	stale, err := parseTTL(r.URL.Query().Get("stale"))
+	if err != nil {
+		log.Logger.Warn("invalid stale in request", zap.Error(err))
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidParameter, "invalid stale in request", key)
+		return
+	}
+
+	precondition, err := writePrecondition(r)
+	if err != nil {
+		log.Logger.Warn("invalid precondition in request", zap.Error(err))
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidParameter, "invalid precondition in request", key)
+		return
+	}
+
+	limitBody(w, r)
 	jsonDecoder := json.NewDecoder(r.Body)
 	var value map[string]any
-	err := jsonDecoder.Decode(&value)
+	err = jsonDecoder.Decode(&value)
+	if tooLarge(w, r, key, err) {
+		return
+	}
 	if err != nil {
 		log.Logger.Error("invalid request body", zap.Error(err))
-		http.Error(w, "invalid request body", http.StatusBadRequest)
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidBody, "invalid request body", key)
 		return
 	}
 
-	err = cache.Set(key, value)
+	opts := cache.SetOptions{TTL: ttl, Stale: stale, Tags: parseTags(r.Header.Get(TAGS_HEADER)), If: precondition}
+	before := ns.Snapshot(key)
+	_, span := tracing.StartCache(r.Context(), "set", ns.Name(), key)
+	version, err := ns.SetWithOptions(key, value, opts)
+	tracing.End(span, err)
+	if err == cache.ErrorPreconditionFailed {
+		log.Logger.Info("precondition failed", zap.String("key", key))
+		apierror.Write(w, r, http.StatusPreconditionFailed, cache.Code(err), "precondition failed", key)
+		return
+	}
+	if tooLarge(w, r, key, err) {
+		return
+	}
 	if err != nil {
 		log.Logger.Error("failed to set cache", zap.Error(err))
-		http.Error(w, "failed to set cache", http.StatusInternalServerError)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "failed to set cache", key)
+		return
+	}
+
+	// the write is undone when the store rejects it
+	if err := persist(ns, change{before: before, version: version}); err != nil {
+		apierror.Write(w, r, http.StatusBadGateway, apierror.CodeStoreFailed, "failed to write to store", key)
 		return
 	}
 
+	// replicas apply the write unconditionally with the same version so the
+	// entity tag is the same on every worker
+	opts.If = cache.Precondition{}
+	opts.Version = version
+	ctx := context.WithoutCancel(r.Context())
 	reg := registry.GetRegistry()
 
-	go func() {
-		err := reg.WriteToPool(key, value)
+	registry.Go(func() {
+		err := reg.WriteToPool(ctx, ns.Name(), key, value, opts)
 		if err != nil {
 			log.Logger.Error("failed to write to pool", zap.Error(err))
 		}
-	}()
+	})
 
 	log.Logger.Info("sync request completed", zap.String("key", key))
+	w.Header().Set("ETag", formatETag(version))
 	w.WriteHeader(http.StatusNoContent)
 }
 
 // SyncPostHandler handles the sync request from the worker
 func SyncPostHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+		apierror.Write(w, r, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "invalid request method", "")
+		return
+	}
+
+	ns, ok := createNamespaceFor(w, r)
+	if !ok {
 		return
 	}
 
 	key := r.URL.Query().Get("key")
 	if key == "" {
 		log.Logger.Warn("missing key in request")
-		http.Error(w, "missing key in request", http.StatusBadRequest)
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeMissingKey, "missing key in request", "")
+		return
+	}
+
+	ttl, err := parseTTL(r.URL.Query().Get("ttl"))
+	if err != nil {
+		log.Logger.Warn("invalid ttl in request", zap.Error(err))
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidParameter, "invalid ttl in request", key)
+		return
+	}
+
+	stale, err := parseTTL(r.URL.Query().Get("stale"))
+	if err != nil {
+		log.Logger.Warn("invalid stale in request", zap.Error(err))
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidParameter, "invalid stale in request", key)
 		return
 	}
 
+	limitSyncBody(w, r)
 	jsonDecoder := json.NewDecoder(r.Body)
 	var value map[string]any
-	err := jsonDecoder.Decode(&value)
+	err = jsonDecoder.Decode(&value)
+	if tooLarge(w, r, key, err) {
+		return
+	}
 	if err != nil {
 		log.Logger.Error("invalid request body", zap.Error(err))
-		http.Error(w, "invalid request body", http.StatusBadRequest)
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidBody, "invalid request body", key)
 		return
 	}
 
-	err = cache.Set(key, value)
+	version, err := strconv.ParseUint(r.URL.Query().Get("version"), 10, 64)
+	if err != nil {
+		version = 0
+	}
+
+	_, span := tracing.StartCache(r.Context(), "set", ns.Name(), key)
+	_, err = ns.SetWithOptions(key, value, cache.SetOptions{TTL: ttl, Stale: stale, Tags: r.URL.Query()["tag"], Version: version})
+	tracing.End(span, err)
+	if err == cache.ErrorStaleVersion {
+		// a newer write of the key arrived first, the replica keeps it
+		log.Logger.Info("stale sync request ignored", zap.String("key", key), zap.Uint64("version", version))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if tooLarge(w, r, key, err) {
+		return
+	}
 	if err != nil {
 		log.Logger.Error("failed to set cache", zap.Error(err))
-		http.Error(w, "failed to set cache", http.StatusInternalServerError)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "failed to set cache", key)
 		return
 	}
 
 	log.Logger.Info("sync request completed", zap.String("key", key))
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// parseTTL parses a ttl given either as a number of seconds or as a duration
+// such as 1m30s, an empty ttl means the key does not expire
+func parseTTL(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	var ttl time.Duration
+	if seconds, err := strconv.Atoi(s); err == nil {
+		ttl = time.Duration(seconds) * time.Second
+	} else if ttl, err = time.ParseDuration(s); err != nil {
+		return 0, err
+	}
+	if ttl < 0 {
+		return 0, errors.New("ttl must not be negative")
+	}
+	return ttl, nil
+}
+
+// parseTags splits a comma separated list of tags, ignoring empty entries
+func parseTags(s string) []string {
+	var tags []string
+	for _, tag := range strings.Split(s, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
@@ -3,12 +3,17 @@ package handlers
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/vishaldc/go-cache/internal/cache"
+	"github.com/vishaldc/go-cache/internal/store"
 )
 
 func TestPostHandler(t *testing.T) {
@@ -122,3 +127,97 @@ func TestSyncPostHandler(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, value, cachedValue)
 }
+
+func TestPostHandlerWithTTL(t *testing.T) {
+	// Create a request with a ttl that has already elapsed
+	req, err := http.NewRequest("POST", "/post?key=testKeyWithTTL&ttl=1ns", bytes.NewBuffer([]byte(`{"field1":"value1"}`)))
+	assert.NoError(t, err)
+
+	// Create a ResponseRecorder to record the response
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(PostHandler)
+
+	// Call the handler
+	handler.ServeHTTP(rr, req)
+
+	// Check the status code
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	// Check the item has expired
+	time.Sleep(time.Millisecond)
+	_, err = cache.Get("testKeyWithTTL")
+	assert.Equal(t, cache.ErrorKeyNotFound, err)
+}
+
+func TestPostHandlerInvalidTTL(t *testing.T) {
+	// Create a request to pass to our handler
+	req, err := http.NewRequest("POST", "/post?key=testKey&ttl=-5", bytes.NewBuffer([]byte(`{"field1":"value1"}`)))
+	assert.NoError(t, err)
+
+	// Create a ResponseRecorder to record the response
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(PostHandler)
+
+	// Call the handler
+	handler.ServeHTTP(rr, req)
+
+	// Check the status code
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, "invalid ttl in request\n", rr.Body.String())
+}
+
+func TestPostHandlerWriteThroughFailed(t *testing.T) {
+	// Register a write through store whose table does not exist
+	path := filepath.Join(t.TempDir(), "stores.json")
+	config := `[{"namespace": "unstored", "mode": "write-through", "driver": "sqlite", "dsn": ":memory:", "table": "entries"}]`
+	assert.NoError(t, os.WriteFile(path, []byte(config), 0o644))
+	assert.NoError(t, store.Setup(nil, path))
+	ns, err := cache.CreateNamespace("unstored")
+	assert.NoError(t, err)
+	assert.NoError(t, ns.Set("kept", map[string]any{"a": float64(1)}))
+
+	// Test the value is not cached when the store fails
+	req := httptest.NewRequest("POST", "/cache?ns=unstored&key=lost", bytes.NewBufferString(`{"a": 1}`))
+	rr := httptest.NewRecorder()
+	PostHandler(rr, req)
+	assert.Equal(t, http.StatusBadGateway, rr.Code)
+	_, err = ns.Get("lost")
+	assert.Equal(t, cache.ErrorKeyNotFound, err)
+
+	// Test an overwrite rejected by the store restores the previous value
+	req = httptest.NewRequest("POST", "/cache?ns=unstored&key=kept", bytes.NewBufferString(`{"a": 2}`))
+	rr = httptest.NewRecorder()
+	PostHandler(rr, req)
+	assert.Equal(t, http.StatusBadGateway, rr.Code)
+	value, err := ns.Get("kept")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"a": float64(1)}, value)
+
+	// Test the key is kept when the store fails to delete it
+	req = httptest.NewRequest("DELETE", "/cache?ns=unstored&key=kept", nil)
+	rr = httptest.NewRecorder()
+	DeleteHandler(rr, req)
+	assert.Equal(t, http.StatusBadGateway, rr.Code)
+	_, err = ns.Get("kept")
+	assert.NoError(t, err)
+}
+
+func TestSyncPostHandlerOutOfOrder(t *testing.T) {
+	sync := func(version int, body string) {
+		req := httptest.NewRequest("POST", fmt.Sprintf("/cache/sync?key=syncReordered&version=%d", version), bytes.NewBufferString(body))
+		rr := httptest.NewRecorder()
+		SyncPostHandler(rr, req)
+		assert.Equal(t, http.StatusNoContent, rr.Code)
+	}
+
+	// Apply v2 then a v1 delayed by replication
+	sync(2, `{"field1": "v2"}`)
+	sync(1, `{"field1": "v1"}`)
+
+	// Check the newer write is kept with its entity tag
+	ns, _ := cache.Namespace("")
+	value, version, err := ns.GetVersioned("syncReordered")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"field1": "v2"}, value)
+	assert.Equal(t, uint64(2), version)
+}
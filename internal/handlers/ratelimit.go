@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/vishaldc/go-cache/internal/apierror"
+	"github.com/vishaldc/go-cache/internal/log"
+	"github.com/vishaldc/go-cache/internal/ratelimit"
+	"go.uber.org/zap"
+)
+
+// SyncRateLimitHandler takes the tokens another worker took from the cluster
+// wide rate limit buckets
+func SyncRateLimitHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apierror.Write(w, r, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "invalid request method", "")
+		return
+	}
+
+	var usage map[string]float64
+	limitSyncBody(w, r)
+	err := json.NewDecoder(r.Body).Decode(&usage)
+	if tooLarge(w, r, "", err) {
+		return
+	}
+	if err != nil {
+		log.Logger.Error("invalid request body", zap.Error(err))
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidBody, "invalid request body", "")
+		return
+	}
+	ratelimit.Consume(usage)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"github.com/vishaldc/go-cache/internal/cache"
+	"github.com/vishaldc/go-cache/internal/log"
+	"github.com/vishaldc/go-cache/internal/store"
+	"go.uber.org/zap"
+)
+
+// change is a key written by a request, its state before the write and the
+// version written, 0 when the write removed the key
+type change struct {
+	before  cache.Snapshot
+	version uint64
+}
+
+// persist writes the keys changed by a request to the store of the namespace.
+// A key written again since is left to the write that replaced it. When the
+// store fails every key is restored to its snapshot, unless written again
+// since, so the cache never keeps a write the store rejected.
+func persist(ns *cache.Cache, changes ...change) error {
+	if !store.Configured(ns.Name()) {
+		return nil
+	}
+	writes, err := storeChanges(ns, changes)
+	if err == nil {
+		err = store.WriteAll(ns.Name(), writes)
+	}
+	if err != nil {
+		for _, c := range changes {
+			ns.Restore(c.before, c.version)
+		}
+		log.Logger.Warn("write undone after store failure", zap.String("namespace", ns.Name()), zap.Int("keys", len(changes)), zap.Error(err))
+	}
+	return err
+}
+
+// storeChanges reads the values to persist for changes from the cache
+func storeChanges(ns *cache.Cache, changes []change) ([]store.Change, error) {
+	writes := make([]store.Change, 0, len(changes))
+	for _, c := range changes {
+		if c.version == 0 {
+			writes = append(writes, store.Change{Key: c.before.Key, Delete: true})
+			continue
+		}
+		value, version, err := ns.Export(c.before.Key)
+		if err == cache.ErrorKeyNotFound || (err == nil && version != c.version) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		writes = append(writes, store.Change{Key: c.before.Key, Value: value, Version: version})
+	}
+	return writes, nil
+}
+
+// persistKeys removes keys from the store of the namespace before they are
+// deleted from the cache
+func persistKeys(ns *cache.Cache, keys []string) error {
+	writes := make([]store.Change, len(keys))
+	for i, key := range keys {
+		writes[i] = store.Change{Key: key, Delete: true}
+	}
+	return store.WriteAll(ns.Name(), writes)
+}
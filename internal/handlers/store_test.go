@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vishaldc/go-cache/internal/cache"
+	"github.com/vishaldc/go-cache/internal/store"
+)
+
+func TestWriteThroughPaths(t *testing.T) {
+	// Register a write through store on a database file the test reads back
+	dir := t.TempDir()
+	dsn := filepath.Join(dir, "store.db")
+	database, err := sql.Open("sqlite", dsn)
+	assert.NoError(t, err)
+	defer database.Close()
+	_, err = database.Exec(`CREATE TABLE entries (namespace TEXT, key TEXT, value TEXT, version INTEGER, updated_at TIMESTAMP, PRIMARY KEY (namespace, key))`)
+	assert.NoError(t, err)
+	path := filepath.Join(dir, "stores.json")
+	config := fmt.Sprintf(`[{"namespace": "stored", "mode": "write-through", "driver": "sqlite", "dsn": %q, "table": "entries"}]`, dsn)
+	assert.NoError(t, os.WriteFile(path, []byte(config), 0o644))
+	assert.NoError(t, store.Setup(nil, path))
+	_, err = cache.CreateNamespace("stored")
+	assert.NoError(t, err)
+
+	row := func(key string) string {
+		var v string
+		err := database.QueryRow(`SELECT value FROM entries WHERE namespace = 'stored' AND key = $1`, key).Scan(&v)
+		if err == sql.ErrNoRows {
+			return ""
+		}
+		assert.NoError(t, err)
+		return v
+	}
+	serve := func(handler http.HandlerFunc, req *http.Request) {
+		rr := httptest.NewRecorder()
+		handler(rr, req)
+		assert.Less(t, rr.Code, 300, rr.Body.String())
+	}
+
+	// Test sets and patches are persisted
+	serve(PostHandler, httptest.NewRequest("POST", "/cache?ns=stored&key=user:1", bytes.NewBufferString(`{"name": "ada"}`)))
+	req := httptest.NewRequest("PATCH", "/cache?ns=stored&key=user:1", bytes.NewBufferString(`{"age": 36}`))
+	req.Header.Set("Content-Type", MERGE_PATCH_CONTENT_TYPE)
+	serve(PatchHandler, req)
+	assert.Equal(t, `{"age":36,"name":"ada"}`, row("user:1"))
+
+	// Test counters and collections are persisted
+	serve(IncrHandler, httptest.NewRequest("POST", "/cache/incr?ns=stored&key=hits&by=2", nil))
+	assert.Equal(t, `{"value":2}`, row("hits"))
+	serve(CollectionHandler, httptest.NewRequest("POST", "/cache/rpush?ns=stored&key=queue", bytes.NewBufferString(`{"values": ["a"]}`)))
+	assert.Equal(t, `{"list":["a"]}`, row("queue"))
+	serve(CollectionHandler, httptest.NewRequest("POST", "/cache/lpop?ns=stored&key=queue", nil))
+	assert.Equal(t, "", row("queue"))
+
+	// Test transactions are persisted
+	body := `{"ops": [{"op": "delete", "key": "user:1"}, {"op": "set", "key": "user:2", "value": {"name": "alan"}, "tags": ["people"]}]}`
+	serve(TxnHandler, httptest.NewRequest("POST", "/cache/txn?ns=stored", bytes.NewBufferString(body)))
+	assert.Equal(t, "", row("user:1"))
+	assert.Equal(t, `{"name":"alan"}`, row("user:2"))
+
+	// Test invalidations are persisted
+	req = httptest.NewRequest("DELETE", "/cache/tags/people?ns=stored", nil)
+	req.SetPathValue("tag", "people")
+	serve(TagDeleteHandler, req)
+	assert.Equal(t, "", row("user:2"))
+
+	// Test flushes clear the store
+	serve(FlushHandler, httptest.NewRequest("POST", "/ns/stored/flush?ns=stored", nil))
+	assert.Equal(t, "", row("hits"))
+}
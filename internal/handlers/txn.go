@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/vishaldc/go-cache/internal/apierror"
+	"github.com/vishaldc/go-cache/internal/auth"
+	"github.com/vishaldc/go-cache/internal/cache"
+	"github.com/vishaldc/go-cache/internal/log"
+	"github.com/vishaldc/go-cache/internal/registry"
+	"github.com/vishaldc/go-cache/internal/tracing"
+	"go.uber.org/zap"
+)
+
+// TxnResponse is the response body of a committed transaction
+type TxnResponse struct {
+	Version uint64 `json:"version"`
+}
+
+// TxnHandler commits a transaction of set and delete operations guarded by
+// key conditions, e.g.
+//
+//	{"if": [{"key": "order:1", "version": 42}],
+//	 "ops": [{"op": "set", "key": "order:1", "value": {...}},
+//	         {"op": "delete", "key": "orders:pending:1"}]}
+//
+// The transaction is replicated to the pool as a single request.
+func TxnHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apierror.Write(w, r, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "invalid request method", "")
+		return
+	}
+
+	ns, ok := namespaceFor(w, r)
+	if !ok {
+		return
+	}
+
+	limitBody(w, r)
+	var txn cache.Txn
+	if err := json.NewDecoder(r.Body).Decode(&txn); tooLarge(w, r, "", err) {
+		return
+	} else if err != nil {
+		log.Logger.Error("invalid request body", zap.Error(err))
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidBody, "invalid request body", "")
+		return
+	}
+	txn.Version = 0
+	for _, cond := range txn.If {
+		if !allowed(w, r, ns, auth.Read, cond.Key) {
+			return
+		}
+	}
+	for _, op := range txn.Ops {
+		required := auth.Write
+		if op.Op == cache.TxnDelete {
+			required = auth.Delete
+		}
+		if !allowed(w, r, ns, required, op.Key) {
+			return
+		}
+	}
+
+	// the last operation on a key decides what is persisted
+	last := make(map[string]string, len(txn.Ops))
+	var keys []string
+	for _, op := range txn.Ops {
+		if _, ok := last[op.Key]; !ok {
+			keys = append(keys, op.Key)
+		}
+		last[op.Key] = op.Op
+	}
+	changes := make([]change, len(keys))
+	for i, key := range keys {
+		changes[i].before = ns.Snapshot(key)
+	}
+
+	_, span := tracing.StartCache(r.Context(), "commit", ns.Name(), "")
+	version, err := ns.Commit(txn)
+	tracing.End(span, err)
+	if !txnError(w, r, err) {
+		return
+	}
+	for i, key := range keys {
+		if last[key] == cache.TxnSet {
+			changes[i].version = version
+		}
+	}
+	if err := persist(ns, changes...); err != nil {
+		apierror.Write(w, r, http.StatusBadGateway, apierror.CodeStoreFailed, "failed to write to store", "")
+		return
+	}
+
+	// replicas apply the operations unconditionally with the same version
+	txn.If = nil
+	txn.Version = version
+	ctx := context.WithoutCancel(r.Context())
+	reg := registry.GetRegistry()
+
+	registry.Go(func() {
+		err := reg.CommitInPool(ctx, ns.Name(), txn)
+		if err != nil {
+			log.Logger.Error("failed to write to pool", zap.Error(err))
+		}
+	})
+
+	log.Logger.Info("txn request completed", zap.Int("ops", len(txn.Ops)))
+	writeJSON(w, r, TxnResponse{Version: version})
+}
+
+// SyncTxnHandler applies a transaction replicated from another worker
+func SyncTxnHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apierror.Write(w, r, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "invalid request method", "")
+		return
+	}
+
+	ns, ok := createNamespaceFor(w, r)
+	if !ok {
+		return
+	}
+
+	var txn cache.Txn
+	limitSyncBody(w, r)
+	err := json.NewDecoder(r.Body).Decode(&txn)
+	if tooLarge(w, r, "", err) {
+		return
+	}
+	if err != nil {
+		log.Logger.Error("invalid request body", zap.Error(err))
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidBody, "invalid request body", "")
+		return
+	}
+
+	_, span := tracing.StartCache(r.Context(), "commit", ns.Name(), "")
+	_, err = ns.Commit(txn)
+	tracing.End(span, err)
+	if !txnError(w, r, err) {
+		return
+	}
+
+	log.Logger.Info("sync request completed", zap.Int("ops", len(txn.Ops)))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// txnError writes the response for a failed transaction and reports whether
+// err is nil
+func txnError(w http.ResponseWriter, r *http.Request, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, cache.ErrorPreconditionFailed):
+		log.Logger.Info("txn precondition failed", zap.Error(err))
+		apierror.Write(w, r, http.StatusPreconditionFailed, cache.Code(err), err.Error(), "")
+	case errors.Is(err, cache.ErrorInvalidTxn):
+		log.Logger.Warn("invalid txn", zap.Error(err))
+		apierror.Write(w, r, http.StatusBadRequest, cache.Code(err), err.Error(), "")
+	case tooLarge(w, r, "", err):
+	default:
+		log.Logger.Error("failed to commit txn", zap.Error(err))
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "failed to commit txn", "")
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func txn(t *testing.T, handler http.HandlerFunc, body string) *httptest.ResponseRecorder {
+	// Create a request to pass to our handler
+	req, err := http.NewRequest("POST", "/cache/txn", bytes.NewBufferString(body))
+	assert.NoError(t, err)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestTxnHandler(t *testing.T) {
+	rr := post(t, "txnOrder", `{"status":"pending"}`, nil)
+	version := strings.Trim(rr.Header().Get("ETag"), `"`)
+
+	body := fmt.Sprintf(`{
+		"if": [{"key": "txnOrder", "version": %s}],
+		"ops": [
+			{"op": "set", "key": "txnOrder", "value": {"status": "shipped"}},
+			{"op": "set", "key": "txnShipped", "value": {"order": "txnOrder"}}
+		]
+	}`, version)
+	rr = txn(t, TxnHandler, body)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"version"`)
+
+	// Check replaying the transaction fails on the stale version
+	rr = txn(t, TxnHandler, body)
+	assert.Equal(t, http.StatusPreconditionFailed, rr.Code)
+
+	// Check an invalid transaction is rejected
+	rr = txn(t, TxnHandler, `{"ops": []}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	rr = txn(t, TxnHandler, `{"ops": [{"op": "set", "key": "a", "ttl": "x"}]}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestSyncTxnHandler(t *testing.T) {
+	rr := txn(t, SyncTxnHandler, `{"version": 42, "ops": [{"op": "set", "key": "syncTxnKey", "value": {"n": 1}}]}`)
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	// Check the write was applied with the replicated version
+	req, err := http.NewRequest("GET", "/cache?key=syncTxnKey", nil)
+	assert.NoError(t, err)
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(GetHandler).ServeHTTP(rr, req)
+	assert.Equal(t, `"42"`, rr.Header().Get("ETag"))
+}
+
+func TestSyncTxnHandlerOutOfOrder(t *testing.T) {
+	rr := txn(t, SyncTxnHandler, `{"version": 52, "ops": [{"op": "set", "key": "syncTxnReordered", "value": {"n": 2}}]}`)
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	rr = txn(t, SyncTxnHandler, `{"version": 51, "ops": [{"op": "set", "key": "syncTxnReordered", "value": {"n": 1}}]}`)
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	// Check the older transaction did not replace the newer value
+	req, err := http.NewRequest("GET", "/cache?key=syncTxnReordered", nil)
+	assert.NoError(t, err)
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(GetHandler).ServeHTTP(rr, req)
+	assert.Equal(t, `"52"`, rr.Header().Get("ETag"))
+	assert.JSONEq(t, `{"n": 2}`, rr.Body.String())
+}
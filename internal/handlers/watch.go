@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/vishaldc/go-cache/internal/apierror"
+	"github.com/vishaldc/go-cache/internal/cache"
+	"github.com/vishaldc/go-cache/internal/log"
+	"go.uber.org/zap"
+)
+
+// WATCH_BUFFER is the number of events buffered per watcher before events are dropped
+const WATCH_BUFFER = 256
+
+// WatchHandler streams change events of the namespace as newline delimited
+// JSON until the client disconnects
+func WatchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		log.Logger.Warn("invalid request method", zap.String("method", r.Method))
+		apierror.Write(w, r, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "invalid request method", "")
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		log.Logger.Error("streaming is not supported")
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "streaming is not supported", "")
+		return
+	}
+
+	ns, ok := namespaceFor(w, r)
+	if !ok {
+		return
+	}
+
+	prefix := r.URL.Query().Get("prefix")
+	events, unsubscribe := cache.Subscribe(WATCH_BUFFER)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	log.Logger.Info("watch started", zap.String("prefix", prefix))
+	encoder := json.NewEncoder(w)
+	for {
+		select {
+		case <-r.Context().Done():
+			log.Logger.Info("watch completed", zap.String("prefix", prefix))
+			return
+		case e := <-events:
+			if e.Namespace != ns.Name() || !strings.HasPrefix(e.Key, prefix) {
+				continue
+			}
+			if err := encoder.Encode(e); err != nil {
+				log.Logger.Warn("failed to write event", zap.Error(err))
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,401 @@
+package loader
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vishaldc/go-cache/internal/cache"
+	"github.com/vishaldc/go-cache/internal/log"
+	"github.com/vishaldc/go-cache/internal/registry"
+	"github.com/vishaldc/go-cache/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// LOAD_TIMEOUT bounds the query run to load a missing key
+const LOAD_TIMEOUT = 5 * time.Second
+
+var ErrorNoLoader = errors.New("no loader for key")
+
+// Loader loads the keys of a namespace starting with a prefix from the
+// database or a webhook when they are missing from the cache. The query is
+// run with the key without its prefix as $1 and must return at most one row:
+// its columns become the fields of the cached value, or a single JSON object
+// column becomes the value itself. The webhook is called with a GET, the key
+// without its prefix replacing {key} in its url or added as the key
+// parameter, and must answer with a JSON object or a 404.
+type Loader struct {
+	Namespace string
+	Prefix    string
+	Query     string
+	Webhook   string
+	// TTL expires the loaded keys, zero uses the default ttl of the namespace
+	TTL time.Duration
+	// Stale serves the loaded keys stale for the duration after they expire
+	// while they are refreshed in the background
+	Stale time.Duration
+}
+
+type loaderJSON struct {
+	Namespace string `json:"namespace,omitempty"`
+	Prefix    string `json:"prefix,omitempty"`
+	Query     string `json:"query,omitempty"`
+	Webhook   string `json:"webhook,omitempty"`
+	TTL       string `json:"ttl,omitempty"`
+	Stale     string `json:"stale,omitempty"`
+}
+
+// MarshalJSON encodes the ttl and grace period as duration strings such as 5m
+func (l Loader) MarshalJSON() ([]byte, error) {
+	v := loaderJSON{Namespace: l.Namespace, Prefix: l.Prefix, Query: l.Query, Webhook: l.Webhook}
+	if l.TTL > 0 {
+		v.TTL = l.TTL.String()
+	}
+	if l.Stale > 0 {
+		v.Stale = l.Stale.String()
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON decodes the ttl and grace period from duration strings such
+// as 5m
+func (l *Loader) UnmarshalJSON(b []byte) error {
+	var v loaderJSON
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	if (v.Query == "") == (v.Webhook == "") {
+		return errors.New("loader needs either a query or a webhook")
+	}
+	*l = Loader{Namespace: v.Namespace, Prefix: v.Prefix, Query: v.Query, Webhook: v.Webhook}
+	for _, d := range []struct {
+		s   string
+		dst *time.Duration
+	}{{v.TTL, &l.TTL}, {v.Stale, &l.Stale}} {
+		if d.s == "" {
+			continue
+		}
+		duration, err := time.ParseDuration(d.s)
+		if err != nil {
+			return err
+		}
+		if duration < 0 {
+			return errors.New("ttl must not be negative")
+		}
+		*d.dst = duration
+	}
+	return nil
+}
+
+var mu sync.RWMutex
+var db *sql.DB
+var loaders []Loader
+var group = flight{calls: make(map[string]*call)}
+var client = &http.Client{Timeout: LOAD_TIMEOUT}
+
+// refreshing holds the stale keys being refreshed in the background and
+// failed the stale keys whose last refresh failed
+var refreshMu sync.Mutex
+var refreshing = make(map[string]bool)
+var failed = make(map[string]bool)
+
+// Setup registers the loaders defined in a JSON file, a list of loaders such
+// as [{"namespace": "users", "prefix": "user:", "query": "...", "ttl": "5m"}],
+// run against database
+func Setup(database *sql.DB, path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var configs []Loader
+	if err := json.Unmarshal(b, &configs); err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	db = database
+	loaders = configs
+	log.Logger.Info("loaders registered", zap.Int("loaders", len(loaders)))
+	return nil
+}
+
+// find returns the loader of the namespace with the longest prefix of key
+func find(ns, key string) (Loader, *sql.DB, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	var found Loader
+	ok := false
+	for _, l := range loaders {
+		if l.Namespace == ns && strings.HasPrefix(key, l.Prefix) && (!ok || len(l.Prefix) > len(found.Prefix)) {
+			found, ok = l, true
+		}
+	}
+	return found, db, ok && (db != nil || found.Webhook != "")
+}
+
+// Load reads a key missing from the namespace through its loader, caches it
+// and replicates it to the pool. Concurrent loads of the same key are
+// coalesced into a single query. ErrorNoLoader is returned when no loader
+// matches the key and cache.ErrorKeyNotFound when the query returns no row.
+func Load(ctx context.Context, ns *cache.Cache, key string) (map[string]any, uint64, error) {
+	l, database, ok := find(ns.Name(), key)
+	if !ok {
+		return nil, 0, ErrorNoLoader
+	}
+
+	return group.do(ns.Name()+"/"+key, func() (map[string]any, uint64, error) {
+		// a load that finished while this one waited for the flight may
+		// have cached the key already
+		if value, version, err := ns.GetVersioned(key); err == nil {
+			return value, version, nil
+		}
+
+		// the load is shared with the misses waiting for it, so it is not
+		// cancelled with the request that started it
+		ctx, span := tracing.Start(context.WithoutCancel(ctx), "loader.load",
+			attribute.String("cache.namespace", ns.Name()), attribute.String("cache.key", key))
+		loadCtx, cancel := context.WithTimeout(ctx, LOAD_TIMEOUT)
+		defer cancel()
+		var value map[string]any
+		var err error
+		if l.Webhook != "" {
+			value, err = callWebhook(loadCtx, l.Webhook, strings.TrimPrefix(key, l.Prefix))
+		} else {
+			value, err = queryRow(loadCtx, database, l.Query, strings.TrimPrefix(key, l.Prefix))
+		}
+		tracing.End(span, err)
+		if err == cache.ErrorKeyNotFound {
+			return nil, 0, err
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to load %q: %w", key, err)
+		}
+
+		opts := cache.SetOptions{TTL: l.TTL, Stale: l.Stale}
+		if opts.TTL == 0 {
+			opts.TTL = ns.Config().DefaultTTL
+		}
+		if opts.Version, err = ns.SetWithOptions(key, value, opts); err != nil {
+			return nil, 0, err
+		}
+
+		// replicas store the loaded value instead of querying on their own miss
+		reg := registry.GetRegistry()
+		registry.Go(func() {
+			err := reg.WriteToPool(ctx, ns.Name(), key, value, opts)
+			if err != nil {
+				log.Logger.Error("failed to write to pool", zap.Error(err))
+			}
+		})
+
+		log.Logger.Info("key loaded", zap.String("namespace", ns.Name()), zap.String("key", key))
+		return value, opts.Version, nil
+	})
+}
+
+// Revalidate refreshes a stale key of the namespace in the background
+// through its loader, unless a refresh of the key is already running. The
+// stale value keeps being served when the refresh fails. It reports whether
+// a loader covers the key.
+func Revalidate(ctx context.Context, ns *cache.Cache, key string) bool {
+	if _, _, ok := find(ns.Name(), key); !ok {
+		return false
+	}
+
+	id := ns.Name() + "/" + key
+	refreshMu.Lock()
+	if refreshing[id] {
+		refreshMu.Unlock()
+		return true
+	}
+	refreshing[id] = true
+	refreshMu.Unlock()
+
+	ctx = context.WithoutCancel(ctx)
+	registry.Go(func() {
+		_, _, err := Load(ctx, ns, key)
+		if err == cache.ErrorKeyNotFound {
+			// the source no longer has the key, stop serving it
+			ns.Delete(key)
+			reg := registry.GetRegistry()
+			if err := reg.DeleteFromPool(ctx, ns.Name(), key); err != nil {
+				log.Logger.Error("failed to write to pool", zap.Error(err))
+			}
+			err = nil
+		}
+		refreshMu.Lock()
+		delete(refreshing, id)
+		if err != nil {
+			failed[id] = true
+		} else {
+			delete(failed, id)
+		}
+		refreshMu.Unlock()
+		if err != nil {
+			log.Logger.Warn("failed to refresh stale key", zap.String("namespace", ns.Name()), zap.String("key", key), zap.Error(err))
+		}
+	})
+	return true
+}
+
+// RevalidationFailed reports whether the last refresh of a stale key failed
+func RevalidationFailed(ns *cache.Cache, key string) bool {
+	refreshMu.Lock()
+	defer refreshMu.Unlock()
+	return failed[ns.Name()+"/"+key]
+}
+
+// callWebhook gets the value of a key from a webhook
+func callWebhook(ctx context.Context, webhook string, key string) (map[string]any, error) {
+	target := strings.ReplaceAll(webhook, "{key}", url.PathEscape(key))
+	if target == webhook {
+		u, err := url.Parse(webhook)
+		if err != nil {
+			return nil, err
+		}
+		params := u.Query()
+		params.Set("key", key)
+		u.RawQuery = params.Encode()
+		target = u.String()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, cache.ErrorKeyNotFound
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	var value map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&value); err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return nil, errors.New("webhook returned no object")
+	}
+	return value, nil
+}
+
+// queryRow runs query and converts its first row into a cached value
+func queryRow(ctx context.Context, database *sql.DB, query string, arg string) (map[string]any, error) {
+	rows, err := database.QueryContext(ctx, query, arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, cache.ErrorKeyNotFound
+	}
+	values := make([]any, len(columns))
+	ptrs := make([]any, len(columns))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	if err := rows.Scan(ptrs...); err != nil {
+		return nil, err
+	}
+
+	if len(columns) == 1 {
+		if object, ok := jsonObject(values[0]); ok {
+			return object, nil
+		}
+	}
+	value := make(map[string]any, len(columns))
+	for i, column := range columns {
+		value[column] = columnValue(values[i])
+	}
+	return value, nil
+}
+
+// jsonObject decodes a json, jsonb or text column holding a JSON object
+func jsonObject(v any) (map[string]any, bool) {
+	var b []byte
+	switch v := v.(type) {
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return nil, false
+	}
+	var object map[string]any
+	if err := json.Unmarshal(b, &object); err != nil || object == nil {
+		return nil, false
+	}
+	return object, true
+}
+
+// columnValue converts a scanned column into a value that encodes to JSON the
+// way the database renders it
+func columnValue(v any) any {
+	switch v := v.(type) {
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	}
+	return v
+}
+
+// call is a load in flight shared by concurrent misses of a key
+type call struct {
+	wg      sync.WaitGroup
+	value   map[string]any
+	version uint64
+	err     error
+}
+
+// flight coalesces concurrent calls with the same key into one
+type flight struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+func (f *flight) do(key string, fn func() (map[string]any, uint64, error)) (map[string]any, uint64, error) {
+	f.mu.Lock()
+	if c, ok := f.calls[key]; ok {
+		f.mu.Unlock()
+		c.wg.Wait()
+		return c.value, c.version, c.err
+	}
+	c := &call{}
+	c.wg.Add(1)
+	f.calls[key] = c
+	f.mu.Unlock()
+
+	defer func() {
+		f.mu.Lock()
+		delete(f.calls, key)
+		f.mu.Unlock()
+		c.wg.Done()
+	}()
+	c.value, c.version, c.err = fn()
+	return c.value, c.version, c.err
+}
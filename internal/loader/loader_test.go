@@ -0,0 +1,271 @@
+package loader
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vishaldc/go-cache/internal/cache"
+	_ "modernc.org/sqlite"
+)
+
+func setupLoaders(t *testing.T, config string) {
+	t.Helper()
+	database, err := sql.Open("sqlite", ":memory:")
+	assert.NoError(t, err)
+	database.SetMaxOpenConns(1)
+	t.Cleanup(func() { database.Close() })
+
+	for _, stmt := range []string{
+		`CREATE TABLE users (id TEXT PRIMARY KEY, name TEXT, age INTEGER)`,
+		`INSERT INTO users VALUES ('1', 'ada', 36)`,
+		`CREATE TABLE documents (id TEXT PRIMARY KEY, body TEXT)`,
+		`INSERT INTO documents VALUES ('1', '{"title": "notes", "pages": 3}')`,
+	} {
+		_, err := database.Exec(stmt)
+		assert.NoError(t, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "loaders.json")
+	assert.NoError(t, os.WriteFile(path, []byte(config), 0o644))
+	assert.NoError(t, Setup(database, path))
+	t.Cleanup(func() {
+		mu.Lock()
+		db, loaders = nil, nil
+		mu.Unlock()
+	})
+}
+
+func TestLoad(t *testing.T) {
+	setupLoaders(t, `[
+		{"namespace": "loader", "prefix": "user:", "query": "SELECT name, age FROM users WHERE id = $1", "ttl": "1m"},
+		{"namespace": "loader", "prefix": "doc:", "query": "SELECT body FROM documents WHERE id = $1"}
+	]`)
+	ns, err := cache.CreateNamespace("loader")
+	assert.NoError(t, err)
+
+	// Test the columns become the fields of the value
+	value, version, err := Load(context.Background(), ns, "user:1")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"name": "ada", "age": int64(36)}, value)
+	assert.NotZero(t, version)
+
+	// Test the loaded key is cached
+	cached, cachedVersion, err := ns.GetVersioned("user:1")
+	assert.NoError(t, err)
+	assert.Equal(t, value, cached)
+	assert.Equal(t, version, cachedVersion)
+
+	// Test a cached key is not queried again
+	_, err = db.Exec(`DELETE FROM users`)
+	assert.NoError(t, err)
+	value, reloaded, err := Load(context.Background(), ns, "user:1")
+	assert.NoError(t, err)
+	assert.Equal(t, cached, value)
+	assert.Equal(t, version, reloaded)
+
+	// Test a single JSON object column becomes the value
+	value, _, err = Load(context.Background(), ns, "doc:1")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"title": "notes", "pages": float64(3)}, value)
+
+	// Test a missing row
+	_, _, err = Load(context.Background(), ns, "user:2")
+	assert.Equal(t, cache.ErrorKeyNotFound, err)
+	_, _, err = ns.GetVersioned("user:2")
+	assert.Equal(t, cache.ErrorKeyNotFound, err)
+
+	// Test keys without a loader
+	_, _, err = Load(context.Background(), ns, "order:1")
+	assert.Equal(t, ErrorNoLoader, err)
+	other, err := cache.CreateNamespace("loader-other")
+	assert.NoError(t, err)
+	_, _, err = Load(context.Background(), other, "user:1")
+	assert.Equal(t, ErrorNoLoader, err)
+}
+
+func TestSetupInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "loaders.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`[{"namespace": "loader"}]`), 0o644))
+	assert.Error(t, Setup(nil, path))
+
+	assert.NoError(t, os.WriteFile(path, []byte(`[{"query": "SELECT 1", "webhook": "http://localhost"}]`), 0o644))
+	assert.Error(t, Setup(nil, path))
+
+	assert.NoError(t, os.WriteFile(path, []byte(`[{"query": "SELECT 1", "ttl": "soon"}]`), 0o644))
+	assert.Error(t, Setup(nil, path))
+}
+
+func TestFind(t *testing.T) {
+	setupLoaders(t, `[
+		{"namespace": "loader", "prefix": "user:", "query": "SELECT 1"},
+		{"namespace": "loader", "prefix": "user:admin:", "query": "SELECT 2"},
+		{"namespace": "loader", "query": "SELECT 3"}
+	]`)
+
+	// Test the longest prefix wins
+	l, _, ok := find("loader", "user:admin:1")
+	assert.True(t, ok)
+	assert.Equal(t, "SELECT 2", l.Query)
+	l, _, ok = find("loader", "user:1")
+	assert.True(t, ok)
+	assert.Equal(t, "SELECT 1", l.Query)
+
+	// Test the empty prefix matches every key of the namespace
+	l, _, ok = find("loader", "order:1")
+	assert.True(t, ok)
+	assert.Equal(t, "SELECT 3", l.Query)
+	_, _, ok = find("", "order:1")
+	assert.False(t, ok)
+}
+
+func TestFlight(t *testing.T) {
+	f := flight{calls: make(map[string]*call)}
+	var calls atomic.Int32
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	var wg sync.WaitGroup
+	versions := make([]uint64, 10)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, versions[0], _ = f.do("key", func() (map[string]any, uint64, error) {
+			calls.Add(1)
+			close(started)
+			<-release
+			return map[string]any{"a": 1}, 7, nil
+		})
+	}()
+	<-started
+
+	// Test concurrent calls wait for the call in flight
+	for i := 1; i < len(versions); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, versions[i], _ = f.do("key", func() (map[string]any, uint64, error) {
+				calls.Add(1)
+				return nil, 0, nil
+			})
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), calls.Load())
+	for _, v := range versions {
+		assert.Equal(t, uint64(7), v)
+	}
+
+	// Test a finished call is not reused
+	_, v, _ := f.do("key", func() (map[string]any, uint64, error) { return nil, 8, nil })
+	assert.Equal(t, uint64(8), v)
+}
+
+func TestLoadWebhook(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/products/1" && r.URL.Query().Get("key") != "1" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"name": "lamp"}`)
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "loaders.json")
+	config := fmt.Sprintf(`[
+		{"namespace": "webhook", "prefix": "product:", "webhook": "%s/products/{key}"},
+		{"namespace": "webhook", "prefix": "item:", "webhook": "%s/items"}
+	]`, server.URL, server.URL)
+	assert.NoError(t, os.WriteFile(path, []byte(config), 0o644))
+	assert.NoError(t, Setup(nil, path))
+	t.Cleanup(func() {
+		mu.Lock()
+		loaders = nil
+		mu.Unlock()
+	})
+	ns, err := cache.CreateNamespace("webhook")
+	assert.NoError(t, err)
+
+	// Test the key replaces the placeholder or is added as a parameter
+	value, _, err := Load(context.Background(), ns, "product:1")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"name": "lamp"}, value)
+	value, _, err = Load(context.Background(), ns, "item:1")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"name": "lamp"}, value)
+
+	// Test a 404 is a missing key
+	_, _, err = Load(context.Background(), ns, "product:2")
+	assert.Equal(t, cache.ErrorKeyNotFound, err)
+}
+
+func TestRevalidate(t *testing.T) {
+	var calls atomic.Int32
+	var failing atomic.Bool
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-release
+		if failing.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintf(w, `{"calls": %d}`, calls.Load())
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "loaders.json")
+	config := fmt.Sprintf(`[{"namespace": "revalidate", "webhook": "%s", "ttl": "1m", "stale": "1h"}]`, server.URL)
+	assert.NoError(t, os.WriteFile(path, []byte(config), 0o644))
+	assert.NoError(t, Setup(nil, path))
+	t.Cleanup(func() {
+		mu.Lock()
+		loaders = nil
+		mu.Unlock()
+	})
+	ns, err := cache.CreateNamespace("revalidate")
+	assert.NoError(t, err)
+	_, err = ns.SetWithOptions("key", map[string]any{"calls": float64(0)}, cache.SetOptions{TTL: time.Millisecond, Stale: time.Hour})
+	assert.NoError(t, err)
+	time.Sleep(5 * time.Millisecond)
+
+	// Test a single refresh runs for concurrent stale reads
+	assert.True(t, Revalidate(context.Background(), ns, "key"))
+	assert.True(t, Revalidate(context.Background(), ns, "key"))
+	assert.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+	close(release)
+	assert.Eventually(t, func() bool {
+		value, err := ns.Get("key")
+		return err == nil && value["calls"] == float64(1)
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, int32(1), calls.Load())
+
+	// Test a failed refresh keeps the stale value and is reported
+	failing.Store(true)
+	_, err = ns.SetWithOptions("key", map[string]any{"calls": float64(0)}, cache.SetOptions{TTL: time.Millisecond, Stale: time.Hour})
+	assert.NoError(t, err)
+	time.Sleep(5 * time.Millisecond)
+	assert.True(t, Revalidate(context.Background(), ns, "key"))
+	assert.Eventually(t, func() bool { return RevalidationFailed(ns, "key") }, time.Second, time.Millisecond)
+	value, _, staleness, err := ns.GetStale("key")
+	assert.NoError(t, err)
+	assert.True(t, staleness.Stale)
+	assert.Equal(t, map[string]any{"calls": float64(0)}, value)
+
+	// Test keys without a loader are not refreshed
+	other, err := cache.CreateNamespace("revalidate-other")
+	assert.NoError(t, err)
+	assert.False(t, Revalidate(context.Background(), other, "key"))
+}
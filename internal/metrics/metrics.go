@@ -0,0 +1,168 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/vishaldc/go-cache/internal/cache"
+)
+
+var (
+	// Registry holds every metric of the worker
+	Registry = prometheus.NewRegistry()
+
+	// RequestDuration observes the latency of the requests per route and status
+	RequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "go_cache_http_request_duration_seconds",
+		Help:    "Latency of the HTTP requests by route and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"handler", "code"})
+
+	// ReplicationDuration observes the latency of the sync requests per peer
+	ReplicationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "go_cache_replication_duration_seconds",
+		Help:    "Latency of the sync requests sent to a peer by sync path.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"peer", "path"})
+
+	// FanoutDuration observes the latency of replicating a write to the pool
+	FanoutDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "go_cache_replication_fanout_duration_seconds",
+		Help:    "Latency of replicating a request to every peer of the pool by sync path.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"path"})
+
+	// ReplicationFailures counts the sync requests that failed per peer
+	ReplicationFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "go_cache_replication_failures_total",
+		Help: "Sync requests to a peer that failed or were rejected, by sync path.",
+	}, []string{"peer", "path"})
+
+	// Heartbeats counts the heartbeats recorded in the registry by result
+	Heartbeats = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "go_cache_heartbeats_total",
+		Help: "Heartbeats recorded in the registry by result.",
+	}, []string{"result"})
+
+	// RejectedWrites counts the writes refused for exceeding a size limit
+	RejectedWrites = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "go_cache_rejected_writes_total",
+		Help: "Writes refused with a 413 for exceeding a size limit, by reason.",
+	}, []string{"reason"})
+
+	// NotificationLag observes the delay between a change notified by the
+	// database and its invalidation, for notifications carrying a sent time
+	NotificationLag = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "go_cache_notification_lag_seconds",
+		Help:    "Delay between a database change notification being sent and applied.",
+		Buckets: []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+	})
+
+	// PoolSize is the number of peers found by the last pool refresh
+	PoolSize = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "go_cache_pool_size",
+		Help: "Number of peers in the pool after the last refresh.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		RequestDuration,
+		ReplicationDuration,
+		FanoutDuration,
+		ReplicationFailures,
+		Heartbeats,
+		RejectedWrites,
+		NotificationLag,
+		PoolSize,
+		namespaceCollector{},
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Handler serves the metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// Result returns the label of a result, success or failure
+func Result(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
+
+// Middleware observes the latency of the requests served by next, labelled
+// with the route pattern matched by the mux
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		// the pattern is set by the mux, unmatched requests share one label
+		pattern := r.Pattern
+		if pattern == "" {
+			pattern = "unmatched"
+		}
+		RequestDuration.WithLabelValues(pattern, strconv.Itoa(rec.status)).Observe(time.Since(start).Seconds())
+	})
+}
+
+// statusRecorder records the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+// Flush lets streaming handlers such as watch flush through the recorder
+func (s *statusRecorder) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+var (
+	keysDesc      = prometheus.NewDesc("go_cache_entries", "Number of keys stored by namespace.", []string{"namespace"}, nil)
+	bytesDesc     = prometheus.NewDesc("go_cache_bytes", "Bytes of values stored by namespace.", []string{"namespace"}, nil)
+	evictionsDesc = prometheus.NewDesc("go_cache_evictions_total", "Keys evicted to respect the namespace limits.", []string{"namespace"}, nil)
+	hitsDesc      = prometheus.NewDesc("go_cache_hits_total", "Reads that found the key by namespace.", []string{"namespace"}, nil)
+	missesDesc    = prometheus.NewDesc("go_cache_misses_total", "Reads that did not find the key by namespace.", []string{"namespace"}, nil)
+)
+
+// namespaceCollector reports the usage of every namespace when scraped
+type namespaceCollector struct{}
+
+func (namespaceCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- keysDesc
+	ch <- bytesDesc
+	ch <- evictionsDesc
+	ch <- hitsDesc
+	ch <- missesDesc
+}
+
+func (namespaceCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, ns := range cache.Namespaces() {
+		ch <- prometheus.MustNewConstMetric(keysDesc, prometheus.GaugeValue, float64(ns.Keys), ns.Name)
+		ch <- prometheus.MustNewConstMetric(bytesDesc, prometheus.GaugeValue, float64(ns.Bytes), ns.Name)
+		ch <- prometheus.MustNewConstMetric(evictionsDesc, prometheus.CounterValue, float64(ns.Evictions), ns.Name)
+		ch <- prometheus.MustNewConstMetric(hitsDesc, prometheus.CounterValue, float64(ns.Hits), ns.Name)
+		ch <- prometheus.MustNewConstMetric(missesDesc, prometheus.CounterValue, float64(ns.Misses), ns.Name)
+	}
+}
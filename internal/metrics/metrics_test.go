@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vishaldc/go-cache/internal/cache"
+)
+
+// scrape returns the metrics served by Handler
+func scrape(t *testing.T) string {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	Handler().ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	return rr.Body.String()
+}
+
+func TestMiddleware(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /cache", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "key not found in cache", http.StatusNotFound)
+	})
+	handler := Middleware(mux)
+
+	// Test requests are observed by route pattern and status code
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/cache?key=a", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/missing", nil))
+	body := scrape(t)
+	assert.Contains(t, body, `go_cache_http_request_duration_seconds_count{code="404",handler="GET /cache"} 1`)
+	assert.Contains(t, body, `go_cache_http_request_duration_seconds_count{code="404",handler="unmatched"} 1`)
+
+	// Test the recorder still lets streaming handlers flush
+	var flushed bool
+	mux.HandleFunc("GET /watch", func(w http.ResponseWriter, r *http.Request) {
+		_, flushed = w.(http.Flusher)
+	})
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/watch", nil))
+	assert.True(t, flushed)
+}
+
+func TestNamespaceCollector(t *testing.T) {
+	ns, err := cache.CreateNamespace("metrics")
+	assert.NoError(t, err)
+	assert.NoError(t, ns.Set("key", map[string]any{"a": float64(1)}))
+	_, _, _, err = ns.GetStale("key")
+	assert.NoError(t, err)
+	_, _, _, err = ns.GetStale("missing")
+	assert.Equal(t, cache.ErrorKeyNotFound, err)
+
+	// Test the usage of every namespace is reported when scraped
+	body := scrape(t)
+	assert.Contains(t, body, `go_cache_entries{namespace="metrics"} 1`)
+	assert.Contains(t, body, `go_cache_hits_total{namespace="metrics"} 1`)
+	assert.Contains(t, body, `go_cache_misses_total{namespace="metrics"} 1`)
+	assert.Contains(t, body, `go_cache_evictions_total{namespace="metrics"} 0`)
+}
+
+func TestResult(t *testing.T) {
+	assert.Equal(t, "success", Result(nil))
+	assert.Equal(t, "failure", Result(errors.New("failed")))
+}
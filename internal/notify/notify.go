@@ -0,0 +1,270 @@
+package notify
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/vishaldc/go-cache/internal/cache"
+	"github.com/vishaldc/go-cache/internal/log"
+	"github.com/vishaldc/go-cache/internal/metrics"
+	"go.uber.org/zap"
+)
+
+const (
+	// MIN_RECONNECT_INTERVAL is the delay before the first reconnect attempt
+	// after the listener connection is lost
+	MIN_RECONNECT_INTERVAL = 1 * time.Second
+
+	// MAX_RECONNECT_INTERVAL caps the delay between reconnect attempts
+	MAX_RECONNECT_INTERVAL = 1 * time.Minute
+
+	// PING_INTERVAL is the idle period after which the connection is checked
+	PING_INTERVAL = 90 * time.Second
+)
+
+var ErrorInvalidNotification = errors.New("invalid notification")
+
+// Notification names the keys of a namespace to invalidate. It is sent as
+// the JSON payload of a NOTIFY, e.g.
+//
+//	SELECT pg_notify('go_cache', json_build_object('namespace', 'users',
+//	    'key', 'user:' || id, 'sent_at', clock_timestamp())::text)
+//
+// A payload that is not a JSON object is the key of the default namespace
+// to invalidate, or a prefix when it ends with *.
+type Notification struct {
+	Namespace string   `json:"namespace,omitempty"`
+	Key       string   `json:"key,omitempty"`
+	Keys      []string `json:"keys,omitempty"`
+	Prefix    string   `json:"prefix,omitempty"`
+	Match     string   `json:"match,omitempty"`
+	Tag       string   `json:"tag,omitempty"`
+	// SentAt is when the change was notified, used to measure the lag
+	SentAt *time.Time `json:"sent_at,omitempty"`
+}
+
+// Resync is what the listener invalidates once reconnected, since the
+// notifications sent while it was disconnected were missed. An empty Prefix
+// flushes the namespace, an empty Namespace is the default one.
+type Resync struct {
+	Namespace string
+	Prefix    string
+}
+
+// ParseResync parses resync entries of the form namespace or
+// namespace:prefix, e.g. "users:user:" invalidates the keys of users starting
+// with user: and ":" flushes the default namespace
+func ParseResync(entries []string) []Resync {
+	var resyncs []Resync
+	for _, entry := range entries {
+		ns, prefix, _ := strings.Cut(entry, ":")
+		resyncs = append(resyncs, Resync{Namespace: ns, Prefix: prefix})
+	}
+	return resyncs
+}
+
+// ListenerStats reports the state of the listener
+type ListenerStats struct {
+	Channels      []string `json:"channels"`
+	Connected     bool     `json:"connected"`
+	Reconnects    uint64   `json:"reconnects"`
+	Received      uint64   `json:"received"`
+	Invalid       uint64   `json:"invalid"`
+	Invalidated   uint64   `json:"invalidated"`
+	LastLagMillis int64    `json:"last_lag_ms"`
+	MaxLagMillis  int64    `json:"max_lag_ms"`
+}
+
+var mu sync.Mutex
+var stats ListenerStats
+var resyncs []Resync
+
+// Setup listens to the channels on the database and invalidates the keys
+// named by their notifications. Every worker listens, so a notification is
+// applied locally only. The connection is re-established with a backoff when
+// it is lost, and resync is invalidated once it is, every namespace when
+// resync is nil.
+func Setup(connStr string, channels []string, resync []Resync) error {
+	listener := pq.NewListener(connStr, MIN_RECONNECT_INTERVAL, MAX_RECONNECT_INTERVAL, event)
+	for _, channel := range channels {
+		if err := listener.Listen(channel); err != nil {
+			listener.Close()
+			return err
+		}
+	}
+
+	mu.Lock()
+	stats.Channels = channels
+	stats.Connected = true
+	resyncs = resync
+	mu.Unlock()
+	log.Logger.Info("listening for invalidations", zap.Strings("channels", channels))
+
+	go run(listener)
+	return nil
+}
+
+// Stats returns the state of the listener
+func Stats() ListenerStats {
+	mu.Lock()
+	defer mu.Unlock()
+	return stats
+}
+
+// event records the state changes of the listener connection
+func event(ev pq.ListenerEventType, err error) {
+	mu.Lock()
+	defer mu.Unlock()
+	switch ev {
+	case pq.ListenerEventConnected:
+		stats.Connected = true
+	case pq.ListenerEventDisconnected:
+		stats.Connected = false
+		log.Logger.Warn("listener disconnected", zap.Error(err))
+	case pq.ListenerEventReconnected:
+		stats.Connected = true
+		stats.Reconnects++
+		log.Logger.Warn("listener reconnected, notifications sent while disconnected were missed")
+	case pq.ListenerEventConnectionAttemptFailed:
+		log.Logger.Warn("listener failed to reconnect", zap.Error(err))
+	}
+}
+
+// run applies the notifications received by the listener
+func run(listener *pq.Listener) {
+	for {
+		select {
+		case n := <-listener.Notify:
+			// a nil notification signals a reconnect
+			if n != nil {
+				handle(n.Channel, n.Extra)
+			} else {
+				resync()
+			}
+		case <-time.After(PING_INTERVAL):
+			go listener.Ping()
+		}
+	}
+}
+
+// handle invalidates the keys named by a notification payload
+func handle(channel string, payload string) {
+	received := time.Now()
+	n, err := parse(payload)
+
+	mu.Lock()
+	stats.Received++
+	if err != nil {
+		stats.Invalid++
+	}
+	if err == nil && n.SentAt != nil {
+		lag := received.Sub(*n.SentAt)
+		stats.LastLagMillis = lag.Milliseconds()
+		stats.MaxLagMillis = max(stats.MaxLagMillis, stats.LastLagMillis)
+		metrics.NotificationLag.Observe(max(lag, 0).Seconds())
+	}
+	mu.Unlock()
+
+	if err != nil {
+		log.Logger.Warn("invalid notification", zap.String("channel", channel), zap.String("payload", payload), zap.Error(err))
+		return
+	}
+
+	deleted, err := apply(n)
+	if err != nil {
+		log.Logger.Warn("failed to apply notification", zap.String("channel", channel), zap.String("payload", payload), zap.Error(err))
+		return
+	}
+
+	mu.Lock()
+	stats.Invalidated += uint64(deleted)
+	mu.Unlock()
+	log.Logger.Debug("notification applied", zap.String("channel", channel), zap.Int("deleted", deleted))
+}
+
+// resync invalidates the keys the notifications missed while the listener
+// was disconnected may have changed
+func resync() {
+	mu.Lock()
+	targets := resyncs
+	mu.Unlock()
+	if targets == nil {
+		for _, s := range cache.Namespaces() {
+			targets = append(targets, Resync{Namespace: s.Name})
+		}
+	}
+
+	deleted := 0
+	for _, target := range targets {
+		ns, err := cache.Namespace(target.Namespace)
+		if err != nil {
+			log.Logger.Warn("failed to resync namespace", zap.String("namespace", target.Namespace), zap.Error(err))
+			continue
+		}
+		if target.Prefix == "" {
+			deleted += ns.Flush()
+			continue
+		}
+		removed, err := ns.Invalidate(cache.Selector{Prefix: target.Prefix})
+		if err != nil {
+			log.Logger.Warn("failed to resync namespace", zap.String("namespace", target.Namespace), zap.String("prefix", target.Prefix), zap.Error(err))
+		}
+		deleted += removed
+	}
+
+	mu.Lock()
+	stats.Invalidated += uint64(deleted)
+	mu.Unlock()
+	log.Logger.Info("listener resynced", zap.Int("deleted", deleted))
+}
+
+// parse decodes a notification payload
+func parse(payload string) (Notification, error) {
+	payload = strings.TrimSpace(payload)
+	if payload == "" {
+		return Notification{}, ErrorInvalidNotification
+	}
+
+	var n Notification
+	if strings.HasPrefix(payload, "{") {
+		if err := json.Unmarshal([]byte(payload), &n); err != nil {
+			return Notification{}, errors.Join(ErrorInvalidNotification, err)
+		}
+	} else if prefix, ok := strings.CutSuffix(payload, "*"); ok {
+		n.Prefix = prefix
+	} else {
+		n.Key = payload
+	}
+
+	if n.Key != "" {
+		n.Keys = append(n.Keys, n.Key)
+		n.Key = ""
+	}
+	selector := cache.Selector{Prefix: n.Prefix, Match: n.Match, Tag: n.Tag}
+	if len(n.Keys) == 0 && selector.IsEmpty() {
+		return Notification{}, ErrorInvalidNotification
+	}
+	return n, nil
+}
+
+// apply deletes the keys of a notification locally and returns the number of
+// keys removed. The other workers receive the same notification, so nothing
+// is replicated to the pool.
+func apply(n Notification) (int, error) {
+	ns, err := cache.Namespace(n.Namespace)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := ns.DeleteKeys(n.Keys)
+	sel := cache.Selector{Prefix: n.Prefix, Match: n.Match, Tag: n.Tag}
+	if sel.IsEmpty() {
+		return deleted, nil
+	}
+	removed, err := ns.Invalidate(sel)
+	return deleted + removed, err
+}
@@ -0,0 +1,122 @@
+package notify
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vishaldc/go-cache/internal/cache"
+	"github.com/vishaldc/go-cache/internal/metrics"
+)
+
+// lagSamples returns the number of lags observed by the histogram
+func lagSamples(t *testing.T) uint64 {
+	t.Helper()
+	families, err := metrics.Registry.Gather()
+	assert.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() == "go_cache_notification_lag_seconds" {
+			return family.GetMetric()[0].GetHistogram().GetSampleCount()
+		}
+	}
+	return 0
+}
+
+func TestParse(t *testing.T) {
+	// Test plain keys and prefixes of the default namespace
+	n, err := parse("user:1")
+	assert.NoError(t, err)
+	assert.Equal(t, Notification{Keys: []string{"user:1"}}, n)
+	n, err = parse("user:*")
+	assert.NoError(t, err)
+	assert.Equal(t, Notification{Prefix: "user:"}, n)
+
+	// Test JSON payloads
+	n, err = parse(`{"namespace": "users", "key": "user:1", "keys": ["user:2"], "tag": "team:1"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, Notification{Namespace: "users", Keys: []string{"user:2", "user:1"}, Tag: "team:1"}, n)
+
+	// Test invalid payloads
+	for _, payload := range []string{"", "  ", `{"namespace": "users"}`, `{"key": 1}`, `{`} {
+		_, err = parse(payload)
+		assert.ErrorIs(t, err, ErrorInvalidNotification, payload)
+	}
+}
+
+func TestHandle(t *testing.T) {
+	ns, err := cache.CreateNamespace("notify")
+	assert.NoError(t, err)
+	for _, key := range []string{"user:1", "user:2", "user:3", "order:1"} {
+		assert.NoError(t, ns.Set(key, map[string]any{"a": float64(1)}))
+	}
+	before := Stats()
+	samples := lagSamples(t)
+
+	// Test keys and selectors are invalidated
+	handle("go_cache", `{"namespace": "notify", "key": "user:1"}`)
+	_, err = ns.Get("user:1")
+	assert.Equal(t, cache.ErrorKeyNotFound, err)
+	handle("go_cache", `{"namespace": "notify", "prefix": "user:"}`)
+	assert.Equal(t, []string{"order:1"}, ns.Keys(""))
+
+	// Test invalid payloads are counted and ignored
+	handle("go_cache", `{"namespace": "not a namespace!", "key": "order:1"}`)
+	handle("go_cache", `{}`)
+	assert.Equal(t, []string{"order:1"}, ns.Keys(""))
+
+	// Test the lag is measured from the sent time
+	sentAt := time.Now().Add(-2 * time.Second).Format(time.RFC3339Nano)
+	handle("go_cache", fmt.Sprintf(`{"namespace": "notify", "key": "order:1", "sent_at": %q}`, sentAt))
+
+	stats := Stats()
+	assert.Equal(t, before.Received+5, stats.Received)
+	assert.Equal(t, before.Invalid+1, stats.Invalid)
+	assert.Equal(t, before.Invalidated+4, stats.Invalidated)
+	assert.InDelta(t, 2000, stats.LastLagMillis, 500)
+	assert.GreaterOrEqual(t, stats.MaxLagMillis, stats.LastLagMillis)
+	assert.Equal(t, samples+1, lagSamples(t))
+}
+
+func TestParseResync(t *testing.T) {
+	assert.Nil(t, ParseResync(nil))
+	assert.Equal(t, []Resync{{Namespace: "users"}, {Namespace: "users", Prefix: "user:"}, {}}, ParseResync([]string{"users", "users:user:", ":"}))
+}
+
+func TestResync(t *testing.T) {
+	ns, err := cache.CreateNamespace("resync")
+	assert.NoError(t, err)
+	other, err := cache.CreateNamespace("resyncOther")
+	assert.NoError(t, err)
+	set := func() {
+		for _, key := range []string{"user:1", "order:1"} {
+			assert.NoError(t, ns.Set(key, map[string]any{"a": float64(1)}))
+			assert.NoError(t, other.Set(key, map[string]any{"a": float64(1)}))
+		}
+	}
+	t.Cleanup(func() {
+		mu.Lock()
+		resyncs = nil
+		mu.Unlock()
+	})
+
+	// Test a reconnect invalidates the configured namespaces and prefixes
+	set()
+	mu.Lock()
+	resyncs = ParseResync([]string{"resync:user:", "resyncOther"})
+	mu.Unlock()
+	before := Stats()
+	resync()
+	assert.Equal(t, []string{"order:1"}, ns.Keys(""))
+	assert.Empty(t, other.Keys(""))
+	assert.Equal(t, before.Invalidated+3, Stats().Invalidated)
+
+	// Test every namespace is flushed when none is configured
+	set()
+	mu.Lock()
+	resyncs = nil
+	mu.Unlock()
+	resync()
+	assert.Empty(t, ns.Keys(""))
+	assert.Empty(t, other.Keys(""))
+}
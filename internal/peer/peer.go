@@ -0,0 +1,239 @@
+package peer
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/vishaldc/go-cache/internal/apierror"
+	"github.com/vishaldc/go-cache/internal/cache"
+	"github.com/vishaldc/go-cache/internal/log"
+	"go.uber.org/zap"
+)
+
+const (
+	// SIGNATURE_HEADER carries the HMAC-SHA256 of a signed sync request
+	SIGNATURE_HEADER = "X-Sync-Signature"
+
+	// TIMESTAMP_HEADER carries the unix time a sync request was signed at
+	TIMESTAMP_HEADER = "X-Sync-Timestamp"
+
+	// NONCE_HEADER carries the random value making a signature unique
+	NONCE_HEADER = "X-Sync-Nonce"
+
+	// MAX_CLOCK_SKEW is how far the timestamp of a signed request may be from
+	// the clock of the worker, the nonces are remembered for twice as long
+	MAX_CLOCK_SKEW = 30 * time.Second
+)
+
+var (
+	ErrorInvalidPeerConfig = errors.New("invalid peer configuration")
+	ErrorUnsigned          = errors.New("sync request is not signed")
+	ErrorExpiredSignature  = errors.New("sync request signature expired")
+	ErrorReplayed          = errors.New("sync request replayed")
+	ErrorInvalidSignature  = errors.New("invalid sync request signature")
+)
+
+// Config authenticates the workers of the pool to each other, with mutual
+// TLS when the CA, certificate and key are set and with HMAC signatures
+// when the secret is set. Both can be combined.
+type Config struct {
+	CAFile   string
+	CertFile string
+	KeyFile  string
+	Secret   string
+}
+
+var mu sync.RWMutex
+var secret []byte
+var clientTLS *tls.Config
+var serverTLS *tls.Config
+
+// Setup configures the authentication of the sync requests sent to and
+// received from the pool
+func Setup(config Config) error {
+	var client, server *tls.Config
+	if config.CAFile != "" || config.CertFile != "" || config.KeyFile != "" {
+		if config.CAFile == "" || config.CertFile == "" || config.KeyFile == "" {
+			return fmt.Errorf("%w: mutual TLS needs a CA, a certificate and a key", ErrorInvalidPeerConfig)
+		}
+		ca, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return fmt.Errorf("%w: no certificate in %s", ErrorInvalidPeerConfig, config.CAFile)
+		}
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return errors.Join(ErrorInvalidPeerConfig, err)
+		}
+		// the same certificate identifies the worker as a client and a server
+		client = &tls.Config{RootCAs: pool, Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+		server = &tls.Config{ClientCAs: pool, Certificates: []tls.Certificate{cert}, ClientAuth: tls.RequireAndVerifyClientCert, MinVersion: tls.VersionTLS12}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	clientTLS, serverTLS = client, server
+	secret = []byte(config.Secret)
+	log.Logger.Info("peer authentication configured", zap.Bool("mtls", server != nil), zap.Bool("hmac", len(secret) > 0))
+	return nil
+}
+
+// Client returns the client sending sync requests to the pool
+func Client(timeout time.Duration) *http.Client {
+	mu.RLock()
+	defer mu.RUnlock()
+	client := &http.Client{Timeout: timeout}
+	if clientTLS != nil {
+		client.Transport = &http.Transport{TLSClientConfig: clientTLS}
+	}
+	return client
+}
+
+// Scheme returns the scheme of the sync server of the peers
+func Scheme() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	if clientTLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// ServerTLSConfig returns the TLS configuration of the sync server, nil when
+// it serves plain HTTP
+func ServerTLSConfig() *tls.Config {
+	mu.RLock()
+	defer mu.RUnlock()
+	return serverTLS
+}
+
+// Sign adds the signature of a sync request with its body when a secret is
+// configured
+func Sign(req *http.Request, body []byte) {
+	mu.RLock()
+	key := secret
+	mu.RUnlock()
+	if len(key) == 0 {
+		return
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	b := make([]byte, 16)
+	rand.Read(b)
+	nonce := hex.EncodeToString(b)
+	req.Header.Set(TIMESTAMP_HEADER, timestamp)
+	req.Header.Set(NONCE_HEADER, nonce)
+	req.Header.Set(SIGNATURE_HEADER, signature(key, req.Method, req.URL.RequestURI(), timestamp, nonce, body))
+}
+
+// signature returns the hex HMAC-SHA256 of a request
+func signature(key []byte, method, uri, timestamp, nonce string, body []byte) string {
+	digest := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, key)
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s\n%x", method, uri, timestamp, nonce, digest)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// nonces remembers the nonces of the signatures accepted recently so a
+// captured request can not be replayed
+var noncesMu sync.Mutex
+var nonces = make(map[string]time.Time)
+var pruned time.Time
+
+// remember records a nonce, returning false when it was already used
+func remember(nonce string, now time.Time) bool {
+	noncesMu.Lock()
+	defer noncesMu.Unlock()
+	if now.Sub(pruned) > time.Second {
+		for n, seen := range nonces {
+			if now.Sub(seen) > 2*MAX_CLOCK_SKEW {
+				delete(nonces, n)
+			}
+		}
+		pruned = now
+	}
+	if _, ok := nonces[nonce]; ok {
+		return false
+	}
+	nonces[nonce] = now
+	return true
+}
+
+// Verify checks the signature of a sync request, the body is read and
+// restored so handlers can still decode it. The body is bounded by the size
+// of the largest write a peer can replicate, a larger body fails with a
+// *http.MaxBytesError before the signature is checked.
+func Verify(r *http.Request) error {
+	r.Body = http.MaxBytesReader(nil, r.Body, cache.CurrentLimits().MaxSyncSize())
+
+	mu.RLock()
+	key := secret
+	mu.RUnlock()
+	if len(key) == 0 {
+		return nil
+	}
+
+	timestamp, nonce, sig := r.Header.Get(TIMESTAMP_HEADER), r.Header.Get(NONCE_HEADER), r.Header.Get(SIGNATURE_HEADER)
+	if timestamp == "" || nonce == "" || sig == "" {
+		return ErrorUnsigned
+	}
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrorInvalidSignature
+	}
+	now := time.Now()
+	if skew := now.Sub(time.Unix(seconds, 0)); skew > MAX_CLOCK_SKEW || skew < -MAX_CLOCK_SKEW {
+		return ErrorExpiredSignature
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	expected := signature(key, r.Method, r.URL.RequestURI(), timestamp, nonce, body)
+	if !hmac.Equal([]byte(sig), []byte(expected)) {
+		return ErrorInvalidSignature
+	}
+	// the nonce is only remembered once the signature is known to be valid
+	if !remember(nonce, now) {
+		return ErrorReplayed
+	}
+	return nil
+}
+
+// Middleware rejects the sync requests that are not signed by a worker of
+// the pool with a 401, and the ones over the size limit with a 413
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := Verify(r)
+		var maxBytes *http.MaxBytesError
+		if errors.As(err, &maxBytes) {
+			log.Logger.Warn("sync request too large", zap.String("remote", r.RemoteAddr), zap.String("path", r.URL.Path), zap.Int64("limit", maxBytes.Limit))
+			apierror.Write(w, r, http.StatusRequestEntityTooLarge, apierror.CodeBodyTooLarge, "request body too large", "")
+			return
+		}
+		if err != nil {
+			log.Logger.Warn("rejected sync request", zap.String("remote", r.RemoteAddr), zap.String("path", r.URL.Path), zap.Error(err))
+			apierror.Write(w, r, http.StatusUnauthorized, apierror.CodeUnauthenticated, err.Error(), "")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
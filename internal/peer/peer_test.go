@@ -0,0 +1,162 @@
+package peer
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vishaldc/go-cache/internal/cache"
+)
+
+// signedRequest returns a sync request signed with the configured secret
+func signedRequest(t *testing.T, body string) *http.Request {
+	t.Helper()
+	req := httptest.NewRequest("POST", "/cache/sync?key=a", bytes.NewReader([]byte(body)))
+	Sign(req, []byte(body))
+	return req
+}
+
+func TestVerify(t *testing.T) {
+	assert.NoError(t, Setup(Config{Secret: "secret"}))
+	t.Cleanup(func() { Setup(Config{}) })
+
+	// Test a signed request is accepted once and its body is still readable
+	req := signedRequest(t, `{"a":1}`)
+	assert.NoError(t, Verify(req))
+	body := new(bytes.Buffer)
+	body.ReadFrom(req.Body)
+	assert.Equal(t, `{"a":1}`, body.String())
+
+	replayed := httptest.NewRequest("POST", "/cache/sync?key=a", bytes.NewReader([]byte(`{"a":1}`)))
+	replayed.Header = req.Header
+	assert.Equal(t, ErrorReplayed, Verify(replayed))
+
+	// Test unsigned, tampered and expired requests are rejected
+	assert.Equal(t, ErrorUnsigned, Verify(httptest.NewRequest("POST", "/cache/sync?key=a", nil)))
+
+	req = signedRequest(t, `{"a":1}`)
+	tampered := httptest.NewRequest("POST", "/cache/sync?key=b", bytes.NewReader([]byte(`{"a":1}`)))
+	tampered.Header = req.Header
+	assert.Equal(t, ErrorInvalidSignature, Verify(tampered))
+
+	req = signedRequest(t, `{"a":1}`)
+	req.Header.Set(TIMESTAMP_HEADER, strconv.FormatInt(time.Now().Add(-2*MAX_CLOCK_SKEW).Unix(), 10))
+	assert.Equal(t, ErrorExpiredSignature, Verify(req))
+
+	// Test the middleware answers a 401
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("DELETE", "/cache/sync?key=a", nil))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, signedRequest(t, ""))
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	// Test a body over the limit is rejected before it is read whole
+	cache.SetLimits(cache.Limits{MaxKeyLength: 8, MaxValueSize: 64, MaxBatchSize: 1})
+	t.Cleanup(func() { cache.SetLimits(cache.Limits{}) })
+	var maxBytes *http.MaxBytesError
+	assert.ErrorAs(t, Verify(signedRequest(t, strings.Repeat("x", 1024))), &maxBytes)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, signedRequest(t, strings.Repeat("x", 1024)))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+}
+
+func TestVerifyDisabled(t *testing.T) {
+	assert.NoError(t, Setup(Config{}))
+
+	// Test requests are not checked without a secret
+	assert.NoError(t, Verify(httptest.NewRequest("POST", "/cache/sync?key=a", nil)))
+	assert.Equal(t, "http", Scheme())
+	assert.Nil(t, ServerTLSConfig())
+}
+
+// writePEM writes a PEM block to a file of dir
+func writePEM(t *testing.T, dir, name, kind string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	assert.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: kind, Bytes: der}), 0o600))
+	return path
+}
+
+// issue creates a certificate for localhost signed by the parent, or self
+// signed when parent is nil
+func issue(t *testing.T, parent *x509.Certificate, parentKey *ecdsa.PrivateKey, ca bool) (*x509.Certificate, *ecdsa.PrivateKey, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: "go-cache"},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  ca,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	assert.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	return cert, key, der
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey, caDER := issue(t, nil, nil, true)
+	_, key, der := issue(t, ca, caKey, false)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+	config := Config{
+		CAFile:   writePEM(t, dir, "ca.pem", "CERTIFICATE", caDER),
+		CertFile: writePEM(t, dir, "cert.pem", "CERTIFICATE", der),
+		KeyFile:  writePEM(t, dir, "key.pem", "EC PRIVATE KEY", keyDER),
+	}
+	assert.NoError(t, Setup(config))
+	t.Cleanup(func() { Setup(Config{}) })
+	assert.Equal(t, "https", Scheme())
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	server.TLS = ServerTLSConfig()
+	server.StartTLS()
+	defer server.Close()
+
+	// Test a peer presenting a certificate of the CA is served
+	resp, err := Client(time.Second).Post(server.URL+"/cache/sync", "application/json", nil)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	// Test a client without a certificate is refused
+	anonymous := server.Client()
+	_, err = anonymous.Post(server.URL+"/cache/sync", "application/json", nil)
+	assert.Error(t, err)
+
+	// Test an incomplete configuration is rejected
+	assert.ErrorIs(t, Setup(Config{CAFile: config.CAFile}), ErrorInvalidPeerConfig)
+}
@@ -0,0 +1,299 @@
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vishaldc/go-cache/internal/apierror"
+	"github.com/vishaldc/go-cache/internal/auth"
+	"github.com/vishaldc/go-cache/internal/log"
+	"github.com/vishaldc/go-cache/internal/registry"
+	"go.uber.org/zap"
+)
+
+const (
+	// SHARE_INTERVAL is how often the tokens taken from the cluster wide
+	// buckets are sent to the pool
+	SHARE_INTERVAL = 1 * time.Second
+
+	// IDLE_PERIOD is how long a full bucket is kept after its last request
+	IDLE_PERIOD = 10 * time.Minute
+)
+
+var ErrorInvalidLimit = errors.New("invalid rate limit")
+
+// Dimension is what a rule counts requests by
+type Dimension string
+
+const (
+	// ByKey counts the requests of a principal authenticated by an API key
+	// or a token, requests without valid credentials are not counted
+	ByKey Dimension = "key"
+	// ByIP counts the requests of a client address
+	ByIP Dimension = "ip"
+	// ByNamespace counts the requests to a namespace
+	ByNamespace Dimension = "namespace"
+)
+
+// Rule is a token bucket refilled with Rate tokens per second up to Burst
+// for every principal, client address or namespace. A rule naming one of
+// them replaces the rule of its dimension without a name. Cluster rules
+// share their buckets across the pool so spreading the requests over the
+// workers does not multiply the allowance.
+type Rule struct {
+	By      Dimension `json:"by"`
+	Name    string    `json:"name,omitempty"`
+	Rate    float64   `json:"rate"`
+	Burst   float64   `json:"burst,omitempty"`
+	Cluster bool      `json:"cluster,omitempty"`
+}
+
+// Config is the content of the rate limits file
+type Config struct {
+	Rules []Rule `json:"rules"`
+	// TrustForwardedFor takes the client address from the X-Forwarded-For
+	// header set by a load balancer in front of the workers
+	TrustForwardedFor bool `json:"trust_forwarded_for,omitempty"`
+}
+
+// bucket holds the tokens of an identity under a rule
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// refill adds the tokens earned since the bucket was last refilled
+func (b *bucket) refill(rule Rule, now time.Time) {
+	b.tokens = math.Min(rule.Burst, b.tokens+now.Sub(b.last).Seconds()*rule.Rate)
+	b.last = now
+}
+
+var mu sync.Mutex
+var config Config
+var buckets = make(map[string]*bucket)
+
+// taken are the tokens taken from the cluster buckets since they were last
+// shared with the pool
+var taken = make(map[string]float64)
+
+// Setup limits the requests with the rules of a JSON file such as
+//
+//	{"rules": [{"by": "key", "rate": 100, "burst": 200, "cluster": true},
+//	    {"by": "ip", "rate": 20}, {"by": "namespace", "name": "sessions", "rate": 1000}]}
+//
+// and starts sharing the cluster buckets with the pool
+func Setup(ctx context.Context, path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var c Config
+	if err := json.Unmarshal(b, &c); err != nil {
+		return errors.Join(ErrorInvalidLimit, err)
+	}
+	if err := configure(c); err != nil {
+		return err
+	}
+	log.Logger.Info("rate limits configured", zap.Int("rules", len(c.Rules)))
+	go share(ctx)
+	return nil
+}
+
+// configure validates and applies the rules, forgetting the buckets
+func configure(c Config) error {
+	for i := range c.Rules {
+		rule := &c.Rules[i]
+		if rule.By != ByKey && rule.By != ByIP && rule.By != ByNamespace {
+			return fmt.Errorf("%w: unknown dimension %q", ErrorInvalidLimit, rule.By)
+		}
+		if rule.Rate <= 0 {
+			return fmt.Errorf("%w: rate must be positive", ErrorInvalidLimit)
+		}
+		// a bucket holds at least a second of requests
+		if rule.Burst < 1 {
+			rule.Burst = math.Max(1, rule.Rate)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	config = c
+	buckets = make(map[string]*bucket)
+	taken = make(map[string]float64)
+	return nil
+}
+
+// rules returns the rule of every dimension applying to the identities of a
+// request with the name of its bucket
+func rules(identities map[Dimension]string) map[string]Rule {
+	applied := make(map[string]Rule)
+	chosen := make(map[Dimension]Rule)
+	for _, rule := range config.Rules {
+		identity, ok := identities[rule.By]
+		if !ok || (rule.Name != "" && rule.Name != identity) {
+			continue
+		}
+		if current, ok := chosen[rule.By]; ok && (current.Name != "" || rule.Name == "") {
+			continue
+		}
+		chosen[rule.By] = rule
+	}
+	for by, rule := range chosen {
+		applied[string(by)+":"+identities[by]] = rule
+	}
+	return applied
+}
+
+// Allow takes a token from the bucket of every rule applying to the
+// identities of a request, returning how long to wait before retrying when
+// one of them is empty
+func Allow(identities map[Dimension]string) (time.Duration, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	now := time.Now()
+
+	applied := rules(identities)
+	// every bucket is checked before any token is taken so a request
+	// refused by one rule does not consume the others
+	var wait time.Duration
+	for name, rule := range applied {
+		b, ok := buckets[name]
+		if !ok {
+			b = &bucket{tokens: rule.Burst, last: now}
+			buckets[name] = b
+		}
+		b.refill(rule, now)
+		if b.tokens < 1 {
+			wait = max(wait, time.Duration((1-b.tokens)/rule.Rate*float64(time.Second)))
+		}
+	}
+	if wait > 0 {
+		return wait, false
+	}
+	for name, rule := range applied {
+		buckets[name].tokens--
+		if rule.Cluster {
+			taken[name]++
+		}
+	}
+	return 0, true
+}
+
+// Consume takes the tokens taken by a peer from the cluster buckets
+func Consume(usage map[string]float64) {
+	mu.Lock()
+	defer mu.Unlock()
+	now := time.Now()
+	for name, tokens := range usage {
+		by, identity, _ := strings.Cut(name, ":")
+		rule, ok := rules(map[Dimension]string{Dimension(by): identity})[name]
+		if !ok || !rule.Cluster {
+			continue
+		}
+		b, ok := buckets[name]
+		if !ok {
+			b = &bucket{tokens: rule.Burst, last: now}
+			buckets[name] = b
+		}
+		b.refill(rule, now)
+		b.tokens = math.Max(0, b.tokens-tokens)
+	}
+}
+
+// share sends the tokens taken from the cluster buckets to the pool and
+// forgets the idle buckets until ctx is done
+func share(ctx context.Context) {
+	ticker := time.NewTicker(SHARE_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		mu.Lock()
+		usage := taken
+		taken = make(map[string]float64)
+		now := time.Now()
+		for name, b := range buckets {
+			if now.Sub(b.last) > IDLE_PERIOD {
+				delete(buckets, name)
+			}
+		}
+		mu.Unlock()
+
+		reg := registry.GetRegistry()
+		if len(usage) == 0 || len(reg.GetPool()) == 0 {
+			continue
+		}
+		if err := reg.ShareRateLimitsInPool(ctx, usage); err != nil {
+			log.Logger.Error("failed to write to pool", zap.Error(err))
+		}
+	}
+}
+
+// identify returns the identities of a request, its key being the principal
+// recorded by auth.Middleware ahead of the limits
+func identify(r *http.Request, trustForwardedFor bool) map[Dimension]string {
+	identities := make(map[Dimension]string)
+	if name, ok := auth.Identify(r); ok {
+		identities[ByKey] = name
+	}
+
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	if trustForwardedFor {
+		if forwarded, _, _ := strings.Cut(r.Header.Get("X-Forwarded-For"), ","); strings.TrimSpace(forwarded) != "" {
+			ip = strings.TrimSpace(forwarded)
+		}
+	}
+	identities[ByIP] = ip
+
+	// the mux has not routed the request yet, the namespace is read from
+	// the path the same way
+	ns := r.URL.Query().Get("ns")
+	if rest, ok := strings.CutPrefix(r.URL.Path, "/ns/"); ok {
+		ns, _, _ = strings.Cut(rest, "/")
+	}
+	identities[ByNamespace] = ns
+	return identities
+}
+
+// exempt are the paths never limited, so probes keep working under load
+var exempt = map[string]bool{"/healthz": true, "/readyz": true}
+
+// Middleware answers 429 with a Retry-After header to the requests exceeding
+// a rate limit
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		enabled, trustForwardedFor := len(config.Rules) > 0, config.TrustForwardedFor
+		mu.Unlock()
+		if !enabled || exempt[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		identities := identify(r, trustForwardedFor)
+		if wait, ok := Allow(identities); !ok {
+			log.Logger.Warn("rate limit exceeded", zap.String("path", r.URL.Path), zap.Any("identities", identities))
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			apierror.Write(w, r, http.StatusTooManyRequests, apierror.CodeRateLimited, "rate limit exceeded", "")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
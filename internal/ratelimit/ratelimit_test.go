@@ -0,0 +1,97 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vishaldc/go-cache/internal/auth"
+)
+
+// request serves a request from addr through the middleware
+func request(handler http.Handler, target, addr string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", target, nil)
+	req.RemoteAddr = addr
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestMiddleware(t *testing.T) {
+	assert.NoError(t, configure(Config{Rules: []Rule{
+		{By: ByIP, Rate: 0.5, Burst: 2},
+		{By: ByNamespace, Name: "hot", Rate: 0.5},
+	}}))
+	t.Cleanup(func() { configure(Config{}) })
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	// Test a client is refused once its burst is spent with a Retry-After
+	assert.Equal(t, http.StatusNoContent, request(handler, "/cache?key=a", "10.0.0.1:1234").Code)
+	assert.Equal(t, http.StatusNoContent, request(handler, "/cache?key=a", "10.0.0.1:1235").Code)
+	rr := request(handler, "/cache?key=a", "10.0.0.1:1236")
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, "2", rr.Header().Get("Retry-After"))
+
+	// Test other clients and the probes are not affected
+	assert.Equal(t, http.StatusNoContent, request(handler, "/cache?key=a", "10.0.0.2:1234").Code)
+	assert.Equal(t, http.StatusNoContent, request(handler, "/healthz", "10.0.0.1:1234").Code)
+
+	// Test a named namespace has its own limit, checked with the path
+	assert.Equal(t, http.StatusNoContent, request(handler, "/ns/hot/cache?key=a", "10.0.0.3:1234").Code)
+	assert.Equal(t, http.StatusTooManyRequests, request(handler, "/ns/hot/cache?key=a", "10.0.0.4:1234").Code)
+	// the refused request took no token from the client bucket
+	assert.Equal(t, http.StatusNoContent, request(handler, "/cache?key=a", "10.0.0.4:1234").Code)
+}
+
+func TestAllowByKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "auth.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"api_keys": [{"name": "ci", "key": "k"}]}`), 0o600))
+	assert.NoError(t, auth.Setup(path))
+	t.Cleanup(auth.Disable)
+	assert.NoError(t, configure(Config{Rules: []Rule{{By: ByKey, Rate: 1}}}))
+	t.Cleanup(func() { configure(Config{}) })
+
+	req := httptest.NewRequest("GET", "/cache?key=a", nil)
+	req.Header.Set(auth.API_KEY_HEADER, "k")
+	// the principal is recorded by the auth middleware ahead of the limits
+	auth.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = r
+	})).ServeHTTP(httptest.NewRecorder(), req)
+
+	// Test the requests of a key are counted together whatever their address
+	_, ok := Allow(identify(req, false))
+	assert.True(t, ok)
+	req.RemoteAddr = "10.0.0.9:1234"
+	_, ok = Allow(identify(req, false))
+	assert.False(t, ok)
+
+	// Test anonymous requests are not counted by key
+	_, ok = Allow(identify(httptest.NewRequest("GET", "/cache?key=a", nil), false))
+	assert.True(t, ok)
+}
+
+func TestConsume(t *testing.T) {
+	assert.NoError(t, configure(Config{Rules: []Rule{{By: ByIP, Rate: 1, Burst: 5, Cluster: true}}}))
+	t.Cleanup(func() { configure(Config{}) })
+	identities := map[Dimension]string{ByIP: "10.0.0.1"}
+
+	// Test the tokens taken on this worker are recorded to be shared
+	_, ok := Allow(identities)
+	assert.True(t, ok)
+	assert.Equal(t, map[string]float64{"ip:10.0.0.1": 1}, taken)
+
+	// Test the tokens taken by a peer empty the local bucket
+	Consume(map[string]float64{"ip:10.0.0.1": 4, "ip:unknown:rule": 1})
+	_, ok = Allow(identities)
+	assert.False(t, ok)
+}
+
+func TestConfigureInvalid(t *testing.T) {
+	assert.ErrorIs(t, configure(Config{Rules: []Rule{{By: "user", Rate: 1}}}), ErrorInvalidLimit)
+	assert.ErrorIs(t, configure(Config{Rules: []Rule{{By: ByIP}}}), ErrorInvalidLimit)
+}
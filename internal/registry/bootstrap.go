@@ -0,0 +1,73 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/vishaldc/go-cache/internal/cache"
+	"github.com/vishaldc/go-cache/internal/log"
+	"github.com/vishaldc/go-cache/internal/peer"
+	"go.uber.org/zap"
+)
+
+// Bootstrap copies the namespaces and secondary indexes of the cluster from
+// the first worker of the pool that answers. Namespace configurations and
+// index declarations are only replicated when they change, so a worker
+// joining the cluster would not know the ones made before it started.
+func (r *defaultRegistry) Bootstrap(ctx context.Context) error {
+	if err := r.RefreshPool(); err != nil {
+		return err
+	}
+	return r.bootstrap(ctx)
+}
+
+func (r *defaultRegistry) bootstrap(ctx context.Context) error {
+	pool := r.GetPool()
+	if len(pool) == 0 {
+		log.Logger.Info("no workers to bootstrap from")
+		return nil
+	}
+
+	var errs []error
+	for _, w := range pool {
+		schemas, err := r.fetchSchemas(ctx, w)
+		if err != nil {
+			log.Logger.Warn("failed to fetch namespaces", zap.String("worker", w.Hostname), zap.Error(err))
+			errs = append(errs, err)
+			continue
+		}
+		if err := cache.ApplySchemas(schemas); err != nil {
+			return err
+		}
+		log.Logger.Info("bootstrapped namespaces", zap.String("worker", w.Hostname), zap.Int("namespaces", len(schemas)))
+		return nil
+	}
+	return errors.Join(errs...)
+}
+
+// fetchSchemas returns the namespaces and indexes of a worker of the pool
+func (r *defaultRegistry) fetchSchemas(ctx context.Context, w Worker) ([]cache.NamespaceSchema, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s://%s/cache/sync/ns", peer.Scheme(), w.Hostname), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	peer.Sign(req, nil)
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("worker responded with status %d", resp.StatusCode)
+	}
+
+	var schemas []cache.NamespaceSchema
+	if err := json.NewDecoder(resp.Body).Decode(&schemas); err != nil {
+		return nil, err
+	}
+	return schemas, nil
+}
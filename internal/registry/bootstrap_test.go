@@ -0,0 +1,44 @@
+package registry
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vishaldc/go-cache/internal/cache"
+)
+
+func TestBootstrap(t *testing.T) {
+	var requests []string
+	reg := &defaultRegistry{
+		pool: map[string]Worker{
+			"localhost:8081": {ID: 1, Hostname: "localhost:8081"},
+			"localhost:8083": {ID: 2, Hostname: "localhost:8083"},
+		},
+		client: &http.Client{
+			Transport: &MockRoundTripper{
+				roundTripFunc: func(req *http.Request) *http.Response {
+					requests = append(requests, req.Method+" "+req.URL.Host+req.URL.Path)
+					// the first worker is unavailable
+					if req.URL.Host == "localhost:8081" {
+						return &http.Response{StatusCode: http.StatusServiceUnavailable, Header: make(http.Header), Body: io.NopCloser(strings.NewReader(""))}
+					}
+					body := `[{"name": "bootstrapped", "config": {"max_keys": 5}, "indexes": [{"name": "byUser", "field": "user"}]}]`
+					return &http.Response{StatusCode: http.StatusOK, Header: make(http.Header), Body: io.NopCloser(strings.NewReader(body))}
+				},
+			},
+		},
+	}
+
+	assert.NoError(t, reg.bootstrap(context.Background()))
+
+	// Check the next worker is asked when one fails and the schema is applied
+	assert.Equal(t, []string{"GET localhost:8081/cache/sync/ns", "GET localhost:8083/cache/sync/ns"}, requests)
+	ns, err := cache.Namespace("bootstrapped")
+	assert.NoError(t, err)
+	assert.Equal(t, cache.NamespaceConfig{MaxKeys: 5}, ns.Config())
+	assert.Equal(t, []cache.IndexInfo{{Name: "byUser", Field: "user"}}, ns.Indexes())
+}
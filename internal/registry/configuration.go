@@ -2,6 +2,8 @@ package registry
 
 import (
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/vishaldc/go-cache/internal/log"
 	"go.uber.org/zap"
@@ -16,6 +18,53 @@ type Configuration struct {
 	ServerPort string
 	SyncPort   string
 	Hostname   string
+	// AdminPort serves the metrics when set
+	AdminPort string
+	// SyncCAFile, SyncCertFile and SyncKeyFile authenticate the workers of
+	// the pool to each other with mutual TLS when set
+	SyncCAFile   string
+	SyncCertFile string
+	SyncKeyFile  string
+	// SyncSecret signs the sync requests when set
+	SyncSecret string
+	// TLSCertFile and TLSKeyFile serve the client port over TLS when set,
+	// reloaded when they change on disk
+	TLSCertFile string
+	TLSKeyFile  string
+	// TLSMinVersion is 1.2 or 1.3
+	TLSMinVersion string
+	// TLSCipherSuites are the TLS 1.2 cipher suites allowed
+	TLSCipherSuites []string
+	// TLSClientCAFile verifies the client certificates when set, required
+	// unless TLSClientAuth is request
+	TLSClientCAFile string
+	TLSClientAuth   string
+	// NamespacesFile is an optional JSON file with the limits of each namespace
+	NamespacesFile string
+	// LoadersFile is an optional JSON file with the read-through loaders
+	LoadersFile string
+	// StoresFile is an optional JSON file with the tables namespaces are
+	// written through or behind to
+	StoresFile string
+	// RateLimitsFile is an optional JSON file with the rate limits of the
+	// client requests
+	RateLimitsFile string
+	// AuthFile is an optional JSON file with the API keys and JWT settings
+	// the client requests are authenticated with
+	AuthFile string
+	// NotifyChannels are the Postgres channels listened to for invalidations
+	NotifyChannels []string
+	// NotifyResync are the namespaces, or namespace:prefix, invalidated when
+	// the listener reconnects, every namespace when not set
+	NotifyResync []string
+	// MaxKeyLength, MaxValueSize and MaxBatchSize bound the writes accepted
+	// by the worker, zero uses the defaults of the cache
+	MaxKeyLength int
+	MaxValueSize int
+	MaxBatchSize int
+	// OTLPEndpoint is the collector the spans are exported to, tracing is
+	// only propagated when it is not set
+	OTLPEndpoint string
 }
 
 // LoadConfiguration loads environment variables into the Configuration struct
@@ -29,6 +78,49 @@ func LoadConfiguration() *Configuration {
 		ServerPort: os.Getenv("SERVER_PORT"),
 		SyncPort:   os.Getenv("SYNC_PORT"),
 		Hostname:   os.Getenv("HOSTNAME"),
+		AdminPort:  os.Getenv("ADMIN_PORT"),
+
+		SyncCAFile:   os.Getenv("SYNC_TLS_CA"),
+		SyncCertFile: os.Getenv("SYNC_TLS_CERT"),
+		SyncKeyFile:  os.Getenv("SYNC_TLS_KEY"),
+		SyncSecret:   os.Getenv("SYNC_SECRET"),
+
+		TLSCertFile:     os.Getenv("TLS_CERT_FILE"),
+		TLSKeyFile:      os.Getenv("TLS_KEY_FILE"),
+		TLSMinVersion:   os.Getenv("TLS_MIN_VERSION"),
+		TLSClientCAFile: os.Getenv("TLS_CLIENT_CA_FILE"),
+		TLSClientAuth:   os.Getenv("TLS_CLIENT_AUTH"),
+
+		NamespacesFile: os.Getenv("NAMESPACES_FILE"),
+		LoadersFile:    os.Getenv("LOADERS_FILE"),
+		StoresFile:     os.Getenv("STORES_FILE"),
+		AuthFile:       os.Getenv("AUTH_FILE"),
+		RateLimitsFile: os.Getenv("RATE_LIMITS_FILE"),
+		OTLPEndpoint:   os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"),
+	}
+	for _, suite := range strings.Split(os.Getenv("TLS_CIPHER_SUITES"), ",") {
+		if suite = strings.TrimSpace(suite); suite != "" {
+			config.TLSCipherSuites = append(config.TLSCipherSuites, suite)
+		}
+	}
+	for _, channel := range strings.Split(os.Getenv("NOTIFY_CHANNELS"), ",") {
+		if channel = strings.TrimSpace(channel); channel != "" {
+			config.NotifyChannels = append(config.NotifyChannels, channel)
+		}
+	}
+	for _, entry := range strings.Split(os.Getenv("NOTIFY_RESYNC"), ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			config.NotifyResync = append(config.NotifyResync, entry)
+		}
+	}
+	for name, limit := range map[string]*int{"MAX_KEY_LENGTH": &config.MaxKeyLength, "MAX_VALUE_SIZE": &config.MaxValueSize, "MAX_BATCH_SIZE": &config.MaxBatchSize} {
+		if s := os.Getenv(name); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 0 {
+				log.Logger.Fatal("invalid "+name+" environment variable", zap.String("value", s))
+			}
+			*limit = n
+		}
 	}
 
 	// Validate required environment variables
@@ -71,6 +163,28 @@ func LoadConfiguration() *Configuration {
 		zap.String("SERVER_PORT", config.ServerPort),
 		zap.String("SYNC_PORT", config.SyncPort),
 		zap.String("HOSTNAME", config.Hostname),
+		zap.String("ADMIN_PORT", config.AdminPort),
+		zap.String("SYNC_TLS_CA", config.SyncCAFile),
+		zap.String("SYNC_TLS_CERT", config.SyncCertFile),
+		zap.String("SYNC_TLS_KEY", config.SyncKeyFile),
+		zap.Bool("SYNC_SECRET", config.SyncSecret != ""),
+		zap.String("TLS_CERT_FILE", config.TLSCertFile),
+		zap.String("TLS_KEY_FILE", config.TLSKeyFile),
+		zap.String("TLS_MIN_VERSION", config.TLSMinVersion),
+		zap.Strings("TLS_CIPHER_SUITES", config.TLSCipherSuites),
+		zap.String("TLS_CLIENT_CA_FILE", config.TLSClientCAFile),
+		zap.String("TLS_CLIENT_AUTH", config.TLSClientAuth),
+		zap.String("NAMESPACES_FILE", config.NamespacesFile),
+		zap.String("LOADERS_FILE", config.LoadersFile),
+		zap.String("STORES_FILE", config.StoresFile),
+		zap.String("AUTH_FILE", config.AuthFile),
+		zap.String("RATE_LIMITS_FILE", config.RateLimitsFile),
+		zap.Strings("NOTIFY_CHANNELS", config.NotifyChannels),
+		zap.Strings("NOTIFY_RESYNC", config.NotifyResync),
+		zap.Int("MAX_KEY_LENGTH", config.MaxKeyLength),
+		zap.Int("MAX_VALUE_SIZE", config.MaxValueSize),
+		zap.Int("MAX_BATCH_SIZE", config.MaxBatchSize),
+		zap.String("OTEL_EXPORTER_OTLP_ENDPOINT", config.OTLPEndpoint),
 	)
 
 	return config
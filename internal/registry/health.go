@@ -0,0 +1,119 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const (
+	// HEARTBEAT_GRACE_PERIOD is how long after its last heartbeat the worker
+	// is still ready, a few missed heartbeats before peers drop it
+	HEARTBEAT_GRACE_PERIOD = 3 * HEARTBEAT_INTERVAL
+
+	// READY_CHECK_TIMEOUT bounds the database ping of a readiness check
+	READY_CHECK_TIMEOUT = 1 * time.Second
+)
+
+var (
+	ErrorNotBootstrapped = errors.New("worker is not registered in the pool yet")
+	ErrorDraining        = errors.New("worker is draining")
+	ErrorHeartbeatStale  = errors.New("no recent heartbeat")
+)
+
+// Status is a snapshot of the state of the worker in the registry
+type Status struct {
+	Self               *Worker    `json:"self"`
+	Pool               []Worker   `json:"pool"`
+	Bootstrapped       bool       `json:"bootstrapped"`
+	Draining           bool       `json:"draining"`
+	LastRefresh        *time.Time `json:"last_refresh,omitempty"`
+	LastRefreshError   string     `json:"last_refresh_error,omitempty"`
+	LastHeartbeat      *time.Time `json:"last_heartbeat,omitempty"`
+	LastHeartbeatError string     `json:"last_heartbeat_error,omitempty"`
+}
+
+// health records the outcome of the background loops of the registry
+type health struct {
+	lastRefresh   time.Time
+	refreshErr    error
+	lastHeartbeat time.Time
+	heartbeatErr  error
+	draining      bool
+}
+
+// Status returns the state of the worker, its pool and the background loops
+// keeping them up to date
+func (r *defaultRegistry) Status() Status {
+	status := Status{Self: r.GetSelfWorker(), Pool: r.GetPool()}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	status.Bootstrapped = r.self != nil && !r.health.lastRefresh.IsZero()
+	status.Draining = r.health.draining
+	// the times are copied so the snapshot does not share the registry state
+	if last := r.health.lastRefresh; !last.IsZero() {
+		status.LastRefresh = &last
+	}
+	if r.health.refreshErr != nil {
+		status.LastRefreshError = r.health.refreshErr.Error()
+	}
+	if last := r.health.lastHeartbeat; !last.IsZero() {
+		status.LastHeartbeat = &last
+	}
+	if r.health.heartbeatErr != nil {
+		status.LastHeartbeatError = r.health.heartbeatErr.Error()
+	}
+	return status
+}
+
+// Ready returns why the worker should not receive traffic, nil when it is
+// registered, heartbeating, can reach the database and is not draining
+func (r *defaultRegistry) Ready(ctx context.Context) error {
+	status := r.Status()
+	if status.Draining {
+		return ErrorDraining
+	}
+	if !status.Bootstrapped {
+		return ErrorNotBootstrapped
+	}
+	if status.LastHeartbeat == nil || time.Since(*status.LastHeartbeat) > HEARTBEAT_GRACE_PERIOD {
+		return ErrorHeartbeatStale
+	}
+
+	if r.db != nil {
+		ctx, cancel := context.WithTimeout(ctx, READY_CHECK_TIMEOUT)
+		defer cancel()
+		if err := r.db.PingContext(ctx); err != nil {
+			return errors.Join(errors.New("database unreachable"), err)
+		}
+	}
+	return nil
+}
+
+// Drain marks the worker as shutting down so it is no longer ready
+func (r *defaultRegistry) Drain() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.health.draining = true
+}
+
+// recordRefresh records the outcome of a pool refresh
+func (r *defaultRegistry) recordRefresh(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.health.refreshErr = err
+	if err == nil {
+		r.health.lastRefresh = time.Now()
+	}
+}
+
+// recordHeartbeat records the outcome of a heartbeat
+func (r *defaultRegistry) recordHeartbeat(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.health.heartbeatErr = err
+	if err == nil {
+		r.health.lastHeartbeat = time.Now()
+	}
+}
@@ -0,0 +1,58 @@
+package registry
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReady(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	assert.NoError(t, err)
+	reg := &defaultRegistry{db: db, self: &Worker{ID: 1, Hostname: "localhost:8081"}, pool: map[string]Worker{}}
+
+	// Check the worker is not ready before the first pool refresh
+	assert.Equal(t, ErrorNotBootstrapped, reg.Ready(context.Background()))
+
+	reg.recordRefresh(nil)
+	assert.Equal(t, ErrorHeartbeatStale, reg.Ready(context.Background()))
+
+	reg.recordHeartbeat(nil)
+	assert.NoError(t, reg.Ready(context.Background()))
+
+	// Check a heartbeat older than the grace period makes it unready
+	reg.health.lastHeartbeat = time.Now().Add(-HEARTBEAT_GRACE_PERIOD - time.Second)
+	assert.Equal(t, ErrorHeartbeatStale, reg.Ready(context.Background()))
+	reg.recordHeartbeat(nil)
+
+	// Check an unreachable database makes it unready
+	db.Close()
+	assert.Error(t, reg.Ready(context.Background()))
+
+	reg.db = nil
+	reg.Drain()
+	assert.Equal(t, ErrorDraining, reg.Ready(context.Background()))
+}
+
+func TestStatus(t *testing.T) {
+	reg := &defaultRegistry{
+		self: &Worker{ID: 1, Hostname: "localhost:8081"},
+		pool: map[string]Worker{"localhost:8083": {ID: 2, Hostname: "localhost:8083"}},
+	}
+	reg.recordRefresh(nil)
+	reg.recordRefresh(errors.New("connection refused"))
+	reg.recordHeartbeat(errors.New("connection refused"))
+
+	// Check the last successful refresh is kept along with the last errors
+	status := reg.Status()
+	assert.True(t, status.Bootstrapped)
+	assert.NotNil(t, status.LastRefresh)
+	assert.Equal(t, "connection refused", status.LastRefreshError)
+	assert.Nil(t, status.LastHeartbeat)
+	assert.Equal(t, "connection refused", status.LastHeartbeatError)
+	assert.Len(t, status.Pool, 1)
+}
@@ -1,18 +1,23 @@
 package registry
 
 import (
-	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	_ "github.com/lib/pq"
+	"github.com/vishaldc/go-cache/internal/cache"
 	"github.com/vishaldc/go-cache/internal/log"
+	"github.com/vishaldc/go-cache/internal/metrics"
+	"github.com/vishaldc/go-cache/internal/peer"
 	"go.uber.org/zap"
 )
 
@@ -32,29 +37,52 @@ const (
 
 // create a registry to hold the db connection
 type defaultRegistry struct {
-	db     *sql.DB
-	pool   map[string]Worker
-	self   *Worker
-	client *http.Client
-	mu     sync.RWMutex
+	db      *sql.DB
+	connStr string
+	pool    map[string]Worker
+	self    *Worker
+	client  *http.Client
+	health  health
+	mu      sync.RWMutex
 }
 
 // Registry defines the methods for the Registry
 type Registry interface {
 	GetSelfWorker() *Worker
-	WriteToPool(key string, value map[string]any) error
-	DeleteFromPool(key string) error
+	GetPool() []Worker
+	WriteToPool(ctx context.Context, ns string, key string, value map[string]any, opts cache.SetOptions) error
+	DeleteFromPool(ctx context.Context, ns string, key string) error
+	InvalidateInPool(ctx context.Context, ns string, keys []string) error
+	IncrInPool(ctx context.Context, ns string, key string, by string, opts cache.IncrOptions) error
+	ApplyInPool(ctx context.Context, ns string, key string, op cache.CollectionOp, opts cache.CollectionOptions) error
+	CommitInPool(ctx context.Context, ns string, txn cache.Txn) error
+	CreateIndexInPool(ctx context.Context, ns string, name string, field string) error
+	DropIndexInPool(ctx context.Context, ns string, name string) error
+	FlushInPool(ctx context.Context, ns string) error
+	ConfigureInPool(ctx context.Context, ns string, config cache.NamespaceConfig) error
+	ShareRateLimitsInPool(ctx context.Context, usage map[string]float64) error
 	RefreshPool() error
+	Bootstrap(ctx context.Context) error
 	Cleanup()
+	Status() Status
+	Ready(ctx context.Context) error
+	Drain()
+	Shutdown(ctx context.Context) error
 }
 
 type Worker struct {
-	ID        int
-	Hostname  string
-	Port      int
-	SyncPort  int
-	CreatedAt time.Time
-	Updated   time.Time
+	ID        int       `json:"id"`
+	Hostname  string    `json:"hostname"`
+	Port      int       `json:"port"`
+	SyncPort  int       `json:"sync_port"`
+	CreatedAt time.Time `json:"created_at"`
+	Updated   time.Time `json:"updated_at"`
+}
+
+// Membership is a snapshot of the current worker and the pool it replicates to
+type Membership struct {
+	Self *Worker  `json:"self"`
+	Pool []Worker `json:"pool"`
 }
 
 // String returns the string representation of the worker
@@ -71,6 +99,18 @@ func GetRegistry() Registry {
 	return &conf
 }
 
+// DB returns the database connection of the registry, shared with the
+// components reading through to the database
+func DB() *sql.DB {
+	return conf.db
+}
+
+// ConnectionString returns the connection string of the registry database,
+// for the components that open their own connection to it
+func ConnectionString() string {
+	return conf.connStr
+}
+
 // Setup creates the cache table in the database
 func Setup(config *Configuration) {
 	db, err := connectToDB(config)
@@ -79,6 +119,7 @@ func Setup(config *Configuration) {
 	}
 
 	conf.db = db
+	conf.connStr = connectionString(config)
 
 	self := &Worker{}
 	self.Hostname = config.Hostname + ":" + config.SyncPort
@@ -112,9 +153,7 @@ func Setup(config *Configuration) {
 		}
 	}
 
-	client := &http.Client{}
-	client.Timeout = 1 * time.Second
-	conf.client = client
+	conf.client = peer.Client(1 * time.Second)
 	conf.self = self
 	runHeartbeat(conf.self, conf.db)
 	runRefreshPool()
@@ -131,10 +170,15 @@ func runDeleteStaleWorkers() {
 	}()
 }
 
+// connectionString returns the connection string of the database
+func connectionString(config *Configuration) string {
+	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+		config.DBHost, config.DBPort, config.DBUser, config.DBPassword, config.DBName)
+}
+
 func connectToDB(config *Configuration) (*sql.DB, error) {
 	// Create the connection string
-	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		config.DBHost, config.DBPort, config.DBUser, config.DBPassword, config.DBName)
+	connStr := connectionString(config)
 	log.Logger.Info("connecting to the database", zap.String("connection_string", connStr))
 	// Connect to the database
 	db, err := sql.Open("postgres", connStr)
@@ -224,68 +268,48 @@ func (r *defaultRegistry) GetSelfWorker() *Worker {
 	return r.self
 }
 
-// DeleteFromPool deletes a key from the pool
-func (r *defaultRegistry) DeleteFromPool(key string) error {
+// GetPool returns the workers currently in the pool sorted by hostname
+func (r *defaultRegistry) GetPool() []Worker {
 	r.mu.RLock()
-	defer r.mu.RUnlock()
-
-	if len(r.pool) == 0 {
-		log.Logger.Error("no workers in the pool")
-		return nil
-	}
-
+	workers := make([]Worker, 0, len(r.pool))
 	for _, w := range r.pool {
-		log.Logger.Info("writing to worker", zap.String("worker", w.Hostname))
-		req, err := http.NewRequest(http.MethodDelete, fmt.Sprintf("http://%s/cache/sync?key=%s", w.Hostname, key), nil)
-		if err != nil {
-			log.Logger.Error("failed to create request", zap.String("worker", w.Hostname), zap.String("error", err.Error()))
-			return err
-		}
-		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("Accept", "application/json")
-		resp, err := r.client.Do(req)
-		if err != nil {
-			log.Logger.Error("failed to write to worker", zap.String("worker", w.Hostname), zap.String("error", err.Error()))
-			continue
-		}
-		if resp.StatusCode >= http.StatusBadRequest {
-			log.Logger.Error("failed to write to worker", zap.String("worker", w.Hostname), zap.Int("status_code", resp.StatusCode))
-			continue
-		}
-		log.Logger.Info("successfully wrote to worker", zap.String("worker", w.Hostname))
+		workers = append(workers, w)
 	}
-	return nil
+	r.mu.RUnlock()
+
+	sort.Slice(workers, func(i, j int) bool { return workers[i].Hostname < workers[j].Hostname })
+	return workers
+}
+
+// DeleteFromPool deletes a key of the namespace from the pool
+func (r *defaultRegistry) DeleteFromPool(ctx context.Context, ns string, key string) error {
+	return r.broadcast(ctx, http.MethodDelete, "/cache/sync", nsParams(ns, url.Values{"key": {key}}), nil)
 }
 
-// WriteToPool writes a key value to the list of workers in the pool
-func (r *defaultRegistry) WriteToPool(key string, value map[string]any) error {
+// WriteToPool writes a key value of the namespace to the list of workers in
+// the pool, the ttl, grace period, tags and version are forwarded so replicas
+// expire, invalidate and tag the key the same way
+func (r *defaultRegistry) WriteToPool(ctx context.Context, ns string, key string, value map[string]any, opts cache.SetOptions) error {
 	b, err := json.Marshal(value)
 	if err != nil {
 		log.Logger.Error("failed to marshal value", zap.String("error", err.Error()))
+		return err
 	}
 
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-
-	if len(r.pool) == 0 {
-		log.Logger.Error("no workers in the pool")
-		return nil
+	params := url.Values{"key": {key}}
+	if opts.TTL > 0 {
+		params.Set("ttl", opts.TTL.String())
 	}
-
-	for _, w := range r.pool {
-		log.Logger.Info("writing to worker", zap.String("worker", w.Hostname))
-		resp, err := r.client.Post(fmt.Sprintf("http://%s/cache/sync?key=%s", w.Hostname, key), "application/json", bytes.NewBuffer(b))
-		if err != nil {
-			log.Logger.Error("failed to write to worker", zap.String("worker", w.Hostname), zap.String("error", err.Error()))
-			continue
-		}
-		if resp.StatusCode >= http.StatusBadRequest {
-			log.Logger.Error("failed to write to worker", zap.String("worker", w.Hostname), zap.Int("status_code", resp.StatusCode))
-			continue
-		}
-		log.Logger.Info("successfully wrote to worker", zap.String("worker", w.Hostname))
+	if opts.Stale > 0 {
+		params.Set("stale", opts.Stale.String())
 	}
-	return nil
+	for _, tag := range opts.Tags {
+		params.Add("tag", tag)
+	}
+	if opts.Version > 0 {
+		params.Set("version", strconv.FormatUint(opts.Version, 10))
+	}
+	return r.broadcast(ctx, http.MethodPost, "/cache/sync", nsParams(ns, params), b)
 }
 
 // runRefreshPool runs the refresh pool function
@@ -306,6 +330,7 @@ func (r *defaultRegistry) RefreshPool() error {
 	workers, err := getOtherWorkers(r.self)
 	if err != nil {
 		log.Logger.Error("failed to get other workers", zap.String("error", err.Error()))
+		r.recordRefresh(err)
 		return err
 	}
 
@@ -317,7 +342,9 @@ func (r *defaultRegistry) RefreshPool() error {
 		workerNames = append(workerNames, w.String())
 	}
 	log.Logger.Info("workers in the pool", zap.String("workers", strings.Join(workerNames, ", ")))
+	metrics.PoolSize.Set(float64(len(r.pool)))
 	r.mu.Unlock()
+	r.recordRefresh(nil)
 	return nil
 }
 
@@ -338,7 +365,10 @@ func (w *Worker) Heartbeat(db *sql.DB) error {
 func runHeartbeat(w *Worker, db *sql.DB) {
 	go func() {
 		for {
-			if err := w.Heartbeat(db); err != nil {
+			err := w.Heartbeat(db)
+			metrics.Heartbeats.WithLabelValues(metrics.Result(err)).Inc()
+			conf.recordHeartbeat(err)
+			if err != nil {
 				log.Logger.Error("failed to record heartbeat", zap.String("error", err.Error()))
 			}
 			time.Sleep(HEARTBEAT_INTERVAL)
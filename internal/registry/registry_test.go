@@ -1,12 +1,14 @@
 package registry
 
 import (
+	"context"
 	"database/sql"
 	"net/http"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/vishaldc/go-cache/internal/cache"
 	_ "modernc.org/sqlite"
 )
 
@@ -79,7 +81,7 @@ func TestWriteToPool(t *testing.T) {
 	}
 	reg.pool["localhost:8081"] = worker
 
-	err := reg.WriteToPool("testKey", map[string]any{"value": "testValue"})
+	err := reg.WriteToPool(context.Background(), "", "testKey", map[string]any{"value": "testValue"}, cache.SetOptions{})
 	assert.NoError(t, err)
 }
 
@@ -122,7 +124,7 @@ func TestWriteToPoolWithMockClient(t *testing.T) {
 	reg.pool["localhost:8081"] = worker
 
 	// Call the method to test
-	err := reg.WriteToPool("testKey", map[string]any{"value": "testValue"})
+	err := reg.WriteToPool(context.Background(), "", "testKey", map[string]any{"value": "testValue"}, cache.SetOptions{})
 	assert.NoError(t, err)
 }
 
@@ -146,7 +148,7 @@ func TestDeleteFromPool(t *testing.T) {
 	}
 	reg.pool["localhost:8081"] = worker
 
-	err := reg.DeleteFromPool("testKey")
+	err := reg.DeleteFromPool(context.Background(), "", "testKey")
 	assert.NoError(t, err)
 }
 
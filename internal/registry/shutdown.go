@@ -0,0 +1,54 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/vishaldc/go-cache/internal/log"
+	"go.uber.org/zap"
+)
+
+// inflight tracks the replication running in the background
+var inflight sync.WaitGroup
+
+// Go runs the replication of a write in the background, Shutdown waits for
+// it so the write still reaches the pool when the worker stops
+func Go(f func()) {
+	inflight.Add(1)
+	go func() {
+		defer inflight.Done()
+		f()
+	}()
+}
+
+// Shutdown drains the worker, removes it from the registry so peers stop
+// replicating to it and waits for the replication running in the background
+// until ctx is done
+func (r *defaultRegistry) Shutdown(ctx context.Context) error {
+	r.Drain()
+
+	var err error
+	if r.db != nil && r.self != nil {
+		query := `DELETE FROM go_cache.workers WHERE worker = $1`
+		if _, err = r.db.ExecContext(ctx, query, r.self.Hostname); err != nil {
+			log.Logger.Error("failed to deregister worker", zap.String("error", err.Error()))
+		} else {
+			log.Logger.Info("successfully deregistered worker", zap.String("worker", r.self.Hostname))
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		inflight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		log.Logger.Info("replication flushed")
+		return err
+	case <-ctx.Done():
+		log.Logger.Warn("replication still in flight at shutdown")
+		return errors.Join(err, ctx.Err())
+	}
+}
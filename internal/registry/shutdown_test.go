@@ -0,0 +1,56 @@
+package registry
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShutdown(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	assert.NoError(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	_, err = db.Exec(`ATTACH DATABASE ':memory:' AS go_cache`)
+	assert.NoError(t, err)
+	_, err = db.Exec(`CREATE TABLE go_cache.workers (worker TEXT NOT NULL, updated_at DATETIME NOT NULL)`)
+	assert.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO go_cache.workers VALUES ('localhost:8081', $1), ('localhost:8083', $1)`, time.Now())
+	assert.NoError(t, err)
+
+	reg := &defaultRegistry{db: db, self: &Worker{Hostname: "localhost:8081"}, pool: map[string]Worker{}}
+	replicated := false
+	Go(func() {
+		time.Sleep(10 * time.Millisecond)
+		replicated = true
+	})
+	assert.NoError(t, reg.Shutdown(context.Background()))
+
+	// Check the background replication finished and only this worker left
+	assert.True(t, replicated)
+	assert.Equal(t, ErrorDraining, reg.Ready(context.Background()))
+	var workers []string
+	rows, err := db.Query(`SELECT worker FROM go_cache.workers`)
+	assert.NoError(t, err)
+	for rows.Next() {
+		var worker string
+		assert.NoError(t, rows.Scan(&worker))
+		workers = append(workers, worker)
+	}
+	assert.Equal(t, []string{"localhost:8083"}, workers)
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	reg := &defaultRegistry{pool: map[string]Worker{}}
+	release := make(chan struct{})
+	defer close(release)
+	Go(func() { <-release })
+
+	// Check shutdown gives up on replication still running at the deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, reg.Shutdown(ctx), context.DeadlineExceeded)
+}
@@ -0,0 +1,194 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/vishaldc/go-cache/internal/cache"
+	"github.com/vishaldc/go-cache/internal/log"
+	"github.com/vishaldc/go-cache/internal/metrics"
+	"github.com/vishaldc/go-cache/internal/peer"
+	"github.com/vishaldc/go-cache/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// InvalidateInPool removes the keys of the namespace selected by a bulk
+// invalidation from the workers in the pool. The keys are sent rather than
+// the selector so every worker removes the keys removed here, in requests of
+// at most the maximum batch size.
+func (r *defaultRegistry) InvalidateInPool(ctx context.Context, ns string, keys []string) error {
+	size := cache.CurrentLimits().MaxBatchSize
+	for start := 0; start < len(keys); start += size {
+		b, err := json.Marshal(map[string][]string{"keys": keys[start:min(start+size, len(keys))]})
+		if err != nil {
+			log.Logger.Error("failed to marshal keys", zap.String("error", err.Error()))
+			return err
+		}
+		if err := r.broadcast(ctx, http.MethodDelete, "/cache/sync/invalidate", nsParams(ns, url.Values{}), b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IncrInPool replicates an increment of by to the workers in the pool. The
+// increment is sent rather than the resulting value so increments applied
+// concurrently on different workers are not lost.
+func (r *defaultRegistry) IncrInPool(ctx context.Context, ns string, key string, by string, opts cache.IncrOptions) error {
+	params := url.Values{"key": {key}, "by": {by}}
+	if opts.Field != "" {
+		params.Set("field", opts.Field)
+	}
+	if opts.TTL > 0 {
+		params.Set("ttl", opts.TTL.String())
+	}
+	if opts.Version > 0 {
+		params.Set("version", strconv.FormatUint(opts.Version, 10))
+	}
+	return r.broadcast(ctx, http.MethodPost, "/cache/sync/incr", nsParams(ns, params), nil)
+}
+
+// ApplyInPool replicates a collection write to the workers in the pool, the
+// operation is sent rather than the resulting collection so concurrent writes
+// on different workers are not lost
+func (r *defaultRegistry) ApplyInPool(ctx context.Context, ns string, key string, op cache.CollectionOp, opts cache.CollectionOptions) error {
+	b, err := json.Marshal(op)
+	if err != nil {
+		log.Logger.Error("failed to marshal collection operation", zap.String("error", err.Error()))
+		return err
+	}
+
+	params := url.Values{"key": {key}}
+	if opts.TTL > 0 {
+		params.Set("ttl", opts.TTL.String())
+	}
+	if opts.Version > 0 {
+		params.Set("version", strconv.FormatUint(opts.Version, 10))
+	}
+	return r.broadcast(ctx, http.MethodPost, "/cache/sync/collection", nsParams(ns, params), b)
+}
+
+// CommitInPool replicates a transaction to the workers in the pool as a
+// single request, so replicas apply it all at once
+func (r *defaultRegistry) CommitInPool(ctx context.Context, ns string, txn cache.Txn) error {
+	b, err := json.Marshal(txn)
+	if err != nil {
+		log.Logger.Error("failed to marshal transaction", zap.String("error", err.Error()))
+		return err
+	}
+	return r.broadcast(ctx, http.MethodPost, "/cache/sync/txn", nsParams(ns, url.Values{}), b)
+}
+
+// CreateIndexInPool declares a secondary index of the namespace on the
+// workers in the pool
+func (r *defaultRegistry) CreateIndexInPool(ctx context.Context, ns string, name string, field string) error {
+	return r.broadcast(ctx, http.MethodPut, "/cache/sync/indexes", nsParams(ns, url.Values{"index": {name}, "field": {field}}), nil)
+}
+
+// DropIndexInPool drops a secondary index of the namespace on the workers in
+// the pool
+func (r *defaultRegistry) DropIndexInPool(ctx context.Context, ns string, name string) error {
+	return r.broadcast(ctx, http.MethodDelete, "/cache/sync/indexes", nsParams(ns, url.Values{"index": {name}}), nil)
+}
+
+// FlushInPool removes every key of the namespace on the workers in the pool
+func (r *defaultRegistry) FlushInPool(ctx context.Context, ns string) error {
+	return r.broadcast(ctx, http.MethodDelete, "/cache/sync/flush", nsParams(ns, url.Values{}), nil)
+}
+
+// ConfigureInPool applies the namespace limits on the workers in the pool
+func (r *defaultRegistry) ConfigureInPool(ctx context.Context, ns string, config cache.NamespaceConfig) error {
+	b, err := json.Marshal(config)
+	if err != nil {
+		log.Logger.Error("failed to marshal namespace config", zap.String("error", err.Error()))
+		return err
+	}
+	return r.broadcast(ctx, http.MethodPut, "/cache/sync/ns", nsParams(ns, url.Values{}), b)
+}
+
+// ShareRateLimitsInPool sends the tokens taken from the cluster wide rate
+// limit buckets, by bucket, to the workers in the pool
+func (r *defaultRegistry) ShareRateLimitsInPool(ctx context.Context, usage map[string]float64) error {
+	b, err := json.Marshal(usage)
+	if err != nil {
+		log.Logger.Error("failed to marshal rate limits", zap.String("error", err.Error()))
+		return err
+	}
+	return r.broadcast(ctx, http.MethodPost, "/cache/sync/ratelimit", url.Values{}, b)
+}
+
+// nsParams adds the namespace to the sync request parameters, the default
+// namespace is sent without one
+func nsParams(ns string, params url.Values) url.Values {
+	if ns != "" {
+		params.Set("ns", ns)
+	}
+	return params
+}
+
+// broadcast sends a request to the sync server of every worker in the pool.
+// Failures are logged per worker and do not stop the fan-out.
+func (r *defaultRegistry) broadcast(ctx context.Context, method, path string, params url.Values, body []byte) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if len(r.pool) == 0 {
+		log.Logger.Error("no workers in the pool")
+		return nil
+	}
+
+	fanout := time.Now()
+	defer func() { metrics.FanoutDuration.WithLabelValues(path).Observe(time.Since(fanout).Seconds()) }()
+
+	for _, w := range r.pool {
+		if err := r.send(ctx, w, method, path, params, body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// send sends a sync request to a worker of the pool in a client span, so
+// the trace of the write continues on the peer. Only a request that cannot
+// be built is returned, failed requests are logged and counted.
+func (r *defaultRegistry) send(ctx context.Context, w Worker, method, path string, params url.Values, body []byte) error {
+	ctx, span := tracing.StartClient(ctx, method+" "+path, attribute.String("peer", w.Hostname))
+
+	log.Logger.Info("writing to worker", zap.String("worker", w.Hostname), zap.String("path", path))
+	req, err := http.NewRequestWithContext(ctx, method, fmt.Sprintf("%s://%s%s?%s", peer.Scheme(), w.Hostname, path, params.Encode()), bytes.NewReader(body))
+	if err != nil {
+		log.Logger.Error("failed to create request", zap.String("worker", w.Hostname), zap.String("error", err.Error()))
+		tracing.End(span, err)
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	tracing.Inject(ctx, req.Header)
+	peer.Sign(req, body)
+	start := time.Now()
+	resp, err := r.client.Do(req)
+	metrics.ReplicationDuration.WithLabelValues(w.Hostname, path).Observe(time.Since(start).Seconds())
+	if err != nil {
+		metrics.ReplicationFailures.WithLabelValues(w.Hostname, path).Inc()
+		log.Logger.Error("failed to write to worker", zap.String("worker", w.Hostname), zap.String("error", err.Error()))
+		tracing.End(span, err)
+		return nil
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		metrics.ReplicationFailures.WithLabelValues(w.Hostname, path).Inc()
+		log.Logger.Error("failed to write to worker", zap.String("worker", w.Hostname), zap.Int("status_code", resp.StatusCode))
+		tracing.End(span, fmt.Errorf("worker responded with status %d", resp.StatusCode))
+		return nil
+	}
+	log.Logger.Info("successfully wrote to worker", zap.String("worker", w.Hostname))
+	tracing.End(span, nil)
+	return nil
+}
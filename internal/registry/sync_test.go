@@ -0,0 +1,297 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/vishaldc/go-cache/internal/cache"
+	"github.com/vishaldc/go-cache/internal/metrics"
+	"github.com/vishaldc/go-cache/internal/peer"
+	"github.com/vishaldc/go-cache/internal/tracing"
+)
+
+func TestInvalidateInPool(t *testing.T) {
+	var requests []*http.Request
+	reg := &defaultRegistry{
+		pool: map[string]Worker{
+			"localhost:8081": {ID: 1, Hostname: "localhost:8081"},
+			"localhost:8083": {ID: 2, Hostname: "localhost:8083"},
+		},
+		client: &http.Client{
+			Timeout: 1 * time.Second,
+			Transport: &MockRoundTripper{
+				roundTripFunc: func(req *http.Request) *http.Response {
+					requests = append(requests, req)
+					return &http.Response{StatusCode: http.StatusNoContent, Header: make(http.Header)}
+				},
+			},
+		},
+	}
+
+	cache.SetLimits(cache.Limits{MaxBatchSize: 2})
+	t.Cleanup(func() { cache.SetLimits(cache.Limits{}) })
+	err := reg.InvalidateInPool(context.Background(), "", []string{"product:1", "product:2", "product:3"})
+	assert.NoError(t, err)
+
+	// Check the keys were sent to every worker in batches
+	assert.Len(t, requests, 4)
+	var keys []string
+	for _, req := range requests {
+		assert.Equal(t, http.MethodDelete, req.Method)
+		assert.Equal(t, "/cache/sync/invalidate", req.URL.Path)
+		var body struct {
+			Keys []string `json:"keys"`
+		}
+		assert.NoError(t, json.NewDecoder(req.Body).Decode(&body))
+		assert.LessOrEqual(t, len(body.Keys), 2)
+		keys = append(keys, body.Keys...)
+	}
+	assert.ElementsMatch(t, []string{"product:1", "product:2", "product:3", "product:1", "product:2", "product:3"}, keys)
+}
+
+func TestWriteToPoolForwardsOptions(t *testing.T) {
+	var request *http.Request
+	reg := &defaultRegistry{
+		pool: map[string]Worker{"localhost:8081": {ID: 1, Hostname: "localhost:8081"}},
+		client: &http.Client{
+			Transport: &MockRoundTripper{
+				roundTripFunc: func(req *http.Request) *http.Response {
+					request = req
+					return &http.Response{StatusCode: http.StatusNoContent, Header: make(http.Header)}
+				},
+			},
+		},
+	}
+
+	err := reg.WriteToPool(context.Background(), "", "a key", map[string]any{"value": "testValue"}, cache.SetOptions{TTL: time.Minute, Tags: []string{"t1", "t2"}})
+	assert.NoError(t, err)
+
+	// Check the key is escaped and the ttl and tags are forwarded
+	assert.Equal(t, "a key", request.URL.Query().Get("key"))
+	assert.Equal(t, "1m0s", request.URL.Query().Get("ttl"))
+	assert.Equal(t, []string{"t1", "t2"}, request.URL.Query()["tag"])
+}
+
+func TestFlushInPool(t *testing.T) {
+	var request *http.Request
+	reg := &defaultRegistry{
+		pool: map[string]Worker{"localhost:8081": {ID: 1, Hostname: "localhost:8081"}},
+		client: &http.Client{
+			Transport: &MockRoundTripper{
+				roundTripFunc: func(req *http.Request) *http.Response {
+					request = req
+					return &http.Response{StatusCode: http.StatusNoContent, Header: make(http.Header)}
+				},
+			},
+		},
+	}
+
+	err := reg.FlushInPool(context.Background(), "sessions")
+	assert.NoError(t, err)
+
+	// Check the flush names the namespace
+	assert.Equal(t, http.MethodDelete, request.Method)
+	assert.Equal(t, "/cache/sync/flush", request.URL.Path)
+	assert.Equal(t, "sessions", request.URL.Query().Get("ns"))
+}
+
+func TestIncrInPool(t *testing.T) {
+	var request *http.Request
+	reg := &defaultRegistry{
+		pool: map[string]Worker{"localhost:8081": {ID: 1, Hostname: "localhost:8081"}},
+		client: &http.Client{
+			Transport: &MockRoundTripper{
+				roundTripFunc: func(req *http.Request) *http.Response {
+					request = req
+					return &http.Response{StatusCode: http.StatusNoContent, Header: make(http.Header)}
+				},
+			},
+		},
+	}
+
+	err := reg.IncrInPool(context.Background(), "quotas", "requests", "-2", cache.IncrOptions{TTL: time.Minute, Version: 7})
+	assert.NoError(t, err)
+
+	// Check the increment is forwarded rather than a value
+	assert.Equal(t, http.MethodPost, request.Method)
+	assert.Equal(t, "/cache/sync/incr", request.URL.Path)
+	assert.Equal(t, "quotas", request.URL.Query().Get("ns"))
+	assert.Equal(t, "requests", request.URL.Query().Get("key"))
+	assert.Equal(t, "-2", request.URL.Query().Get("by"))
+	assert.Equal(t, "1m0s", request.URL.Query().Get("ttl"))
+	assert.Equal(t, "7", request.URL.Query().Get("version"))
+}
+
+func TestCreateIndexInPool(t *testing.T) {
+	var request *http.Request
+	reg := &defaultRegistry{
+		pool: map[string]Worker{"localhost:8081": {ID: 1, Hostname: "localhost:8081"}},
+		client: &http.Client{
+			Transport: &MockRoundTripper{
+				roundTripFunc: func(req *http.Request) *http.Response {
+					request = req
+					return &http.Response{StatusCode: http.StatusNoContent, Header: make(http.Header)}
+				},
+			},
+		},
+	}
+
+	err := reg.CreateIndexInPool(context.Background(), "sessions", "user", "user.id")
+	assert.NoError(t, err)
+
+	// Check the index definition is forwarded
+	assert.Equal(t, http.MethodPut, request.Method)
+	assert.Equal(t, "/cache/sync/indexes", request.URL.Path)
+	assert.Equal(t, "sessions", request.URL.Query().Get("ns"))
+	assert.Equal(t, "user", request.URL.Query().Get("index"))
+	assert.Equal(t, "user.id", request.URL.Query().Get("field"))
+}
+
+func TestApplyInPool(t *testing.T) {
+	var request *http.Request
+	var body []byte
+	reg := &defaultRegistry{
+		pool: map[string]Worker{"localhost:8081": {ID: 1, Hostname: "localhost:8081"}},
+		client: &http.Client{
+			Transport: &MockRoundTripper{
+				roundTripFunc: func(req *http.Request) *http.Response {
+					request = req
+					body, _ = io.ReadAll(req.Body)
+					return &http.Response{StatusCode: http.StatusNoContent, Header: make(http.Header)}
+				},
+			},
+		},
+	}
+
+	err := reg.ApplyInPool(context.Background(), "", "queue", cache.CollectionOp{Op: cache.OpRPop, Count: 2}, cache.CollectionOptions{Version: 3})
+	assert.NoError(t, err)
+
+	// Check the operation is forwarded rather than the collection
+	assert.Equal(t, "/cache/sync/collection", request.URL.Path)
+	assert.Equal(t, "queue", request.URL.Query().Get("key"))
+	assert.Equal(t, "3", request.URL.Query().Get("version"))
+	assert.JSONEq(t, `{"op":"rpop","count":2}`, string(body))
+}
+
+func TestCommitInPool(t *testing.T) {
+	var requests int
+	var body []byte
+	reg := &defaultRegistry{
+		pool: map[string]Worker{
+			"localhost:8081": {ID: 1, Hostname: "localhost:8081"},
+			"localhost:8083": {ID: 2, Hostname: "localhost:8083"},
+		},
+		client: &http.Client{
+			Transport: &MockRoundTripper{
+				roundTripFunc: func(req *http.Request) *http.Response {
+					requests++
+					assert.Equal(t, "/cache/sync/txn", req.URL.Path)
+					body, _ = io.ReadAll(req.Body)
+					return &http.Response{StatusCode: http.StatusNoContent, Header: make(http.Header)}
+				},
+			},
+		},
+	}
+
+	txn := cache.Txn{Version: 9, Ops: []cache.TxnOp{{Op: cache.TxnDelete, Key: "a"}, {Op: cache.TxnDelete, Key: "b"}}}
+	assert.NoError(t, reg.CommitInPool(context.Background(), "", txn))
+
+	// Check the whole transaction is sent in one request per worker
+	assert.Equal(t, 2, requests)
+	assert.JSONEq(t, `{"version":9,"ops":[{"op":"delete","key":"a"},{"op":"delete","key":"b"}]}`, string(body))
+}
+
+func TestBroadcastMetrics(t *testing.T) {
+	reg := &defaultRegistry{
+		pool: map[string]Worker{"metrics-peer:8081": {ID: 1, Hostname: "metrics-peer:8081"}},
+		client: &http.Client{
+			Transport: &MockRoundTripper{
+				roundTripFunc: func(req *http.Request) *http.Response {
+					return &http.Response{StatusCode: http.StatusInternalServerError, Body: io.NopCloser(strings.NewReader("")), Header: make(http.Header)}
+				},
+			},
+		},
+	}
+
+	// Check rejected sync requests are counted per peer
+	assert.NoError(t, reg.DeleteFromPool(context.Background(), "", "key"))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.ReplicationFailures.WithLabelValues("metrics-peer:8081", "/cache/sync")))
+}
+
+func TestBroadcastPropagatesTrace(t *testing.T) {
+	exporter := tracing.SetupInMemory()
+	var request *http.Request
+	reg := &defaultRegistry{
+		pool: map[string]Worker{"localhost:8081": {ID: 1, Hostname: "localhost:8081"}},
+		client: &http.Client{
+			Transport: &MockRoundTripper{
+				roundTripFunc: func(req *http.Request) *http.Response {
+					request = req
+					return &http.Response{StatusCode: http.StatusNoContent, Header: make(http.Header)}
+				},
+			},
+		},
+	}
+
+	ctx, span := tracing.Start(context.Background(), "test")
+	assert.NoError(t, reg.DeleteFromPool(ctx, "", "key"))
+	span.End()
+
+	// Check the sync request carries the trace of the write in a client span
+	assert.Contains(t, request.Header.Get("traceparent"), span.SpanContext().TraceID().String())
+	spans := exporter.GetSpans()
+	assert.Len(t, spans, 2)
+	assert.Equal(t, "DELETE /cache/sync", spans[0].Name)
+	assert.Equal(t, span.SpanContext().SpanID(), spans[0].Parent.SpanID())
+}
+
+func TestBroadcastSignsRequests(t *testing.T) {
+	assert.NoError(t, peer.Setup(peer.Config{Secret: "secret"}))
+	t.Cleanup(func() { peer.Setup(peer.Config{}) })
+	var verified error
+	reg := &defaultRegistry{
+		pool: map[string]Worker{"localhost:8081": {ID: 1, Hostname: "localhost:8081"}},
+		client: &http.Client{
+			Transport: &MockRoundTripper{
+				roundTripFunc: func(req *http.Request) *http.Response {
+					verified = peer.Verify(req)
+					return &http.Response{StatusCode: http.StatusNoContent, Header: make(http.Header)}
+				},
+			},
+		},
+	}
+
+	// Check the sync request carries a signature the peer accepts
+	assert.NoError(t, reg.WriteToPool(context.Background(), "", "key", map[string]any{"value": "v"}, cache.SetOptions{}))
+	assert.NoError(t, verified)
+}
+
+func TestShareRateLimitsInPool(t *testing.T) {
+	var request *http.Request
+	var body []byte
+	reg := &defaultRegistry{
+		pool: map[string]Worker{"localhost:8081": {ID: 1, Hostname: "localhost:8081"}},
+		client: &http.Client{
+			Transport: &MockRoundTripper{
+				roundTripFunc: func(req *http.Request) *http.Response {
+					request = req
+					body, _ = io.ReadAll(req.Body)
+					return &http.Response{StatusCode: http.StatusNoContent, Header: make(http.Header)}
+				},
+			},
+		},
+	}
+
+	assert.NoError(t, reg.ShareRateLimitsInPool(context.Background(), map[string]float64{"ip:10.0.0.1": 3}))
+
+	// Check the tokens taken are sent by bucket
+	assert.Equal(t, "/cache/sync/ratelimit", request.URL.Path)
+	assert.JSONEq(t, `{"ip:10.0.0.1":3}`, string(body))
+}
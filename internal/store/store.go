@@ -0,0 +1,551 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/vishaldc/go-cache/internal/log"
+	"go.uber.org/zap"
+	_ "modernc.org/sqlite"
+)
+
+const (
+	// WriteThrough writes to the table before the request completes
+	WriteThrough = "write-through"
+
+	// WriteBehind queues writes and flushes them to the table in batches
+	WriteBehind = "write-behind"
+)
+
+const (
+	// DEFAULT_TABLE is the table keys are written to
+	DEFAULT_TABLE = "go_cache.entries"
+
+	// DEFAULT_DEAD_LETTER_TABLE is the table writes that exhausted their
+	// retries are recorded in
+	DEFAULT_DEAD_LETTER_TABLE = "go_cache.dead_letters"
+
+	// DEFAULT_BATCH_SIZE is the maximum number of queued writes flushed in
+	// one transaction
+	DEFAULT_BATCH_SIZE = 100
+
+	// DEFAULT_FLUSH_INTERVAL is the interval at which queued writes are flushed
+	DEFAULT_FLUSH_INTERVAL = 1 * time.Second
+
+	// DEFAULT_MAX_RETRIES is the number of times a failed write is retried
+	// before it is dead lettered
+	DEFAULT_MAX_RETRIES = 5
+
+	// MAX_RETRY_BACKOFF caps the delay before a failed write is retried
+	MAX_RETRY_BACKOFF = 1 * time.Minute
+
+	// WRITE_TIMEOUT bounds a write or a flush to the table
+	WRITE_TIMEOUT = 5 * time.Second
+)
+
+const (
+	opSet    = "set"
+	opDelete = "delete"
+)
+
+var ErrorInvalidStore = errors.New("invalid store")
+
+// table names are interpolated into the statements, so only plain and
+// schema qualified identifiers are accepted
+var validTable = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// Store persists the writes to the keys of a namespace to a table with the
+// columns namespace, key, value, version and updated_at, keyed by namespace
+// and key. Write through stores write before the request completes, write
+// behind stores queue the writes, keep only the latest one of each key and
+// flush them in batches, retrying failed writes with a backoff before
+// recording them in the dead letter table.
+type Store struct {
+	Namespace       string
+	Mode            string
+	Table           string
+	DeadLetterTable string
+	// Driver and DSN connect the store to its own database instead of the
+	// database of the registry
+	Driver        string
+	DSN           string
+	BatchSize     int
+	FlushInterval time.Duration
+	MaxRetries    int
+
+	db      *sql.DB
+	upsert  string
+	remove  string
+	clear   string
+	dead    string
+	mu      sync.Mutex
+	pending map[string]*write
+	wake    chan struct{}
+	flushMu sync.Mutex
+}
+
+type storeJSON struct {
+	Namespace       string `json:"namespace,omitempty"`
+	Mode            string `json:"mode"`
+	Table           string `json:"table,omitempty"`
+	DeadLetterTable string `json:"dead_letter_table,omitempty"`
+	Driver          string `json:"driver,omitempty"`
+	DSN             string `json:"dsn,omitempty"`
+	BatchSize       int    `json:"batch_size,omitempty"`
+	FlushInterval   string `json:"flush_interval,omitempty"`
+	MaxRetries      int    `json:"max_retries,omitempty"`
+}
+
+// UnmarshalJSON decodes a store, the flush interval as a duration string
+// such as 500ms, and fills in the defaults
+func (s *Store) UnmarshalJSON(b []byte) error {
+	var v storeJSON
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*s = Store{
+		Namespace:       v.Namespace,
+		Mode:            v.Mode,
+		Table:           v.Table,
+		DeadLetterTable: v.DeadLetterTable,
+		Driver:          v.Driver,
+		DSN:             v.DSN,
+		BatchSize:       v.BatchSize,
+		MaxRetries:      v.MaxRetries,
+	}
+	if v.FlushInterval != "" {
+		interval, err := time.ParseDuration(v.FlushInterval)
+		if err != nil {
+			return err
+		}
+		s.FlushInterval = interval
+	}
+
+	if s.Table == "" {
+		s.Table = DEFAULT_TABLE
+	}
+	if s.DeadLetterTable == "" {
+		s.DeadLetterTable = DEFAULT_DEAD_LETTER_TABLE
+	}
+	if s.Driver == "" {
+		s.Driver = "postgres"
+	}
+	if s.BatchSize == 0 {
+		s.BatchSize = DEFAULT_BATCH_SIZE
+	}
+	if s.FlushInterval == 0 {
+		s.FlushInterval = DEFAULT_FLUSH_INTERVAL
+	}
+	if s.MaxRetries == 0 {
+		s.MaxRetries = DEFAULT_MAX_RETRIES
+	}
+
+	switch {
+	case s.Mode != WriteThrough && s.Mode != WriteBehind:
+		return fmt.Errorf("%w: unknown mode %q", ErrorInvalidStore, s.Mode)
+	case !validTable.MatchString(s.Table) || !validTable.MatchString(s.DeadLetterTable):
+		return fmt.Errorf("%w: invalid table name", ErrorInvalidStore)
+	case s.BatchSize < 0 || s.FlushInterval < 0 || s.MaxRetries < 0:
+		return fmt.Errorf("%w: batch size, flush interval and retries must not be negative", ErrorInvalidStore)
+	}
+	return nil
+}
+
+// write is a pending write of a key
+type write struct {
+	op       string
+	key      string
+	value    map[string]any
+	version  uint64
+	attempts int
+	due      time.Time
+	err      error
+}
+
+var mu sync.RWMutex
+var stores = make(map[string]*Store)
+
+// Setup registers the stores defined in a JSON file, a list of stores such
+// as [{"namespace": "users", "mode": "write-behind", "flush_interval": "500ms"}],
+// written to database unless they name their own, and starts flushing the
+// write behind stores
+func Setup(database *sql.DB, path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var configs []*Store
+	if err := json.Unmarshal(b, &configs); err != nil {
+		return err
+	}
+
+	for _, s := range configs {
+		s.db = database
+		if s.DSN != "" {
+			if s.db, err = sql.Open(s.Driver, s.DSN); err != nil {
+				return err
+			}
+		}
+		if s.db == nil {
+			return fmt.Errorf("%w: no database for namespace %q", ErrorInvalidStore, s.Namespace)
+		}
+		register(s)
+	}
+	log.Logger.Info("stores registered", zap.Int("stores", len(configs)))
+	return nil
+}
+
+// register prepares the statements of the store and starts flushing it
+func register(s *Store) {
+	// a write flushed after a newer one of the same key is ignored
+	s.upsert = fmt.Sprintf(`INSERT INTO %s AS t (namespace, key, value, version, updated_at) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (namespace, key) DO UPDATE SET value = excluded.value, version = excluded.version, updated_at = excluded.updated_at
+		WHERE t.version < excluded.version`, s.Table)
+	s.remove = fmt.Sprintf(`DELETE FROM %s WHERE namespace = $1 AND key = $2`, s.Table)
+	s.clear = fmt.Sprintf(`DELETE FROM %s WHERE namespace = $1`, s.Table)
+	s.dead = fmt.Sprintf(`INSERT INTO %s (namespace, key, op, value, version, error, attempts, failed_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`, s.DeadLetterTable)
+	s.pending = make(map[string]*write)
+	s.wake = make(chan struct{}, 1)
+
+	mu.Lock()
+	stores[s.Namespace] = s
+	mu.Unlock()
+
+	if s.Mode == WriteBehind {
+		go s.run()
+	}
+}
+
+func find(ns string) (*Store, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	s, ok := stores[ns]
+	return s, ok
+}
+
+// Configured reports whether the namespace has a store
+func Configured(ns string) bool {
+	_, ok := find(ns)
+	return ok
+}
+
+// Write persists the value of a key of the namespace. Write through stores
+// return the error of the write, write behind stores queue it. Namespaces
+// without a store are not persisted.
+func Write(ns string, key string, value map[string]any, version uint64) error {
+	s, ok := find(ns)
+	if !ok {
+		return nil
+	}
+	return s.submit(&write{op: opSet, key: key, value: value, version: version})
+}
+
+// Delete removes a key of the namespace from its store
+func Delete(ns string, key string) error {
+	s, ok := find(ns)
+	if !ok {
+		return nil
+	}
+	return s.submit(&write{op: opDelete, key: key})
+}
+
+// Change is a write of a key, or its removal when Delete is set
+type Change struct {
+	Key     string
+	Value   map[string]any
+	Version uint64
+	Delete  bool
+}
+
+// WriteAll persists the changes to several keys of the namespace. Write
+// through stores apply them in a single transaction, write behind stores
+// queue them.
+func WriteAll(ns string, changes []Change) error {
+	s, ok := find(ns)
+	if !ok || len(changes) == 0 {
+		return nil
+	}
+	writes := make([]*write, len(changes))
+	for i, c := range changes {
+		writes[i] = &write{op: opSet, key: c.Key, value: c.Value, version: c.Version}
+		if c.Delete {
+			writes[i] = &write{op: opDelete, key: c.Key}
+		}
+	}
+	return s.submit(writes...)
+}
+
+// Clear removes every key of the namespace from its store, dropping the
+// queued writes
+func Clear(ns string) error {
+	s, ok := find(ns)
+	if !ok {
+		return nil
+	}
+	// a flush in progress could write a dropped key after the delete
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+	s.mu.Lock()
+	s.pending = make(map[string]*write)
+	s.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), WRITE_TIMEOUT)
+	defer cancel()
+	if _, err := s.db.ExecContext(ctx, s.clear, s.Namespace); err != nil {
+		log.Logger.Error("failed to clear store", zap.String("namespace", s.Namespace), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// Flush writes every queued write now, including the ones waiting for a
+// retry, until ctx is done. The writes left queued when ctx is done are
+// logged and ctx.Err is returned.
+func Flush(ctx context.Context) error {
+	mu.RLock()
+	all := make([]*Store, 0, len(stores))
+	for _, s := range stores {
+		all = append(all, s)
+	}
+	mu.RUnlock()
+
+	for _, s := range all {
+		s.flush(ctx, true)
+	}
+	if err := ctx.Err(); err != nil {
+		for _, s := range all {
+			if n := Pending(s.Namespace); n > 0 {
+				log.Logger.Error("writes not flushed", zap.String("namespace", s.Namespace), zap.Int("writes", n))
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+// Pending returns the number of queued writes of the namespace
+func Pending(ns string) int {
+	s, ok := find(ns)
+	if !ok {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.pending)
+}
+
+func (s *Store) submit(writes ...*write) error {
+	if s.Mode == WriteThrough {
+		ctx, cancel := context.WithTimeout(context.Background(), WRITE_TIMEOUT)
+		defer cancel()
+		if err := s.execAll(ctx, writes); err != nil {
+			log.Logger.Error("failed to write through", zap.String("namespace", s.Namespace), zap.String("key", writes[0].key), zap.Error(err))
+			return err
+		}
+		return nil
+	}
+
+	// a newer write of the key supersedes the queued one
+	s.mu.Lock()
+	for _, w := range writes {
+		if queued, ok := s.pending[w.key]; ok && queued.op == opSet && w.op == opSet && queued.version > w.version {
+			continue
+		}
+		s.pending[w.key] = w
+	}
+	full := len(s.pending) >= s.BatchSize
+	s.mu.Unlock()
+	if full {
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// run flushes the queue at every interval or as soon as a batch is full
+func (s *Store) run() {
+	ticker := time.NewTicker(s.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.wake:
+		}
+		s.flush(context.Background(), false)
+	}
+}
+
+// flush writes the queued writes that are due in batches until none is left
+// or ctx is done. When force is set every queued write is tried once, due or
+// not.
+func (s *Store) flush(ctx context.Context, force bool) {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+
+	tried := make(map[*write]bool)
+	for ctx.Err() == nil {
+		batch := s.take(force, tried)
+		if len(batch) == 0 {
+			return
+		}
+		failed := s.writeBatch(ctx, batch)
+		now := time.Now()
+		for _, w := range failed {
+			if ctx.Err() != nil {
+				// cut short by ctx rather than failed
+				s.requeue(w)
+				continue
+			}
+			w.attempts++
+			if w.attempts > s.MaxRetries {
+				s.deadLetter(w)
+				continue
+			}
+			w.due = now.Add(backoff(s.FlushInterval, w.attempts))
+			tried[w] = true
+			s.requeue(w)
+		}
+	}
+}
+
+// take removes up to a batch of due writes from the queue, skipping the ones
+// already tried by this flush
+func (s *Store) take(force bool, tried map[*write]bool) []*write {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var batch []*write
+	for key, w := range s.pending {
+		if len(batch) == s.BatchSize {
+			break
+		}
+		if !tried[w] && (force || !w.due.After(now)) {
+			batch = append(batch, w)
+			delete(s.pending, key)
+		}
+	}
+	return batch
+}
+
+// requeue queues a failed write again unless the key was written since
+func (s *Store) requeue(w *write) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.pending[w.key]; !ok {
+		s.pending[w.key] = w
+	}
+}
+
+// writeBatch writes a batch in one transaction. When the transaction fails
+// the writes are applied one by one so a single bad write does not hold back
+// the others, and the ones failing are returned.
+func (s *Store) writeBatch(ctx context.Context, batch []*write) []*write {
+	ctx, cancel := context.WithTimeout(ctx, WRITE_TIMEOUT)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err == nil {
+		for _, w := range batch {
+			if err = s.exec(ctx, tx, w); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			err = tx.Commit()
+		} else {
+			tx.Rollback()
+		}
+	}
+	if err == nil {
+		log.Logger.Debug("store flushed", zap.String("namespace", s.Namespace), zap.Int("writes", len(batch)))
+		return nil
+	}
+	log.Logger.Warn("failed to flush batch", zap.String("namespace", s.Namespace), zap.Error(err))
+
+	var failed []*write
+	for _, w := range batch {
+		if w.err = s.exec(ctx, s.db, w); w.err != nil {
+			failed = append(failed, w)
+		}
+	}
+	return failed
+}
+
+// execAll applies writes in a single transaction, a single write is applied
+// on its own
+func (s *Store) execAll(ctx context.Context, writes []*write) error {
+	if len(writes) == 1 {
+		return s.exec(ctx, s.db, writes[0])
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	for _, w := range writes {
+		if err := s.exec(ctx, tx, w); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// exec applies a single write
+func (s *Store) exec(ctx context.Context, db execer, w *write) error {
+	if w.op == opDelete {
+		_, err := db.ExecContext(ctx, s.remove, s.Namespace, w.key)
+		return err
+	}
+	b, err := json.Marshal(w.value)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, s.upsert, s.Namespace, w.key, string(b), int64(w.version), time.Now().UTC())
+	return err
+}
+
+// deadLetter records a write that exhausted its retries
+func (s *Store) deadLetter(w *write) {
+	var value any
+	if w.op == opSet {
+		b, _ := json.Marshal(w.value)
+		value = string(b)
+	}
+	reason := ""
+	if w.err != nil {
+		reason = w.err.Error()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), WRITE_TIMEOUT)
+	defer cancel()
+	_, err := s.db.ExecContext(ctx, s.dead, s.Namespace, w.key, w.op, value, int64(w.version), reason, w.attempts, time.Now().UTC())
+	if err != nil {
+		// the write is lost, keep it in the log so it can be replayed by hand
+		log.Logger.Error("failed to dead letter write", zap.String("namespace", s.Namespace), zap.String("key", w.key),
+			zap.String("op", w.op), zap.Any("value", w.value), zap.Error(err))
+		return
+	}
+	log.Logger.Warn("write dead lettered", zap.String("namespace", s.Namespace), zap.String("key", w.key), zap.String("error", reason))
+}
+
+// backoff doubles the delay before each retry of a write
+func backoff(interval time.Duration, attempts int) time.Duration {
+	d := interval
+	for i := 1; i < attempts && d < MAX_RETRY_BACKOFF; i++ {
+		d *= 2
+	}
+	return min(d, MAX_RETRY_BACKOFF)
+}
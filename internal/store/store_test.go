@@ -0,0 +1,220 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	_ "modernc.org/sqlite"
+)
+
+func setupDB(t *testing.T) *sql.DB {
+	t.Helper()
+	database, err := sql.Open("sqlite", ":memory:")
+	assert.NoError(t, err)
+	database.SetMaxOpenConns(1)
+	t.Cleanup(func() { database.Close() })
+
+	for _, stmt := range []string{
+		`CREATE TABLE entries (namespace TEXT, key TEXT CHECK (key <> 'bad'), value TEXT, version INTEGER, updated_at TIMESTAMP, PRIMARY KEY (namespace, key))`,
+		`CREATE TABLE dead_letters (namespace TEXT, key TEXT, op TEXT, value TEXT, version INTEGER, error TEXT, attempts INTEGER, failed_at TIMESTAMP)`,
+	} {
+		_, err := database.Exec(stmt)
+		assert.NoError(t, err)
+	}
+	return database
+}
+
+func setupStore(t *testing.T, database *sql.DB, config string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "stores.json")
+	assert.NoError(t, os.WriteFile(path, []byte(config), 0o644))
+	assert.NoError(t, Setup(database, path))
+	t.Cleanup(func() {
+		mu.Lock()
+		stores = make(map[string]*Store)
+		mu.Unlock()
+	})
+}
+
+func value(t *testing.T, database *sql.DB, ns, key string) string {
+	t.Helper()
+	var v string
+	err := database.QueryRow(`SELECT value FROM entries WHERE namespace = $1 AND key = $2`, ns, key).Scan(&v)
+	if err == sql.ErrNoRows {
+		return ""
+	}
+	assert.NoError(t, err)
+	return v
+}
+
+func TestWriteThrough(t *testing.T) {
+	database := setupDB(t)
+	setupStore(t, database, `[{"namespace": "users", "mode": "write-through", "table": "entries", "dead_letter_table": "dead_letters"}]`)
+
+	// Test writes are applied before returning
+	assert.NoError(t, Write("users", "user:1", map[string]any{"name": "ada"}, 1))
+	assert.Equal(t, `{"name":"ada"}`, value(t, database, "users", "user:1"))
+	assert.NoError(t, Write("users", "user:1", map[string]any{"name": "grace"}, 2))
+	assert.Equal(t, `{"name":"grace"}`, value(t, database, "users", "user:1"))
+
+	// Test failed writes are returned
+	assert.Error(t, Write("users", "bad", map[string]any{"name": "ada"}, 3))
+
+	// Test deletes
+	assert.NoError(t, Delete("users", "user:1"))
+	assert.Equal(t, "", value(t, database, "users", "user:1"))
+
+	// Test namespaces without a store are not persisted
+	assert.NoError(t, Write("orders", "order:1", map[string]any{"total": 1}, 4))
+	assert.Equal(t, "", value(t, database, "orders", "order:1"))
+}
+
+func TestWriteBehind(t *testing.T) {
+	database := setupDB(t)
+	setupStore(t, database, `[{"namespace": "users", "mode": "write-behind", "table": "entries", "dead_letter_table": "dead_letters", "flush_interval": "1h", "max_retries": 2}]`)
+
+	// Test writes are queued and coalesced per key
+	assert.NoError(t, Write("users", "user:1", map[string]any{"name": "ada"}, 1))
+	assert.NoError(t, Write("users", "user:1", map[string]any{"name": "grace"}, 2))
+	assert.NoError(t, Write("users", "user:2", map[string]any{"name": "alan"}, 3))
+	assert.NoError(t, Write("users", "bad", map[string]any{"name": "eve"}, 4))
+	assert.Equal(t, 3, Pending("users"))
+	assert.Equal(t, "", value(t, database, "users", "user:1"))
+
+	// Test a failing write does not hold back the rest of the batch
+	Flush(context.Background())
+	assert.Equal(t, `{"name":"grace"}`, value(t, database, "users", "user:1"))
+	assert.Equal(t, `{"name":"alan"}`, value(t, database, "users", "user:2"))
+	assert.Equal(t, 1, Pending("users"))
+
+	// Test a failed write waits for its backoff unless forced
+	s, _ := find("users")
+	s.flush(context.Background(), false)
+	assert.Equal(t, 1, Pending("users"))
+
+	// Test a write exhausting its retries is dead lettered
+	Flush(context.Background())
+	Flush(context.Background())
+	assert.Equal(t, 0, Pending("users"))
+	var op, reason string
+	var attempts int
+	err := database.QueryRow(`SELECT op, error, attempts FROM dead_letters WHERE namespace = 'users' AND key = 'bad'`).Scan(&op, &reason, &attempts)
+	assert.NoError(t, err)
+	assert.Equal(t, "set", op)
+	assert.Contains(t, reason, "CHECK")
+	assert.Equal(t, 3, attempts)
+
+	// Test queued deletes
+	assert.NoError(t, Delete("users", "user:2"))
+	Flush(context.Background())
+	assert.Equal(t, "", value(t, database, "users", "user:2"))
+}
+
+func TestWriteBehindBatch(t *testing.T) {
+	database := setupDB(t)
+	setupStore(t, database, `[{"namespace": "users", "mode": "write-behind", "table": "entries", "dead_letter_table": "dead_letters", "flush_interval": "1h", "batch_size": 2}]`)
+
+	// Test a full batch is flushed without waiting for the interval
+	assert.NoError(t, Write("users", "user:1", map[string]any{"name": "ada"}, 1))
+	assert.NoError(t, Write("users", "user:2", map[string]any{"name": "alan"}, 2))
+	assert.Eventually(t, func() bool { return Pending("users") == 0 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, `{"name":"alan"}`, value(t, database, "users", "user:2"))
+}
+
+func TestFlushDeadline(t *testing.T) {
+	database := setupDB(t)
+	setupStore(t, database, `[{"namespace": "users", "mode": "write-behind", "table": "entries", "dead_letter_table": "dead_letters", "flush_interval": "1h"}]`)
+	assert.NoError(t, Write("users", "user:1", map[string]any{"name": "ada"}, 1))
+
+	// Test a flush past its deadline keeps the writes queued
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, Flush(ctx), context.Canceled)
+	assert.Equal(t, 1, Pending("users"))
+
+	assert.NoError(t, Flush(context.Background()))
+	assert.Equal(t, `{"name":"ada"}`, value(t, database, "users", "user:1"))
+}
+
+func TestOutOfOrder(t *testing.T) {
+	database := setupDB(t)
+	setupStore(t, database, `[{"namespace": "users", "mode": "write-behind", "table": "entries", "dead_letter_table": "dead_letters", "flush_interval": "1h"}]`)
+
+	// Test a write flushed after a newer one of the same key is ignored
+	assert.NoError(t, Write("users", "user:1", map[string]any{"name": "grace"}, 2))
+	Flush(context.Background())
+	assert.NoError(t, Write("users", "user:1", map[string]any{"name": "ada"}, 1))
+	Flush(context.Background())
+	assert.Equal(t, `{"name":"grace"}`, value(t, database, "users", "user:1"))
+
+	// Test an older write does not replace a newer queued one
+	assert.NoError(t, Write("users", "user:2", map[string]any{"name": "grace"}, 4))
+	assert.NoError(t, Write("users", "user:2", map[string]any{"name": "ada"}, 3))
+	Flush(context.Background())
+	assert.Equal(t, `{"name":"grace"}`, value(t, database, "users", "user:2"))
+}
+
+func TestWriteAll(t *testing.T) {
+	database := setupDB(t)
+	setupStore(t, database, `[{"namespace": "users", "mode": "write-through", "table": "entries", "dead_letter_table": "dead_letters"}]`)
+	assert.NoError(t, Write("users", "user:1", map[string]any{"name": "ada"}, 1))
+
+	// Test the changes are applied together
+	assert.NoError(t, WriteAll("users", []Change{
+		{Key: "user:1", Delete: true},
+		{Key: "user:2", Value: map[string]any{"name": "alan"}, Version: 2},
+	}))
+	assert.Equal(t, "", value(t, database, "users", "user:1"))
+	assert.Equal(t, `{"name":"alan"}`, value(t, database, "users", "user:2"))
+
+	// Test a failing change rolls back the others
+	assert.Error(t, WriteAll("users", []Change{
+		{Key: "user:3", Value: map[string]any{"name": "grace"}, Version: 3},
+		{Key: "bad", Value: map[string]any{"name": "eve"}, Version: 3},
+	}))
+	assert.Equal(t, "", value(t, database, "users", "user:3"))
+
+	// Test clearing the namespace
+	assert.NoError(t, Clear("users"))
+	assert.Equal(t, "", value(t, database, "users", "user:2"))
+}
+
+func TestClearWriteBehind(t *testing.T) {
+	database := setupDB(t)
+	setupStore(t, database, `[{"namespace": "users", "mode": "write-behind", "table": "entries", "dead_letter_table": "dead_letters", "flush_interval": "1h"}]`)
+
+	// Test clearing drops the queued writes
+	assert.NoError(t, Write("users", "user:1", map[string]any{"name": "ada"}, 1))
+	Flush(context.Background())
+	assert.NoError(t, Write("users", "user:2", map[string]any{"name": "alan"}, 2))
+	assert.NoError(t, Clear("users"))
+	assert.Equal(t, 0, Pending("users"))
+	Flush(context.Background())
+	assert.Equal(t, "", value(t, database, "users", "user:1"))
+	assert.Equal(t, "", value(t, database, "users", "user:2"))
+}
+
+func TestSetupInvalid(t *testing.T) {
+	for _, config := range []string{
+		`[{"namespace": "users", "mode": "write-around"}]`,
+		`[{"namespace": "users", "mode": "write-through", "table": "entries; DROP TABLE entries"}]`,
+		`[{"namespace": "users", "mode": "write-behind", "flush_interval": "soon"}]`,
+		`[{"namespace": "users", "mode": "write-behind", "batch_size": -1}]`,
+		`[{"namespace": "users", "mode": "write-through"}]`,
+	} {
+		path := filepath.Join(t.TempDir(), "stores.json")
+		assert.NoError(t, os.WriteFile(path, []byte(config), 0o644))
+		assert.Error(t, Setup(nil, path), config)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, time.Second, backoff(time.Second, 1))
+	assert.Equal(t, 4*time.Second, backoff(time.Second, 3))
+	assert.Equal(t, MAX_RETRY_BACKOFF, backoff(time.Second, 20))
+}
@@ -0,0 +1,184 @@
+package tlsconfig
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vishaldc/go-cache/internal/log"
+	"go.uber.org/zap"
+)
+
+// RELOAD_INTERVAL is how often the files are checked for changes
+const RELOAD_INTERVAL = 10 * time.Second
+
+var ErrorInvalidTLS = errors.New("invalid TLS configuration")
+
+// Config is the TLS configuration of a server. The certificate, key and
+// client CA files are reloaded when they change on disk.
+type Config struct {
+	CertFile string
+	KeyFile  string
+	// MinVersion is 1.2 or 1.3, 1.2 when empty
+	MinVersion string
+	// CipherSuites are the names of the TLS 1.2 cipher suites allowed, such
+	// as TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, the Go defaults when empty
+	CipherSuites []string
+	// ClientCAFile verifies the certificates of the clients when set
+	ClientCAFile string
+	// ClientAuth is require to refuse clients without a certificate of the
+	// CA or request to only verify the ones presented, require when empty
+	ClientAuth string
+}
+
+// Reloader serves the certificate and client CA last loaded from the files
+// of its configuration
+type Reloader struct {
+	config Config
+	base   *tls.Config
+
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	clientCA *x509.CertPool
+	// contents of the files last loaded, compared to detect changes
+	loaded [][]byte
+}
+
+// New loads the files of the configuration
+func New(config Config) (*Reloader, error) {
+	if config.CertFile == "" || config.KeyFile == "" {
+		return nil, fmt.Errorf("%w: a certificate and a key are required", ErrorInvalidTLS)
+	}
+	base := &tls.Config{MinVersion: tls.VersionTLS12}
+	switch config.MinVersion {
+	case "", "1.2":
+	case "1.3":
+		base.MinVersion = tls.VersionTLS13
+	default:
+		return nil, fmt.Errorf("%w: unsupported minimum version %q", ErrorInvalidTLS, config.MinVersion)
+	}
+	for _, name := range config.CipherSuites {
+		id, ok := cipherSuite(name)
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown or insecure cipher suite %q", ErrorInvalidTLS, name)
+		}
+		base.CipherSuites = append(base.CipherSuites, id)
+	}
+	if config.ClientCAFile != "" {
+		switch config.ClientAuth {
+		case "", "require":
+			base.ClientAuth = tls.RequireAndVerifyClientCert
+		case "request":
+			base.ClientAuth = tls.VerifyClientCertIfGiven
+		default:
+			return nil, fmt.Errorf("%w: unsupported client auth %q", ErrorInvalidTLS, config.ClientAuth)
+		}
+	}
+
+	// the configuration returned per connection replaces the one of the
+	// server, HTTP/2 is negotiated on it
+	base.NextProtos = []string{"h2", "http/1.1"}
+	r := &Reloader{config: config, base: base}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// cipherSuite returns the id of a secure cipher suite by name
+func cipherSuite(name string) (uint16, bool) {
+	for _, suite := range tls.CipherSuites() {
+		if suite.Name == strings.TrimSpace(name) {
+			return suite.ID, true
+		}
+	}
+	return 0, false
+}
+
+// TLSConfig returns the configuration of a server using the certificate and
+// client CA currently loaded for every new connection
+func (r *Reloader) TLSConfig() *tls.Config {
+	config := r.base.Clone()
+	config.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		c := r.base.Clone()
+		c.Certificates = []tls.Certificate{*r.cert}
+		c.ClientCAs = r.clientCA
+		return c, nil
+	}
+	return config
+}
+
+// Watch reloads the files when they change until ctx is done. A change that
+// can not be loaded, such as a certificate written before its key, keeps the
+// previous files in use until the next check.
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.reload(); err != nil {
+				log.Logger.Error("failed to reload TLS certificate", zap.Error(err))
+			}
+		}
+	}
+}
+
+// reload loads the files when their content changed since the last load
+func (r *Reloader) reload() error {
+	paths := []string{r.config.CertFile, r.config.KeyFile}
+	if r.config.ClientCAFile != "" {
+		paths = append(paths, r.config.ClientCAFile)
+	}
+	// the files are read rather than stat'ed so the symlink swaps of
+	// Kubernetes secrets are seen
+	contents := make([][]byte, len(paths))
+	for i, path := range paths {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		contents[i] = b
+	}
+
+	r.mu.RLock()
+	unchanged := r.loaded != nil
+	for i := range contents {
+		unchanged = unchanged && bytes.Equal(contents[i], r.loaded[i])
+	}
+	r.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	cert, err := tls.X509KeyPair(contents[0], contents[1])
+	if err != nil {
+		return errors.Join(ErrorInvalidTLS, err)
+	}
+	var pool *x509.CertPool
+	if len(contents) > 2 {
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(contents[2]) {
+			return fmt.Errorf("%w: no certificate in %s", ErrorInvalidTLS, r.config.ClientCAFile)
+		}
+	}
+
+	r.mu.Lock()
+	r.cert, r.clientCA, r.loaded = &cert, pool, contents
+	r.mu.Unlock()
+	if cert.Leaf != nil {
+		log.Logger.Info("TLS certificate loaded", zap.Strings("dns_names", cert.Leaf.DNSNames), zap.Time("not_after", cert.Leaf.NotAfter))
+	}
+	return nil
+}
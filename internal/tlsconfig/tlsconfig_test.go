@@ -0,0 +1,138 @@
+package tlsconfig
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeCert writes a new self signed certificate for localhost and its key
+// to the files, returning its serial number
+func writeCert(t *testing.T, certFile, keyFile string) *big.Int {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	serial := big.NewInt(time.Now().UnixNano())
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "localhost"},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	assert.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return serial
+}
+
+// serve starts a TLS server using the configuration of the reloader
+func serve(t *testing.T, r *Reloader) *httptest.Server {
+	t.Helper()
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	server.TLS = r.TLSConfig()
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+// served returns the serial number of the certificate served by a server
+func served(t *testing.T, server *httptest.Server, certs ...tls.Certificate) *big.Int {
+	t.Helper()
+	conn, err := tls.Dial("tcp", server.Listener.Addr().String(), &tls.Config{InsecureSkipVerify: true, Certificates: certs})
+	assert.NoError(t, err)
+	defer conn.Close()
+	assert.NoError(t, conn.Handshake())
+	return conn.ConnectionState().PeerCertificates[0].SerialNumber
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	first := writeCert(t, certFile, keyFile)
+	r, err := New(Config{CertFile: certFile, KeyFile: keyFile, MinVersion: "1.3"})
+	assert.NoError(t, err)
+	server := serve(t, r)
+	assert.Equal(t, first, served(t, server))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Watch(ctx, 10*time.Millisecond)
+
+	// Test a rotated certificate is served to new connections
+	second := writeCert(t, certFile, keyFile)
+	assert.Eventually(t, func() bool { return served(t, server).Cmp(second) == 0 }, time.Second, 10*time.Millisecond)
+
+	// Test a broken file keeps the last certificate in use
+	assert.NoError(t, os.WriteFile(keyFile, []byte("garbage"), 0o600))
+	assert.Error(t, r.reload())
+	assert.Equal(t, second, served(t, server))
+
+	// Test the minimum version is enforced
+	_, err = tls.Dial("tcp", server.Listener.Addr().String(), &tls.Config{InsecureSkipVerify: true, MaxVersion: tls.VersionTLS12})
+	assert.Error(t, err)
+}
+
+func TestClientAuth(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	clientCert, clientKey := filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+	writeCert(t, certFile, keyFile)
+	writeCert(t, clientCert, clientKey)
+	r, err := New(Config{CertFile: certFile, KeyFile: keyFile, ClientCAFile: clientCert})
+	assert.NoError(t, err)
+	server := serve(t, r)
+
+	// Test a client presenting a certificate of the CA is served
+	cert, err := tls.LoadX509KeyPair(clientCert, clientKey)
+	assert.NoError(t, err)
+	assert.NotNil(t, served(t, server, cert))
+
+	// Test a client without a certificate is refused
+	conn, err := tls.Dial("tcp", server.Listener.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+	if err == nil {
+		// TLS 1.3 reports the missing certificate on the first read
+		_, err = conn.Read(make([]byte, 1))
+		conn.Close()
+	}
+	assert.Error(t, err)
+}
+
+func TestNewInvalid(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeCert(t, certFile, keyFile)
+
+	_, err := New(Config{CertFile: certFile})
+	assert.ErrorIs(t, err, ErrorInvalidTLS)
+	_, err = New(Config{CertFile: certFile, KeyFile: keyFile, MinVersion: "1.0"})
+	assert.ErrorIs(t, err, ErrorInvalidTLS)
+	_, err = New(Config{CertFile: certFile, KeyFile: keyFile, CipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}})
+	assert.ErrorIs(t, err, ErrorInvalidTLS)
+	_, err = New(Config{CertFile: certFile, KeyFile: keyFile, CipherSuites: []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"}})
+	assert.NoError(t, err)
+}
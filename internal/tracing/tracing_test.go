@@ -0,0 +1,54 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestMiddleware(t *testing.T) {
+	exporter := SetupInMemory()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /cache", func(w http.ResponseWriter, r *http.Request) {
+		_, span := StartCache(r.Context(), "get", "default", r.URL.Query().Get("key"))
+		End(span, nil)
+		http.Error(w, "failed to get cache", http.StatusInternalServerError)
+	})
+
+	// Test the server span is named after the route and parents the cache span
+	Middleware(mux).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/cache?key=a", nil))
+	spans := exporter.GetSpans()
+	assert.Len(t, spans, 2)
+	assert.Equal(t, "cache.get", spans[0].Name)
+	assert.Equal(t, "GET /cache", spans[1].Name)
+	assert.Equal(t, trace.SpanKindServer, spans[1].SpanKind)
+	assert.Equal(t, codes.Error, spans[1].Status.Code)
+	assert.Equal(t, spans[1].SpanContext.SpanID(), spans[0].Parent.SpanID())
+}
+
+func TestPropagation(t *testing.T) {
+	exporter := SetupInMemory()
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	// Test the trace of an outgoing request continues on the server it reaches
+	ctx, span := StartClient(context.Background(), "POST /cache/sync")
+	req := httptest.NewRequest("POST", "/cache/sync", nil)
+	Inject(ctx, req.Header)
+	assert.NotEmpty(t, req.Header.Get("traceparent"))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	End(span, errors.New("worker responded with status 500"))
+
+	spans := exporter.GetSpans()
+	assert.Len(t, spans, 2)
+	assert.Equal(t, span.SpanContext().TraceID(), spans[0].SpanContext.TraceID())
+	assert.Equal(t, span.SpanContext().SpanID(), spans[0].Parent.SpanID())
+	assert.Equal(t, codes.Error, spans[1].Status.Code)
+}